@@ -0,0 +1,184 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package audit implements an append-only log of every credential issued by the
+helper, written as JSON lines. Tokens are never recorded.
+*/
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Outcomes of a credential request.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	// OutcomeDenied is the outcome of a request refused by policy.
+	OutcomeDenied = "denied"
+)
+
+// DefaultMaxSize is the size, in bytes, at which an audit log is rotated if no
+// other size is configured.
+const DefaultMaxSize = 10 * 1024 * 1024
+
+// A Record describes a single credential request.
+type Record struct {
+	Time        time.Time  `json:"time"`
+	ServerURL   string     `json:"server_url"`
+	TokenSource string     `json:"token_source,omitempty"`
+	Principal   string     `json:"principal,omitempty"`
+	TokenExpiry *time.Time `json:"token_expiry,omitempty"`
+	ParentPID   int        `json:"parent_pid"`
+	ParentName  string     `json:"parent_name,omitempty"`
+	Outcome     string     `json:"outcome"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// Log is an append-only audit log, rotated by size.
+type Log struct {
+	// Path is the path of the active log file.
+	Path string
+	// MaxSize is the size, in bytes, beyond which the log is rotated to
+	// Path + ".1", replacing any previously rotated log. If <= 0,
+	// DefaultMaxSize is used.
+	MaxSize int64
+}
+
+// rotatedPath returns the path of the previously rotated log.
+func (l *Log) rotatedPath() string {
+	return l.Path + ".1"
+}
+
+// lockPath returns the path of the file locked while appending to the log.
+func (l *Log) lockPath() string {
+	return l.Path + ".lock"
+}
+
+// lock blocks until it holds the log's lock, shared by every helper process,
+// and returns a function releasing it.
+func (l *Log) lock() (func(), error) {
+	f, err := os.OpenFile(l.lockPath(), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, auditErr("failed to open "+l.lockPath(), err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, auditErr("failed to lock "+l.lockPath(), err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// Append appends a record to the log, rotating it first if it would exceed
+// its maximum size. Concurrent helpers take turns, so that a log is never
+// rotated twice over and its previous records lost.
+func (l *Log) Append(r *Record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return auditErr("failed to encode record", err)
+	}
+	line = append(line, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0700); err != nil {
+		return auditErr("failed to create audit log directory", err)
+	}
+	unlock, err := l.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	maxSize := l.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if fi, err := os.Stat(l.Path); err == nil && fi.Size()+int64(len(line)) > maxSize {
+		if err := os.Rename(l.Path, l.rotatedPath()); err != nil && !os.IsNotExist(err) {
+			return auditErr("failed to rotate "+l.Path, err)
+		}
+	}
+
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return auditErr("failed to open "+l.Path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(line); err != nil {
+		return auditErr("failed to write "+l.Path, err)
+	}
+	return nil
+}
+
+// Tail returns the last n lines of the log, oldest first, including those in
+// the previously rotated log if necessary.
+func (l *Log) Tail(n int) ([]string, error) {
+	if n < 0 {
+		return nil, auditErr(fmt.Sprintf("invalid number of lines: %d", n), nil)
+	}
+	lines, err := readLines(l.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) < n {
+		rotated, err := readLines(l.rotatedPath())
+		if err != nil {
+			return nil, err
+		}
+		lines = append(rotated, lines...)
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
+
+// readLines returns every line of the given file, or none if it doesn't exist.
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, auditErr("failed to open "+path, err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, auditErr("failed to read "+path, err)
+	}
+	return lines, nil
+}
+
+func auditErr(message string, err error) error {
+	if err == nil {
+		return fmt.Errorf("docker-credential-gcr/audit: %s", message)
+	}
+	return fmt.Errorf("docker-credential-gcr/audit: %s: %v", message, err)
+}
@@ -0,0 +1,143 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testLog(t *testing.T, maxSize int64) *Log {
+	return &Log{Path: filepath.Join(t.TempDir(), "audit.log"), MaxSize: maxSize}
+}
+
+func TestAppend_JSONLines(t *testing.T) {
+	tested := testLog(t, 0)
+	expiry := time.Date(2036, 1, 2, 15, 4, 5, 0, time.UTC)
+	expected := &Record{
+		Time:        time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC),
+		ServerURL:   "https://gcr.io",
+		TokenSource: "env",
+		Principal:   "sa@project.iam.gserviceaccount.com",
+		TokenExpiry: &expiry,
+		ParentPID:   42,
+		ParentName:  "docker",
+		Outcome:     OutcomeSuccess,
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := tested.Append(expected); err != nil {
+			t.Fatalf("Append returned an error: %v", err)
+		}
+	}
+
+	lines, err := tested.Tail(10)
+	if err != nil {
+		t.Fatalf("Tail returned an error: %v", err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got: %v", lines)
+	}
+	var actual Record
+	if err := json.Unmarshal([]byte(lines[1]), &actual); err != nil {
+		t.Fatalf("Unable to decode record: %v", err)
+	}
+	if actual.ServerURL != expected.ServerURL || actual.Principal != expected.Principal || !actual.TokenExpiry.Equal(expiry) || actual.ParentPID != 42 {
+		t.Errorf("Expected: %+v, got: %+v", expected, actual)
+	}
+
+	if fi, err := os.Stat(tested.Path); err != nil {
+		t.Fatalf("Unable to stat the audit log: %v", err)
+	} else if fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected audit log permissions 0600, got: %v", fi.Mode().Perm())
+	}
+}
+
+func TestAppend_RotatesBySize(t *testing.T) {
+	// Small enough that every record triggers a rotation.
+	tested := testLog(t, 10)
+
+	for i := 0; i < 3; i++ {
+		if err := tested.Append(&Record{ServerURL: fmt.Sprintf("https://%d.gcr.io", i), Outcome: OutcomeSuccess}); err != nil {
+			t.Fatalf("Append returned an error: %v", err)
+		}
+	}
+
+	current, err := readLines(tested.Path)
+	if err != nil || len(current) != 1 {
+		t.Fatalf("Expected 1 record in the active log, got: %v, %v", current, err)
+	}
+	rotated, err := readLines(tested.rotatedPath())
+	if err != nil || len(rotated) != 1 {
+		t.Fatalf("Expected 1 record in the rotated log, got: %v, %v", rotated, err)
+	}
+
+	// Tail spans both files.
+	lines, err := tested.Tail(5)
+	if err != nil {
+		t.Fatalf("Tail returned an error: %v", err)
+	}
+	if len(lines) != 2 || lines[1] != current[0] || lines[0] != rotated[0] {
+		t.Errorf("Expected the rotated record followed by the active one, got: %v", lines)
+	}
+}
+
+func TestAppend_ConcurrentRotation(t *testing.T) {
+	record := func(i int) *Record {
+		return &Record{ServerURL: fmt.Sprintf("https://%03d.gcr.io", i), Outcome: OutcomeSuccess}
+	}
+	line, err := json.Marshal(record(0))
+	if err != nil {
+		t.Fatalf("Unable to encode record: %v", err)
+	}
+	// Room for 10 records, so that after 205 the rotated log holds 10 and the
+	// active one 5.
+	tested := testLog(t, int64(10*(len(line)+1)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 205; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := tested.Append(record(i)); err != nil {
+				t.Errorf("Append returned an error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if lines, err := tested.Tail(100); err != nil || len(lines) != 15 {
+		t.Errorf("Expected 15 records to be kept, got %d: %v", len(lines), err)
+	}
+}
+
+func TestTail_Missing(t *testing.T) {
+	lines, err := testLog(t, 0).Tail(10)
+
+	if err != nil || len(lines) != 0 {
+		t.Fatalf("Expected no lines and no error, got: %v, %v", lines, err)
+	}
+}
+
+func TestTail_Negative(t *testing.T) {
+	if _, err := testLog(t, 0).Tail(-1); err == nil {
+		t.Error("Expected a negative number of lines to be refused")
+	}
+}
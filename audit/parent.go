@@ -0,0 +1,36 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// ParentProcess returns the PID and, where available, the name of the process
+// which invoked the helper (typically the Docker CLI).
+func ParentProcess() (int, string) {
+	ppid := os.Getppid()
+	if runtime.GOOS != "linux" {
+		return ppid, ""
+	}
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", ppid))
+	if err != nil {
+		return ppid, ""
+	}
+	return ppid, strings.TrimSpace(string(comm))
+}
@@ -19,17 +19,16 @@ generate access tokens for use with GCR.
 package auth
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"log/slog"
 	"net"
-	"net/http"
-	"net/http/httputil"
 	"os"
-	"strings"
+	"time"
 
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
 	"github.com/toqueteos/webbrowser"
@@ -38,6 +37,14 @@ import (
 
 const redirectURIAuthCodeInTitleBar = "urn:ietf:wg:oauth:2.0:oob"
 
+// emailScope is requested in addition to the configured scopes so that the
+// signed-in account can be identified via tokeninfo.
+const emailScope = "https://www.googleapis.com/auth/userinfo.email"
+
+// DefaultLoginTimeout is how long PerformLogin waits for the user to complete
+// the login in their browser, by default.
+const DefaultLoginTimeout = 5 * time.Minute
+
 // GCRLoginAgent implements the OAuth2 login dance, generating an Oauth2 access_token
 // for the user. If AllowBrowser is set to true, the agent will attempt to
 // obtain an authorization_code automatically by executing OpenBrowser and
@@ -53,6 +60,29 @@ type GCRLoginAgent struct {
 
 	// Open the browser for the given url.  If nil, uses webbrowser.Open.
 	OpenBrowser func(url string) error
+
+	// The OAuth2 scopes to request. If nil, uses config.GCRScopes.
+	Scopes []string
+
+	// Whether Scopes are pinned by policy, in which case they're requested
+	// as is, without the email scope. The signed-in account may then be
+	// unknown.
+	ScopesPinned bool
+
+	// How long to wait for the user to complete the login. If zero, uses
+	// DefaultLoginTimeout.
+	Timeout time.Duration
+
+	// The OAuth2 client to sign in with. If unset, uses
+	// config.DefaultOAuthClient.
+	Client config.OAuthClient
+
+	// The account, e.g. an email address, to suggest to the user. Optional.
+	LoginHint string
+
+	// The Google Workspace domain whose accounts are offered to the user.
+	// Optional.
+	HostedDomain string
 }
 
 // populate missing fields as described in the struct definition comments
@@ -66,16 +96,39 @@ func (a *GCRLoginAgent) init() {
 	if a.OpenBrowser == nil {
 		a.OpenBrowser = webbrowser.Open
 	}
+	if a.Scopes == nil {
+		a.Scopes = config.GCRScopes
+	}
+	if a.Timeout == 0 {
+		a.Timeout = DefaultLoginTimeout
+	}
+	if a.Client == (config.OAuthClient{}) {
+		a.Client = config.DefaultOAuthClient
+	}
+}
+
+// scopes returns the scopes to request during login.
+func (a *GCRLoginAgent) scopes() []string {
+	if a.ScopesPinned {
+		return a.Scopes
+	}
+	for _, scope := range a.Scopes {
+		if scope == emailScope {
+			return a.Scopes
+		}
+	}
+	return append(append([]string{}, a.Scopes...), emailScope)
 }
 
 // PerformLogin performs the auth dance necessary to obtain an
 // authorization_code from the user and exchange it for an Oauth2 access_token.
-func (a *GCRLoginAgent) PerformLogin() (*oauth2.Token, error) {
+// Waiting for the authorization_code is abandoned if ctx is done first.
+func (a *GCRLoginAgent) PerformLogin(ctx context.Context) (*oauth2.Token, error) {
 	a.init()
 	conf := &oauth2.Config{
-		ClientID:     config.GCRCredHelperClientID,
-		ClientSecret: config.GCRCredHelperClientNotSoSecret,
-		Scopes:       config.GCRScopes,
+		ClientID:     a.Client.ID,
+		ClientSecret: a.Client.Secret,
+		Scopes:       a.scopes(),
 		Endpoint:     config.GCROAuth2Endpoint,
 	}
 
@@ -89,6 +142,12 @@ func (a *GCRLoginAgent) PerformLogin() (*oauth2.Token, error) {
 		oauth2.SetAuthURLParam("code_challenge", challenge),
 		oauth2.SetAuthURLParam("code_challenge_method", method),
 	}
+	if a.LoginHint != "" {
+		authCodeOpts = append(authCodeOpts, oauth2.SetAuthURLParam("login_hint", a.LoginHint))
+	}
+	if a.HostedDomain != "" {
+		authCodeOpts = append(authCodeOpts, oauth2.SetAuthURLParam("hd", a.HostedDomain))
+	}
 
 	// Browser based auth is the only mechanism supported now.
 	// Attempt to receive the authorization code via redirect URL
@@ -97,22 +156,33 @@ func (a *GCRLoginAgent) PerformLogin() (*oauth2.Token, error) {
 		return nil, fmt.Errorf("Unable to open local listener: %v", err)
 	}
 	defer ln.Close()
+	waitCtx, cancel := context.WithTimeout(ctx, a.Timeout)
+	defer cancel()
 
 	// open a web browser and listen on the redirect URL port
 	conf.RedirectURL = fmt.Sprintf("http://localhost:%d", port)
 	url := conf.AuthCodeURL(state, authCodeOpts...)
+	slog.Debug("login: opening browser", "redirect_url", conf.RedirectURL, "client_id", conf.ClientID)
 	err = a.OpenBrowser(url)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to open browser: %v", err)
 	}
 
-	code, err := handleCodeResponse(ln, state)
+	code, err := receiveCode(waitCtx, ln, state)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("Login abandoned: %w", ctx.Err())
+	}
+	if waitCtx.Err() != nil {
+		return nil, fmt.Errorf("Login wasn't completed within %v: %w", a.Timeout, waitCtx.Err())
+	}
 	if err != nil {
+		slog.Debug("login: invalid authorization response", "error", err)
 		return nil, fmt.Errorf("Response was invalid: %v", err)
 	}
 
+	slog.Debug("login: exchanging authorization code", "token_url", conf.Endpoint.TokenURL)
 	return conf.Exchange(
-		config.OAuthHTTPContext,
+		ctx,
 		code,
 		oauth2.SetAuthURLParam("code_verifier", verifier))
 }
@@ -143,59 +213,6 @@ func getListener() (net.Listener, int, error) {
 	return ln, ln.Addr().(*net.TCPAddr).Port, nil
 }
 
-func handleCodeResponse(ln net.Listener, stateCheck string) (string, error) {
-	conn, err := ln.Accept()
-	if err != nil {
-		return "", err
-	}
-
-	srvConn := httputil.NewServerConn(conn, nil)
-	defer srvConn.Close()
-
-	req, err := srvConn.Read()
-	if err != nil {
-		return "", err
-	}
-
-	code := req.URL.Query().Get("code")
-	state := req.URL.Query().Get("state")
-
-	resp := &http.Response{
-		StatusCode:    200,
-		Proto:         "HTTP/1.1",
-		ProtoMajor:    1,
-		ProtoMinor:    1,
-		Close:         true,
-		ContentLength: -1, // designates unknown length
-	}
-	defer srvConn.Write(req, resp)
-
-	// If the code couldn't be obtained, inform the user via the browser and
-	// return an error.
-	// TODO i18n?
-	if code == "" {
-		err := fmt.Errorf("Code not present in response: %s", req.URL.String())
-		resp.Body = getResponseBody("ERROR: Authentication code not present in response.")
-		return "", err
-	}
-
-	if state != stateCheck {
-		err := fmt.Errorf("Invalid State")
-		resp.StatusCode = 400
-		resp.Body = getResponseBody("ERROR: State parameter is invalid.")
-		return "", err
-	}
-
-	resp.Body = getResponseBody("Success! You may now close your browser.")
-	return code, nil
-}
-
-// turn a string into an io.ReadCloser as required by an http.Response
-func getResponseBody(body string) io.ReadCloser {
-	reader := strings.NewReader(body)
-	return ioutil.NopCloser(reader)
-}
-
 // generates the values used in "Proof Key for Code Exchange by OAuth Public Clients"
 // https://tools.ietf.org/html/rfc7636
 // https://developers.google.com/identity/protocols/OAuth2InstalledApp#step1-code-verifier
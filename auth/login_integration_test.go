@@ -18,6 +18,7 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -28,6 +29,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
 	"golang.org/x/oauth2"
@@ -37,7 +39,7 @@ import (
 const (
 	// The client ID corresponding to GCR's OAuth2 login page.
 	expectedClientID  = "99426463878-o7n0bshgue20tdpm25q4at0vs2mr4utq.apps.googleusercontent.com"
-	expectedScope     = "https://www.googleapis.com/auth/devstorage.read_write"
+	expectedScope     = "https://www.googleapis.com/auth/devstorage.read_write https://www.googleapis.com/auth/userinfo.email"
 	expectedHost      = "localhost"
 	expectedAuthPath  = "/auth"
 	expectedTokenPath = "/token"
@@ -254,7 +256,7 @@ func TestBrowserAllowed(t *testing.T) {
 	tested := &GCRLoginAgent{
 		OpenBrowser: mockBrowser.Open,
 	}
-	tok, err := tested.PerformLogin()
+	tok, err := tested.PerformLogin(context.Background())
 	if err != nil {
 		t.Fatalf("Login failed: %v", err)
 	}
@@ -305,7 +307,7 @@ func TestStateMismatch(t *testing.T) {
 	tested := &GCRLoginAgent{
 		OpenBrowser: mockBrowser.Open,
 	}
-	_, err = tested.PerformLogin()
+	_, err = tested.PerformLogin(context.Background())
 	if err == nil {
 		t.Fatalf("No error, expected bad state")
 	}
@@ -360,7 +362,7 @@ func TestBrowserAllowed_BrowserOpenFails(t *testing.T) {
 		Out:         mockStdout,
 		OpenBrowser: mockBrowser.Open,
 	}
-	_, err = tested.PerformLogin()
+	_, err = tested.PerformLogin(context.Background())
 	if err == nil {
 		t.Fatalf("Did not throw an error")
 	}
@@ -368,3 +370,64 @@ func TestBrowserAllowed_BrowserOpenFails(t *testing.T) {
 		t.Fatalf("Error doesn't mention the browser, got: %v", err)
 	}
 }
+
+func TestPerformLogin_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The user never completes the login.
+	tested := &GCRLoginAgent{
+		OpenBrowser: func(string) error {
+			cancel()
+			return nil
+		},
+	}
+	_, err := tested.PerformLogin(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the login to be abandoned, got: %v", err)
+	}
+}
+
+func TestPerformLogin_Timeout(t *testing.T) {
+	// The user never completes the login.
+	tested := &GCRLoginAgent{
+		OpenBrowser: func(string) error { return nil },
+		Timeout:     10 * time.Millisecond,
+	}
+	_, err := tested.PerformLogin(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the login to time out, got: %v", err)
+	}
+}
+
+func TestPerformLogin_CustomClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var authURL *url.URL
+	tested := &GCRLoginAgent{
+		OpenBrowser: func(urlStr string) error {
+			defer cancel()
+			var err error
+			authURL, err = url.Parse(urlStr)
+			return err
+		},
+		Client:       config.OAuthClient{ID: "my-client.apps.googleusercontent.com", Secret: "shh"},
+		LoginHint:    "user@example.com",
+		HostedDomain: "example.com",
+	}
+	if _, err := tested.PerformLogin(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the login to be abandoned, got: %v", err)
+	}
+
+	q := authURL.Query()
+	for param, expected := range map[string]string{
+		"client_id":  "my-client.apps.googleusercontent.com",
+		"login_hint": "user@example.com",
+		"hd":         "example.com",
+	} {
+		if got := q.Get(param); got != expected {
+			t.Errorf("Expected %s: %s, got: %s", param, expected, got)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// readHeaderTimeout bounds how long a browser connection may idle before
+	// sending a request to the loopback server.
+	readHeaderTimeout = 10 * time.Second
+	// shutdownTimeout bounds how long the loopback server waits for the
+	// browser to receive its final page.
+	shutdownTimeout = time.Second
+)
+
+// ErrInvalidState is returned if the redirect's state parameter doesn't match
+// the one sent with the authorization request, e.g. a forged redirect.
+var ErrInvalidState = errors.New("Invalid State")
+
+// pageTemplate renders the pages served to the user's browser by the loopback
+// server.
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>docker-credential-gcr</title>
+<style>
+  body { font-family: Roboto, Arial, sans-serif; background: #f8f9fa; color: #202124; }
+  main { max-width: 32em; margin: 15vh auto; padding: 2em; background: #fff; border-radius: 8px; box-shadow: 0 1px 3px rgba(60, 64, 67, .3); }
+  h1 { font-size: 1.4em; font-weight: 400; color: {{if .Failed}}#d93025{{else}}#188038{{end}}; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+</main>
+</body>
+</html>
+`))
+
+// page describes a page served by the loopback server.
+type page struct {
+	Failed  bool
+	Title   string
+	Message string
+}
+
+// codeResult is the outcome of the OAuth2 redirect to the loopback server.
+type codeResult struct {
+	code string
+	err  error
+}
+
+// receiveCode serves HTTP on ln until the browser is redirected to it with an
+// authorization code or an OAuth2 error, or ctx is done. Requests for any path
+// other than the root, e.g. /favicon.ico, and requests without a code or error
+// are answered but otherwise ignored.
+func receiveCode(ctx context.Context, ln net.Listener, state string) (string, error) {
+	results := make(chan codeResult, 1)
+	srv := &http.Server{
+		Handler:           redirectHandler(state, results),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	go srv.Serve(ln)
+	defer func() {
+		// let the browser receive the final page before closing
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			srv.Close()
+		}
+	}()
+
+	select {
+	case r := <-results:
+		return r.code, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// redirectHandler handles the OAuth2 redirect, sending the first outcome to
+// results.
+func redirectHandler(state string, results chan<- codeResult) http.Handler {
+	send := func(r codeResult) {
+		select {
+		case results <- r:
+		default: // only the first outcome is used
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+		q := req.URL.Query()
+		code, oauthErr := q.Get("code"), q.Get("error")
+		switch {
+		case code == "" && oauthErr == "":
+			slog.Debug("login: ignoring request without an authorization response", "url", req.URL.String())
+			writePage(w, http.StatusBadRequest, page{true, "Waiting for sign-in", "This page didn't include a response from Google. Please complete the sign-in in the browser window which was opened for you."})
+		case q.Get("state") != state:
+			writePage(w, http.StatusBadRequest, page{true, "Sign-in failed", "The response from Google was invalid: its state parameter didn't match. Please run the login again."})
+			send(codeResult{err: ErrInvalidState})
+		case oauthErr != "":
+			err := fmt.Errorf("authorization failed: %s", oauthErr)
+			if desc := q.Get("error_description"); desc != "" {
+				err = fmt.Errorf("authorization failed: %s: %s", oauthErr, desc)
+			}
+			msg := "Sign-in wasn't completed. You may close this window and run the login again."
+			if oauthErr == "access_denied" {
+				msg = "Access was denied. You may close this window and run the login again."
+			}
+			writePage(w, http.StatusOK, page{true, "Sign-in failed", msg})
+			send(codeResult{err: err})
+		default:
+			writePage(w, http.StatusOK, page{false, "Signed in", "You're signed in to docker-credential-gcr. You may now close this window."})
+			send(codeResult{code: code})
+		}
+	})
+}
+
+func writePage(w http.ResponseWriter, status int, p page) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := pageTemplate.Execute(w, p); err != nil {
+		slog.Debug("login: unable to write page", "error", err)
+	}
+}
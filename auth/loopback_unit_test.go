@@ -0,0 +1,139 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testState = "st4te"
+
+// startReceiver starts receiveCode on a loopback listener, returning the
+// server's base URL and a channel which receives its outcome.
+func startReceiver(t *testing.T, ctx context.Context) (string, <-chan codeResult) {
+	ln, port, err := getListener()
+	if err != nil {
+		t.Fatalf("Unable to open local listener: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	results := make(chan codeResult, 1)
+	go func() {
+		code, err := receiveCode(ctx, ln, testState)
+		results <- codeResult{code, err}
+	}()
+	return fmt.Sprintf("http://localhost:%d", port), results
+}
+
+// get requests the given URL, returning the status and body.
+func get(t *testing.T, url string) (int, string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Unable to read the response to %s: %v", url, err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func awaitResult(t *testing.T, results <-chan codeResult) codeResult {
+	select {
+	case r := <-results:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("receiveCode didn't return")
+		return codeResult{}
+	}
+}
+
+func TestReceiveCode_IgnoresUnrelatedRequests(t *testing.T) {
+	baseURL, results := startReceiver(t, context.Background())
+
+	if status, _ := get(t, baseURL+"/favicon.ico"); status != http.StatusNotFound {
+		t.Errorf("Expected /favicon.ico to be not found, got: %d", status)
+	}
+	if status, body := get(t, baseURL+"/"); status != http.StatusBadRequest || !strings.Contains(body, "Waiting for sign-in") {
+		t.Errorf("Expected a page asking the user to complete the sign-in, got: %d %s", status, body)
+	}
+	select {
+	case r := <-results:
+		t.Fatalf("Expected unrelated requests to be ignored, got: %+v", r)
+	default:
+	}
+
+	status, body := get(t, baseURL+"/?code=c0de&state="+testState)
+	if status != http.StatusOK || !strings.Contains(body, "Signed in") {
+		t.Errorf("Expected the success page, got: %d %s", status, body)
+	}
+	if r := awaitResult(t, results); r.err != nil || r.code != "c0de" {
+		t.Errorf("Expected the authorization code, got: %+v", r)
+	}
+}
+
+func TestReceiveCode_OAuthError(t *testing.T) {
+	baseURL, results := startReceiver(t, context.Background())
+
+	status, body := get(t, baseURL+"/?error=access_denied&error_description=%3Cb%3Enope%3C%2Fb%3E&state="+testState)
+
+	if status != http.StatusOK || !strings.Contains(body, "Access was denied") {
+		t.Errorf("Expected the access denied page, got: %d %s", status, body)
+	}
+	r := awaitResult(t, results)
+	if r.err == nil || !strings.Contains(r.err.Error(), "access_denied: <b>nope</b>") {
+		t.Errorf("Expected the OAuth2 error and its description, got: %+v", r)
+	}
+}
+
+func TestReceiveCode_InvalidState(t *testing.T) {
+	baseURL, results := startReceiver(t, context.Background())
+
+	status, body := get(t, baseURL+"/?code=c0de&state=forged")
+
+	if status != http.StatusBadRequest || !strings.Contains(body, "Sign-in failed") {
+		t.Errorf("Expected the failure page, got: %d %s", status, body)
+	}
+	if r := awaitResult(t, results); !errors.Is(r.err, ErrInvalidState) {
+		t.Errorf("Expected ErrInvalidState, got: %+v", r)
+	}
+}
+
+func TestReceiveCode_Timeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, results := startReceiver(t, ctx)
+
+	if r := awaitResult(t, results); !errors.Is(r.err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to be exceeded, got: %+v", r)
+	}
+}
+
+func TestWritePage_EscapesMessage(t *testing.T) {
+	var b strings.Builder
+	if err := pageTemplate.Execute(&b, page{true, "Sign-in failed", "<script>alert(1)</script>"}); err != nil {
+		t.Fatalf("Unable to render page: %v", err)
+	}
+	if strings.Contains(b.String(), "<script>") {
+		t.Errorf("Expected the message to be escaped, got: %s", b.String())
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"golang.org/x/oauth2"
+)
+
+// ErrTokenInvalid is returned by RevokeToken if the token had already expired
+// or been revoked.
+var ErrTokenInvalid = errors.New("the token was already invalid")
+
+// RevokeToken revokes the given refresh (or access) token at
+// config.GCRRevokeURL. Revoking a refresh token also revokes any access tokens
+// issued from it.
+func RevokeToken(ctx context.Context, token string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.GCRRevokeURL,
+		strings.NewReader(url.Values{"token": {token}}.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	slog.Debug("revoking token", "revoke_url", config.GCRRevokeURL)
+	resp, err := oauth2.NewClient(ctx, nil).Do(req)
+	if err != nil {
+		return fmt.Errorf("revocation request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var e struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	json.Unmarshal(body, &e)
+	if e.Error == "invalid_token" {
+		return ErrTokenInvalid
+	}
+	if e.ErrorDescription != "" {
+		return fmt.Errorf("revocation failed: %s: %s", resp.Status, e.ErrorDescription)
+	}
+	return fmt.Errorf("revocation failed: %s", resp.Status)
+}
@@ -0,0 +1,76 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+)
+
+// testRefreshToken is the refresh token revoked by the tests.
+const testRefreshToken = "refreshplz"
+
+func setUpRevoke(t *testing.T, handler http.HandlerFunc) {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	orig := config.GCRRevokeURL
+	config.GCRRevokeURL = srv.URL
+	t.Cleanup(func() { config.GCRRevokeURL = orig })
+}
+
+func TestRevokeToken(t *testing.T) {
+	revoked := false
+	setUpRevoke(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Expected a POST, got: %s", r.Method)
+		}
+		if got := r.PostFormValue("token"); got != testRefreshToken {
+			t.Errorf("Expected token %q, got: %q", testRefreshToken, got)
+		}
+		revoked = true
+	})
+
+	if err := RevokeToken(context.Background(), testRefreshToken); err != nil {
+		t.Fatalf("RevokeToken returned an error: %v", err)
+	}
+	if !revoked {
+		t.Error("Expected the revocation endpoint to be called")
+	}
+}
+
+func TestRevokeToken_AlreadyInvalid(t *testing.T) {
+	setUpRevoke(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_token","error_description":"Token expired or revoked"}`))
+	})
+
+	if err := RevokeToken(context.Background(), testRefreshToken); err != ErrTokenInvalid {
+		t.Fatalf("Expected ErrTokenInvalid, got: %v", err)
+	}
+}
+
+func TestRevokeToken_ServerError(t *testing.T) {
+	setUpRevoke(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	if err := RevokeToken(context.Background(), testRefreshToken); err == nil || err == ErrTokenInvalid {
+		t.Fatalf("Expected a revocation failure, got: %v", err)
+	}
+}
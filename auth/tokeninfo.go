@@ -0,0 +1,99 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"golang.org/x/oauth2"
+)
+
+// TokenInfo describes an access token, as reported by the tokeninfo endpoint.
+type TokenInfo struct {
+	// Email is the account the token was issued to. It's only present if the
+	// token was granted an email scope or belongs to a service account.
+	Email string
+	// Scopes are the OAuth2 scopes granted to the token.
+	Scopes []string
+	// Expiry is when the token expires.
+	Expiry time.Time
+}
+
+// GetTokenInfo looks up the given access token at config.GCRTokenInfoURL.
+func GetTokenInfo(ctx context.Context, accessToken string) (*TokenInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.GCRTokenInfoURL,
+		strings.NewReader(url.Values{"access_token": {accessToken}}.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := oauth2.NewClient(ctx, nil).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("tokeninfo request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read tokeninfo response: %v", err)
+	}
+
+	var info struct {
+		Email            string `json:"email"`
+		Scope            string `json:"scope"`
+		Exp              string `json:"exp"`
+		ExpiresIn        string `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("unable to parse tokeninfo response (%s): %v", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if info.ErrorDescription != "" {
+			return nil, fmt.Errorf("tokeninfo rejected the token: %s", info.ErrorDescription)
+		}
+		return nil, fmt.Errorf("tokeninfo rejected the token: %s", resp.Status)
+	}
+
+	ret := &TokenInfo{Email: info.Email, Scopes: strings.Fields(info.Scope)}
+	if exp, err := strconv.ParseInt(info.Exp, 10, 64); err == nil {
+		ret.Expiry = time.Unix(exp, 0)
+	} else if expiresIn, err := strconv.ParseInt(info.ExpiresIn, 10, 64); err == nil {
+		ret.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	return ret, nil
+}
+
+// LookupAccount returns the account which the given token was issued to, or
+// "" if it can't be determined.
+func LookupAccount(ctx context.Context, tok *oauth2.Token) string {
+	info, err := GetTokenInfo(ctx, tok.AccessToken)
+	if err != nil {
+		slog.Warn("unable to determine the signed-in account", "error", err)
+		return ""
+	}
+	return info.Email
+}
@@ -0,0 +1,86 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+)
+
+func setUpTokenInfo(t *testing.T, handler http.HandlerFunc) {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	orig := config.GCRTokenInfoURL
+	config.GCRTokenInfoURL = srv.URL
+	t.Cleanup(func() { config.GCRTokenInfoURL = orig })
+}
+
+func TestGetTokenInfo(t *testing.T) {
+	setUpTokenInfo(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.PostFormValue("access_token"); got != "t0k3n" {
+			t.Errorf("Expected access_token \"t0k3n\", got: %q", got)
+		}
+		w.Write([]byte(`{"email":"user@example.com","scope":"https://www.googleapis.com/auth/devstorage.read_write https://www.googleapis.com/auth/userinfo.email","exp":"1893456000","expires_in":"3599"}`))
+	})
+
+	info, err := GetTokenInfo(context.Background(), "t0k3n")
+	if err != nil {
+		t.Fatalf("GetTokenInfo returned an error: %v", err)
+	}
+	if info.Email != "user@example.com" {
+		t.Errorf("Expected email \"user@example.com\", got: %q", info.Email)
+	}
+	expectedScopes := []string{"https://www.googleapis.com/auth/devstorage.read_write", emailScope}
+	if !reflect.DeepEqual(info.Scopes, expectedScopes) {
+		t.Errorf("Expected scopes %v, got: %v", expectedScopes, info.Scopes)
+	}
+	if !info.Expiry.Equal(time.Unix(1893456000, 0)) {
+		t.Errorf("Unexpected expiry: %v", info.Expiry)
+	}
+}
+
+func TestGetTokenInfo_Invalid(t *testing.T) {
+	setUpTokenInfo(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_token","error_description":"Invalid Value"}`))
+	})
+
+	_, err := GetTokenInfo(context.Background(), "expired")
+	if err == nil || !strings.Contains(err.Error(), "Invalid Value") {
+		t.Fatalf("Expected an invalid token error, got: %v", err)
+	}
+}
+
+func TestLoginScopes(t *testing.T) {
+	a := &GCRLoginAgent{Scopes: []string{"a"}}
+	if got := a.scopes(); !reflect.DeepEqual(got, []string{"a", emailScope}) {
+		t.Errorf("Expected the email scope to be added, got: %v", got)
+	}
+	a = &GCRLoginAgent{Scopes: []string{emailScope, "a"}}
+	if got := a.scopes(); !reflect.DeepEqual(got, []string{emailScope, "a"}) {
+		t.Errorf("Expected the scopes to be unchanged, got: %v", got)
+	}
+	a = &GCRLoginAgent{Scopes: []string{"a"}, ScopesPinned: true}
+	if got := a.scopes(); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Expected pinned scopes not to be widened, got: %v", got)
+	}
+}
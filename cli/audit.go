@@ -0,0 +1,93 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/audit"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/google/subcommands"
+)
+
+type auditCmd struct {
+	cmd
+}
+
+// NewAuditSubcommand returns a subcommands.Command which reads the audit log
+// of issued credentials.
+func NewAuditSubcommand() subcommands.Command {
+	return &auditCmd{
+		cmd{
+			name:     "audit",
+			synopsis: "read the audit log of issued credentials",
+		},
+	}
+}
+
+// Usage returns the usage of the audit command and its actions.
+func (c *auditCmd) Usage() string {
+	return fmt.Sprintf("%s: %s\n  %s tail [-n lines]\n", c.Name(), c.Synopsis(), c.Name())
+}
+
+func (c *auditCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	args := f.Args()
+	if len(args) == 0 || args[0] != "tail" {
+		fmt.Fprint(os.Stderr, c.Usage())
+		return subcommands.ExitUsageError
+	}
+
+	tailFlags := flag.NewFlagSet("audit tail", flag.ContinueOnError)
+	n := tailFlags.Int("n", 10, "the number of records to print")
+	if err := tailFlags.Parse(args[1:]); err != nil {
+		return subcommands.ExitUsageError
+	}
+	if *n < 0 {
+		fmt.Fprintf(os.Stderr, "-n must not be negative: %d\n", *n)
+		fmt.Fprint(os.Stderr, c.Usage())
+		return subcommands.ExitUsageError
+	}
+
+	if err := tailAuditLog(os.Stdout, *n); err != nil {
+		fmt.Fprintf(os.Stderr, "Failure: %v\n", err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// tailAuditLog prints the last n records of the configured audit log.
+func tailAuditLog(out io.Writer, n int) error {
+	cfg, err := config.LoadUserConfig()
+	if err != nil {
+		return err
+	}
+	path := cfg.AuditLog()
+	if path == "" {
+		return errors.New("the audit log is disabled, see `config --show` for the AuditLog setting")
+	}
+	lines, err := (&audit.Log{Path: path}).Tail(n)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		fmt.Fprintln(out, line)
+	}
+	return nil
+}
@@ -15,44 +15,287 @@
 package cli
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/util"
+	cliconfig "github.com/docker/cli/cli/config"
 	"github.com/google/subcommands"
 )
 
 type clearCmd struct {
 	cmd
+	// remove the stored credentials
+	credentials bool
+	// remove the user config
+	config bool
+	// remove the Docker config entries which refer to this helper
+	dockerConfig bool
+	// remove everything
+	all bool
+	// don't prompt for confirmation
+	yes bool
+	// skip revoking the refresh token
+	localOnly bool
+
+	// Read confirmations from here; if nil, uses os.Stdin.
+	in *bufio.Reader
+	// Whether in is attached to a user; if in is nil, whether the process
+	// has a terminal, as for reauthentication.
+	interactive bool
+	// Write prompts to here; if nil, uses os.Stdout.
+	out io.Writer
 }
 
-// NewClearSubcommand returns a subcommands.Command which removes all stored
-// credentials.
+// NewClearSubcommand returns a subcommands.Command which removes the
+// credentials, config and Docker config entries managed by the helper.
 func NewClearSubcommand() subcommands.Command {
 	return &clearCmd{
-		cmd{
+		cmd: cmd{
 			name:     "clear",
-			synopsis: "remove all stored credentials",
+			synopsis: "remove stored credentials and, optionally, all other state managed by the helper",
 		},
 	}
 }
 
-func (c *clearCmd) Execute(context.Context, *flag.FlagSet, ...interface{}) subcommands.ExitStatus {
-	if err := c.ClearAll(); err != nil {
+// Usage returns the usage of the clear command.
+func (c *clearCmd) Usage() string {
+	return fmt.Sprintf(`%s: %s
+  %s [--credentials] [--config] [--docker-config] [--all] [--yes] [--local-only]
+
+If no scope is given, only the stored credentials are removed. Without a
+terminal to confirm with, --yes is required.
+`, c.Name(), c.Synopsis(), c.Name())
+}
+
+func (c *clearCmd) SetFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.credentials, "credentials", false, "remove the credentials stored by gcr-login, revoking the refresh token")
+	fs.BoolVar(&c.config, "config", false, "remove the user config")
+	fs.BoolVar(&c.dockerConfig, "docker-config", false, "remove the Docker config entries which use this helper")
+	fs.BoolVar(&c.all, "all", false, "remove all of the above")
+	fs.BoolVar(&c.yes, "yes", false, "don't prompt for confirmation")
+	fs.BoolVar(&c.localOnly, localOnlyFlag, false, "only delete the stored credentials, without revoking the refresh token")
+}
+
+func (c *clearCmd) Execute(ctx context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if err := c.ClearAll(ctx); err != nil {
+		slog.Error("clear: failed", "error", err)
 		fmt.Fprintf(os.Stderr, "failure: %v\n", err)
 		return subcommands.ExitFailure
 	}
+	slog.Info("clear: succeeded")
 	return subcommands.ExitSuccess
 }
 
-// ClearAll removes all credentials from the store (GCR or otherwise).
-func (c *clearCmd) ClearAll() error {
-	s, err := store.DefaultGCRCredStore()
+// ClearAll removes each of the artifacts selected by the command's flags,
+// asking for confirmation before removing anything unless --yes was given.
+func (c *clearCmd) ClearAll(ctx context.Context) error {
+	if c.in == nil {
+		c.in = bufio.NewReader(os.Stdin)
+		c.interactive = util.HasTerminal()
+	}
+	if c.out == nil {
+		c.out = os.Stdout
+	}
+	if c.all {
+		c.credentials, c.config, c.dockerConfig = true, true, true
+	}
+	if !c.credentials && !c.config && !c.dockerConfig {
+		c.credentials = true
+	}
+
+	// Read before the user config may be removed.
+	var delegate string
+	if c.dockerConfig {
+		delegate = credsStoreDelegate()
+	}
+
+	if c.credentials {
+		if err := c.clearCredentials(ctx); err != nil {
+			return fmt.Errorf("unable to remove the stored credentials: %v", err)
+		}
+	}
+	if c.config {
+		if err := c.clearConfig(); err != nil {
+			return fmt.Errorf("unable to remove the user config: %v", err)
+		}
+	}
+	if c.dockerConfig {
+		if err := c.clearDockerConfig(delegate); err != nil {
+			return fmt.Errorf("unable to update the Docker config: %v", err)
+		}
+	}
+	return nil
+}
+
+// clearCredentials revokes and deletes the stored GCR credentials, then
+// removes the credential store and its encryption key.
+func (c *clearCmd) clearCredentials(ctx context.Context) error {
+	path, err := store.DefaultCredStorePath()
 	if err != nil {
 		return err
 	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		fmt.Fprintln(c.out, "No stored credentials.")
+		// Files left behind by a store removed some other way hold nothing
+		// without it, so they're removed without asking.
+		return removeStoreFiles(c.out, path)
+	}
+	if ok, err := c.confirm("Remove the stored credentials in %s?", path); !ok || err != nil {
+		return err
+	}
+	if err := deleteGCRAuth(ctx, store.NewGCRCredStore(path), c.localOnly); err != nil {
+		return err
+	}
+	if err := removeFile(c.out, path); err != nil {
+		return err
+	}
+	return removeStoreFiles(c.out, path)
+}
+
+// removeStoreFiles removes the files kept next to the credential store at the
+// given path, if they exist. The encryption key is useless without the store,
+// as is the record of the metadata server's availability.
+func removeStoreFiles(out io.Writer, path string) error {
+	for _, p := range []string{store.EncryptionKeyPath(path), store.MetadataUnavailablePath(path)} {
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		if err := removeFile(out, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearConfig removes the user config. The config isn't loaded first, so that
+// an invalid config can be removed too.
+func (c *clearCmd) clearConfig() error {
+	path, err := config.UserConfigPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		fmt.Fprintln(c.out, "No user config.")
+		return nil
+	}
+	if ok, err := c.confirm("Remove the user config in %s?", path); !ok || err != nil {
+		return err
+	}
+	return removeFile(c.out, path)
+}
+
+// clearDockerConfig removes the Docker config's credHelpers and credsStore
+// entries which refer to this helper. If this helper is the credsStore, the
+// given credsStore it delegates to, if any, is restored.
+func (c *clearCmd) clearDockerConfig(delegate string) error {
+	dockerConfig, err := cliconfig.Load("")
+	if err != nil {
+		return err
+	}
+	suffix := helperSuffix()
+	var registries []string
+	for registry, helper := range dockerConfig.CredentialHelpers {
+		if helper == suffix {
+			registries = append(registries, registry)
+		}
+	}
+	credsStore := dockerConfig.CredentialsStore == suffix
+	if len(registries) == 0 && !credsStore {
+		fmt.Fprintf(c.out, "No entries in %s use %s%s.\n", dockerConfig.Filename, credHelperPrefix, suffix)
+		return nil
+	}
+	entries := len(registries)
+	if credsStore {
+		entries++
+	}
+	if ok, err := c.confirm("Remove the %d entries in %s which use %s%s?", entries, dockerConfig.Filename, credHelperPrefix, suffix); !ok || err != nil {
+		return err
+	}
+
+	for _, registry := range registries {
+		delete(dockerConfig.CredentialHelpers, registry)
+	}
+	if credsStore {
+		dockerConfig.CredentialsStore = delegate
+		if delegate != "" {
+			fmt.Fprintf(c.out, "Restored %s%s as the credsStore.\n", credHelperPrefix, delegate)
+		}
+	}
+	if err := dockerConfig.Save(); err != nil {
+		return err
+	}
+	if credsStore {
+		// Outside of credsStore mode, every registry is served again.
+		if userCfg, err := config.LoadUserConfig(); err == nil {
+			if err := userCfg.SetCredsStore(false, ""); err != nil {
+				return err
+			}
+		}
+	}
+	slog.Info("clear: removed docker config entries", "path", dockerConfig.Filename, "registries", len(registries), "creds_store", credsStore)
+	fmt.Fprintf(c.out, "Updated %s.\n", dockerConfig.Filename)
+	return nil
+}
+
+// credsStoreDelegate returns the configured credsStore delegate, or "" if
+// there's none or the config can't be loaded.
+func credsStoreDelegate() string {
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		slog.Warn("clear: unable to load the config", "error", err)
+		return ""
+	}
+	return userCfg.CredsStoreDelegate()
+}
+
+// confirm asks the user to confirm an action, returning true if they did or
+// if --yes was given. Any answer other than "y" or "yes" declines. Without a
+// user to answer, e.g. in a script, it fails rather than silently skipping the
+// action.
+func (c *clearCmd) confirm(format string, args ...interface{}) (bool, error) {
+	if c.yes {
+		return true, nil
+	}
+	if !c.interactive {
+		return false, errors.New("there's no terminal to confirm with; pass --yes to clear without confirmation")
+	}
+	fmt.Fprintf(c.out, format+" [y/N] ", args...)
+	answer, err := c.in.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(c.out)
+		return false, fmt.Errorf("no answer to confirm with (%v); pass --yes to clear without confirmation", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	fmt.Fprintln(c.out, "Skipped.")
+	return false, nil
+}
+
+func removeFile(out io.Writer, path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	slog.Info("clear: removed file", "path", path)
+	fmt.Fprintf(out, "Removed %s.\n", path)
+	return nil
+}
 
-	return s.DeleteGCRAuth()
+// helperSuffix returns the suffix of this helper's binary name, as used in
+// the Docker config's credHelpers and credsStore entries.
+func helperSuffix() string {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	return strings.TrimPrefix(name, credHelperPrefix)
 }
@@ -0,0 +1,98 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+)
+
+func testClearCmd(input string, interactive bool) (*clearCmd, *bytes.Buffer) {
+	var out bytes.Buffer
+	return &clearCmd{in: bufio.NewReader(strings.NewReader(input)), interactive: interactive, out: &out}, &out
+}
+
+func TestConfirm(t *testing.T) {
+	tested, out := testClearCmd("y\nYes\nn\n\n", true)
+
+	for _, expected := range []bool{true, true, false, false} {
+		if ok, err := tested.confirm("Remove %s?", "it"); ok != expected || err != nil {
+			t.Errorf("Expected %v, got: %v, %v", expected, ok, err)
+		}
+	}
+	if n := strings.Count(out.String(), "Remove it? [y/N] "); n != 4 {
+		t.Errorf("Expected 4 prompts, got: %s", out.String())
+	}
+	if n := strings.Count(out.String(), "Skipped."); n != 2 {
+		t.Errorf("Expected 2 skipped prompts, got: %s", out.String())
+	}
+}
+
+func TestConfirm_EOF(t *testing.T) {
+	tested, _ := testClearCmd("", true)
+
+	if ok, err := tested.confirm("Remove it?"); ok || err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Errorf("Expected an error suggesting --yes, got: %v, %v", ok, err)
+	}
+}
+
+func TestConfirm_NonInteractive(t *testing.T) {
+	tested, out := testClearCmd("y\n", false)
+
+	if ok, err := tested.confirm("Remove it?"); ok || err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Errorf("Expected an error suggesting --yes, got: %v, %v", ok, err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no prompt, got: %s", out.String())
+	}
+
+	tested.yes = true
+	if ok, err := tested.confirm("Remove it?"); !ok || err != nil {
+		t.Errorf("Expected --yes to confirm, got: %v, %v", ok, err)
+	}
+}
+
+func TestClearCredentials_LeftoverFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docker_credentials.json")
+	t.Setenv("DOCKER_CREDENTIAL_GCR_STORE", path)
+	leftovers := []string{store.EncryptionKeyPath(path), store.MetadataUnavailablePath(path)}
+	for _, p := range leftovers {
+		if err := os.WriteFile(p, []byte("x"), 0o600); err != nil {
+			t.Fatalf("Unable to write %s: %v", p, err)
+		}
+	}
+	// Nothing to confirm, there are no credentials.
+	tested, out := testClearCmd("", false)
+
+	if err := tested.clearCredentials(context.Background()); err != nil {
+		t.Fatalf("clearCredentials returned an error: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "No stored credentials.") {
+		t.Errorf("Expected no stored credentials to be reported, got: %s", out.String())
+	}
+	for _, p := range leftovers {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed: %v", p, err)
+		}
+	}
+}
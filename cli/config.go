@@ -19,8 +19,11 @@ import (
 	"encoding/csv"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
 	"github.com/google/subcommands"
@@ -29,12 +32,16 @@ import (
 const (
 	tokenSourceFlag = "token-source"
 	resetAllFlag    = "unset-all"
+	showFlag        = "show"
+	originFlag      = "origin"
 )
 
 type configCmd struct {
 	cmd
 	tokenSources string
 	resetAll     bool
+	show         bool
+	origin       bool
 }
 
 // NewConfigSubcommand returns a subcommands.Command which allows for user
@@ -49,6 +56,8 @@ func NewConfigSubcommand() subcommands.Command {
 		// these values will always be explicitly set by the user if visited.
 		"unused",
 		false,
+		false,
+		false,
 	}
 }
 
@@ -59,8 +68,10 @@ func (c *configCmd) SetFlags(fs *flag.FlagSet) {
 	}
 	supportedSources := strings.Join(srcs, ", ")
 	defaultSources := strings.Join(config.DefaultTokenSources[:], ", ")
-	fs.StringVar(&c.tokenSources, tokenSourceFlag, defaultSources, "The source(s), in order, to search for credentials. Supported sources are: "+supportedSources)
+	fs.StringVar(&c.tokenSources, tokenSourceFlag, defaultSources, "The source(s), in order, to search for credentials. Supported sources are: "+supportedSources+", and exec:<name> for each configured ExecSources program")
 	fs.BoolVar(&c.resetAll, resetAllFlag, false, "Resets all settings to default")
+	fs.BoolVar(&c.show, showFlag, false, "Prints the effective settings and where each came from")
+	fs.BoolVar(&c.origin, originFlag, false, "With --show, also prints the values overridden in lower-precedence layers")
 }
 
 func (c *configCmd) Execute(_ context.Context, flags *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -73,6 +84,14 @@ func (c *configCmd) Execute(_ context.Context, flags *flag.FlagSet, _ ...interfa
 		return subcommands.ExitSuccess
 	}
 
+	if c.show {
+		if err := showSettings(os.Stdout, c.origin); err != nil {
+			printError(showFlag, err)
+			return subcommands.ExitFailure
+		}
+		return subcommands.ExitSuccess
+	}
+
 	result := subcommands.ExitSuccess
 	flags.Visit(func(f *flag.Flag) {
 		if f.Name == tokenSourceFlag {
@@ -97,6 +116,27 @@ func resetAll() error {
 	return cfg.ResetAll()
 }
 
+// showSettings prints the effective settings. If showShadowed is set, the
+// values overridden by each setting's origin are printed beneath it, in order
+// of precedence.
+func showSettings(out io.Writer, showShadowed bool) error {
+	cfg, err := config.LoadUserConfig()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tVALUE\tORIGIN")
+	for _, s := range cfg.Settings() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, s.Value, s.Origin)
+		if showShadowed {
+			for _, shadowed := range s.Shadowed {
+				fmt.Fprintf(w, "  (overrides)\t%s\t%s\n", shadowed.Value, shadowed.Origin)
+			}
+		}
+	}
+	return w.Flush()
+}
+
 func setTokenSources(rawSource string) error {
 	cfg, err := config.LoadUserConfig()
 	if err != nil {
@@ -118,5 +158,6 @@ func printSuccess(msg string) {
 }
 
 func printError(flag string, err error) {
+	slog.Error("config: failed", "flag", flag, "error", err)
 	fmt.Fprintf(os.Stderr, "Failure: %s: %v\n", flag, err)
 }
@@ -19,6 +19,7 @@ import (
 	"encoding/csv"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -38,6 +39,8 @@ type dockerConfigCmd struct {
 	registries string
 	// whether to include all AR Registries
 	includeArtifactRegistry bool
+	// whether to install the cred helper as the global credsStore instead
+	asCredsStore bool
 }
 
 // see https://github.com/docker/docker/blob/master/cliconfig/credentials/native_store.go
@@ -54,6 +57,7 @@ func NewDockerConfigSubcommand() subcommands.Command {
 		false,
 		"unused",
 		false,
+		false,
 	}
 }
 
@@ -61,6 +65,7 @@ func (c *dockerConfigCmd) SetFlags(fs *flag.FlagSet) {
 	fs.BoolVar(&c.overwrite, "overwrite", false, "overwrite any previously configured credential store and/or credentials")
 	fs.BoolVar(&c.includeArtifactRegistry, "include-artifact-registry", false, "include all Artifact Registry registries as well as GCR registries ")
 	fs.StringVar(&c.registries, "registries", "", "the comma-separated list of registries to configure the cred helper for")
+	fs.BoolVar(&c.asCredsStore, "as-creds-store", false, "install the cred helper as the global credsStore, delegating registries other than Google's to the previous credsStore")
 }
 
 func (c *dockerConfigCmd) Execute(context.Context, *flag.FlagSet, ...interface{}) subcommands.ExitStatus {
@@ -86,9 +91,61 @@ func (c *dockerConfigCmd) Execute(context.Context, *flag.FlagSet, ...interface{}
 	// binary.
 	credHelperSuffix := binaryName[len(credHelperPrefix):]
 
+	if c.asCredsStore {
+		if c.registries != "" || c.includeArtifactRegistry {
+			printErrorln("--as-creds-store serves all of Google's registries; it can't be combined with --registries or --include-artifact-registry")
+			return subcommands.ExitFailure
+		}
+		userCfg, err := config.LoadUserConfig()
+		if err != nil {
+			printErrorln("Unable to load the config: %v", err)
+			return subcommands.ExitFailure
+		}
+		return c.setCredsStore(dockerConfig, userCfg, credHelperSuffix)
+	}
 	return c.setConfig(dockerConfig, credHelperSuffix)
 }
 
+// setCredsStore installs the credential helper as Docker's global credsStore,
+// recording the credsStore it replaces, if any, as the delegate for other
+// registries. The credHelpers entries which use the credential helper are
+// removed, since they're redundant.
+func (c *dockerConfigCmd) setCredsStore(dockerConfig *configfile.ConfigFile, userCfg config.UserConfig, helperSuffix string) subcommands.ExitStatus {
+	delegate := dockerConfig.CredentialsStore
+	if delegate == helperSuffix {
+		// Already installed: keep the original delegate.
+		delegate = userCfg.CredsStoreDelegate()
+	}
+	if err := userCfg.SetCredsStore(true, delegate); err != nil {
+		printErrorln("Unable to save the config: %v", err)
+		return subcommands.ExitFailure
+	}
+
+	dockerConfig.CredentialsStore = helperSuffix
+	removed := 0
+	for registry, helper := range dockerConfig.CredentialHelpers {
+		if helper == helperSuffix {
+			delete(dockerConfig.CredentialHelpers, registry)
+			removed++
+		}
+	}
+	if err := dockerConfig.Save(); err != nil {
+		printErrorln("Unable to save docker config: %v", err)
+		return subcommands.ExitFailure
+	}
+	slog.Info("configure-docker: installed as credsStore", "path", dockerConfig.Filename, "delegate", delegate, "removed_cred_helpers", removed)
+
+	if removed > 0 {
+		fmt.Printf("Removed %d credHelpers entries which are no longer needed.\n", removed)
+	}
+	if delegate == "" {
+		fmt.Printf("%s configured to use this credential helper for all registries; it serves Google's registries and no others\n", dockerConfig.Filename)
+	} else {
+		fmt.Printf("%s configured to use this credential helper for all registries; it serves Google's registries and delegates the others to %s%s\n", dockerConfig.Filename, credHelperPrefix, delegate)
+	}
+	return subcommands.ExitSuccess
+}
+
 // Configure Docker to use the credential helper for GCR's registries only.
 // Defining additional 'auths' entries is unnecessary in versions which
 // support registry-specific credential helpers.
@@ -131,6 +188,7 @@ func (c *dockerConfigCmd) setConfig(dockerConfig *configfile.ConfigFile, helperS
 		printErrorln("Unable to save docker config: %v", err)
 		return subcommands.ExitFailure
 	}
+	slog.Info("configure-docker: saved docker config", "path", dockerConfig.Filename, "registries", len(registries))
 
 	if c.includeArtifactRegistry {
 		fmt.Printf("%s configured to use this credential helper for GCR and AR registries\n", dockerConfig.Filename)
@@ -141,5 +199,6 @@ func (c *dockerConfigCmd) setConfig(dockerConfig *configfile.ConfigFile, helperS
 }
 
 func printErrorln(fmtString string, v ...interface{}) {
+	slog.Error("configure-docker: failed", "error", fmt.Sprintf(fmtString, v...))
 	fmt.Fprintf(os.Stderr, "ERROR: "+fmtString+"\n", v...)
 }
@@ -18,6 +18,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
@@ -31,19 +32,21 @@ type helperCmd struct {
 	cmd
 }
 
-func (*helperCmd) Execute(context.Context, *flag.FlagSet, ...interface{}) subcommands.ExitStatus {
+func (*helperCmd) Execute(ctx context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
 	store, err := store.DefaultGCRCredStore()
 	if err != nil {
+		slog.Error("unable to open the credential store", "error", err)
 		fmt.Fprintf(os.Stderr, "Failure: %v\n", err)
 		return subcommands.ExitFailure
 	}
 	userCfg, err := config.LoadUserConfig()
 	if err != nil {
+		slog.Error("unable to load the config", "error", err)
 		fmt.Fprintf(os.Stderr, "Failure: %v\n", err)
 		return subcommands.ExitFailure
 	}
 
-	credentials.Serve(credhelper.NewGCRCredentialHelper(store, userCfg))
+	credentials.Serve(credhelper.NewGCRCredentialHelper(ctx, store, userCfg))
 	return subcommands.ExitSuccess
 }
 
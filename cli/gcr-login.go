@@ -16,32 +16,59 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/auth"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/credhelper"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/gcloud"
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
 	"github.com/google/subcommands"
+	"golang.org/x/oauth2"
 )
 
 type loginCmd struct {
 	cmd
+	// overrides for the configured OAuth2 client and login parameters
+	clientID, clientSecret, clientSecretsFile string
+	loginHint, hostedDomain                   string
+	// credentials to import instead of signing in
+	keyFile, credFile string
+	fromGcloud        bool
+	// the gcloud account to import, "" for gcloud's active account
+	account string
 }
 
 // NewGCRLoginSubcommand returns a subcommands.Command which implements the GCR
 // login operation.
 func NewGCRLoginSubcommand() subcommands.Command {
 	return &loginCmd{
-		cmd{
+		cmd: cmd{
 			name:     "gcr-login",
 			synopsis: "log in to GCR",
 		},
 	}
 }
 
-func (c *loginCmd) Execute(context.Context, *flag.FlagSet, ...interface{}) subcommands.ExitStatus {
-	if err := c.GCRLogin(); err != nil {
+func (c *loginCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.clientID, "client-id", "", "the ID of the OAuth2 client to sign in with, instead of the configured OAuthClientId")
+	fs.StringVar(&c.clientSecret, "client-secret", "", "the secret of the OAuth2 client given by --client-id")
+	fs.StringVar(&c.clientSecretsFile, "client-secrets-file", "", "the path of a client_secrets.json file for the OAuth2 client to sign in with, instead of the configured OAuthClientSecretsFile")
+	fs.StringVar(&c.loginHint, "login-hint", "", "the account, e.g. an email address, to suggest to the user")
+	fs.StringVar(&c.hostedDomain, "hosted-domain", "", "the Google Workspace domain whose accounts are offered to the user")
+	fs.StringVar(&c.keyFile, "key-file", "", "the path of a service account key to import into the store, instead of signing in")
+	fs.StringVar(&c.credFile, "cred-file", "", "the path of an external account (workload identity federation) credential config to import into the store, instead of signing in")
+	fs.BoolVar(&c.fromGcloud, "from-gcloud", false, "import gcloud's credentials for a user account into the store, instead of signing in")
+	fs.StringVar(&c.account, "account", "", "the gcloud account to import with --from-gcloud, instead of gcloud's active account")
+}
+
+func (c *loginCmd) Execute(ctx context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if err := c.GCRLogin(ctx); err != nil {
+		slog.Error("gcr-login: failed", "error", err)
 		fmt.Fprintf(os.Stderr, "Login failure: %v\n", err)
 		return subcommands.ExitFailure
 	}
@@ -50,21 +77,150 @@ func (c *loginCmd) Execute(context.Context, *flag.FlagSet, ...interface{}) subco
 
 // GCRLogin performs the actions necessary to generate a GCR access token
 // and persist it for later use.
-func (c *loginCmd) GCRLogin() error {
-	loginAgent := &auth.GCRLoginAgent{}
+func (c *loginCmd) GCRLogin(ctx context.Context) error {
+	if c.account != "" && !c.fromGcloud {
+		return errors.New("--account requires --from-gcloud")
+	}
+	if c.fromGcloud {
+		return c.importGcloudCredential()
+	}
+	if c.keyFile != "" || c.credFile != "" {
+		return c.importCredential(ctx)
+	}
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return err
+	}
+	client, err := c.oauthClient(userCfg)
+	if err != nil {
+		return fmt.Errorf("unable to load the OAuth2 client: %v", err)
+	}
+	loginAgent := &auth.GCRLoginAgent{
+		Scopes:       userCfg.Scopes(),
+		ScopesPinned: userCfg.ScopesPinned(),
+		Client:       client,
+		LoginHint:    firstNonEmpty(c.loginHint, userCfg.LoginHint()),
+		HostedDomain: firstNonEmpty(c.hostedDomain, userCfg.HostedDomain()),
+	}
 	s, err := store.DefaultGCRCredStore()
 	if err != nil {
 		return err
 	}
 
-	tok, err := loginAgent.PerformLogin()
+	tok, err := loginAgent.PerformLogin(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to authenticate user: %v", err)
 	}
 
-	if err = s.SetGCRAuth(tok); err != nil {
+	account := auth.LookupAccount(ctx, tok)
+	if err = s.SetGCRAuth(tok, account, client); err != nil {
 		return fmt.Errorf("unable to persist access token: %v", err)
 	}
 
+	slog.Info("gcr-login: succeeded", "account", account, "client_id", client.ID, "expiry", tok.Expiry)
+	return nil
+}
+
+// importCredential imports the service account key or external account
+// credential config given by the flags into the store, in place of any
+// signed-in user. The user's refresh token is only deleted locally, since it
+// may be used elsewhere; `gcr-logout` first to revoke it.
+func (c *loginCmd) importCredential(ctx context.Context) error {
+	if c.keyFile != "" && c.credFile != "" {
+		return errors.New("--key-file and --cred-file are mutually exclusive")
+	}
+	if c.clientID != "" || c.clientSecret != "" || c.clientSecretsFile != "" || c.loginHint != "" || c.hostedDomain != "" {
+		return errors.New("the OAuth2 client and sign-in flags can't be combined with --key-file or --cred-file")
+	}
+	path, credType := c.keyFile, store.ServiceAccountCredential
+	if c.credFile != "" {
+		path, credType = c.credFile, store.ExternalAccountCredential
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	cred, err := credhelper.ParseCredential(data, credType)
+	if err != nil {
+		return fmt.Errorf("unable to import %s: %v", path, err)
+	}
+	s, err := store.DefaultGCRCredStore()
+	if err != nil {
+		return err
+	}
+	if err := deleteGCRAuth(ctx, s, true); err != nil {
+		return fmt.Errorf("unable to replace the stored credentials: %v", err)
+	}
+	if err := s.SetImportedCredential(cred); err != nil {
+		return fmt.Errorf("unable to persist the credential: %v", err)
+	}
+	slog.Info("gcr-login: imported credential", "path", path, "type", cred.Type, "principal", cred.Principal)
+	principal := cred.Principal
+	if principal == "" {
+		principal = "an unknown principal"
+	}
+	fmt.Printf("Imported the %s credential for %s from %s.\n", cred.Type, principal, path)
+	return nil
+}
+
+// importGcloudCredential stores the refresh token which gcloud stores for the
+// selected user account, along with the OAuth2 client which issued it, in
+// place of signing in.
+func (c *loginCmd) importGcloudCredential() error {
+	if c.keyFile != "" || c.credFile != "" {
+		return errors.New("--from-gcloud can't be combined with --key-file or --cred-file")
+	}
+	if c.clientID != "" || c.clientSecret != "" || c.clientSecretsFile != "" || c.loginHint != "" || c.hostedDomain != "" {
+		return errors.New("the OAuth2 client and sign-in flags can't be combined with --from-gcloud")
+	}
+	cfg, err := gcloud.DefaultConfig()
+	if err != nil {
+		return fmt.Errorf("unable to locate the gcloud config directory: %v", err)
+	}
+	account, err := cfg.ResolveAccount("", c.account)
+	if err != nil {
+		return err
+	}
+	cred, err := cfg.UserCredential(account)
+	if err != nil {
+		return fmt.Errorf("unable to read gcloud's credentials from %s: %v", cfg.Dir, err)
+	}
+	s, err := store.DefaultGCRCredStore()
+	if err != nil {
+		return err
+	}
+	// The access token is obtained with the refresh token on first use.
+	tok := &oauth2.Token{RefreshToken: cred.RefreshToken}
+	client := config.OAuthClient{ID: cred.ClientID, Secret: cred.ClientSecret}
+	if err := s.SetGCRAuth(tok, account, client); err != nil {
+		return fmt.Errorf("unable to persist the refresh token: %v", err)
+	}
+	slog.Info("gcr-login: imported gcloud credentials", "account", account, "client_id", client.ID, "dir", cfg.Dir)
+	fmt.Printf("Imported gcloud's credentials for %s.\n", account)
 	return nil
 }
+
+// oauthClient returns the OAuth2 client to sign in with: that given by the
+// flags, if any, else the configured one.
+func (c *loginCmd) oauthClient(userCfg config.UserConfig) (config.OAuthClient, error) {
+	switch {
+	case c.clientSecretsFile != "" && c.clientID != "":
+		return config.OAuthClient{}, errors.New("--client-id and --client-secrets-file are mutually exclusive")
+	case c.clientSecretsFile != "":
+		return config.LoadClientSecrets(c.clientSecretsFile)
+	case c.clientID != "":
+		return config.OAuthClient{ID: c.clientID, Secret: c.clientSecret}, nil
+	case c.clientSecret != "":
+		return config.OAuthClient{}, errors.New("--client-secret requires --client-id")
+	}
+	return userCfg.OAuthClient()
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+	"golang.org/x/oauth2"
+)
+
+func TestImportCredential_DoesNotRevoke(t *testing.T) {
+	_, requests := setUpLogout(t, http.StatusOK)
+	path := filepath.Join(t.TempDir(), "docker_credentials.json")
+	t.Setenv("DOCKER_CREDENTIAL_GCR_STORE", path)
+	s := store.NewGCRCredStore(path)
+	tok := &oauth2.Token{AccessToken: "t0k3n", RefreshToken: testRefreshToken, Expiry: time.Now().Add(time.Hour)}
+	if err := s.SetGCRAuth(tok, "user@example.com", config.DefaultOAuthClient); err != nil {
+		t.Fatalf("Unable to store credentials: %v", err)
+	}
+	credFile := filepath.Join(t.TempDir(), "cred.json")
+	cred := `{
+		"type": "external_account",
+		"audience": "//iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/p/providers/gh",
+		"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+		"token_url": "https://sts.googleapis.com/v1/token",
+		"credential_source": {"file": "/var/run/token"}
+	}`
+	if err := os.WriteFile(credFile, []byte(cred), 0o600); err != nil {
+		t.Fatalf("Unable to write the credential config: %v", err)
+	}
+
+	if err := (&loginCmd{credFile: credFile}).importCredential(context.Background()); err != nil {
+		t.Fatalf("importCredential returned an error: %v", err)
+	}
+
+	if *requests != 0 {
+		t.Errorf("Expected the signed-in user's refresh token not to be revoked, got %d requests", *requests)
+	}
+	assertLoggedOut(t, s)
+	if imported, err := s.GetImportedCredential(); err != nil || imported.Type != store.ExternalAccountCredential {
+		t.Errorf("Expected the credential to be imported, got: %+v, %v", imported, err)
+	}
+}
@@ -18,41 +18,106 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/auth"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/gcloud"
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
 	"github.com/google/subcommands"
 )
 
+const localOnlyFlag = "local-only"
+
 type logoutCmd struct {
 	cmd
+	// skip revoking the refresh token
+	localOnly bool
 }
 
 // NewGCRLogoutSubcommand returns a subcommands.Command which implements the GCR
 // logout operation.
 func NewGCRLogoutSubcommand() subcommands.Command {
 	return &logoutCmd{
-		cmd{
+		cmd: cmd{
 			name:     "gcr-logout",
 			synopsis: "log out from GCR",
 		},
 	}
 }
 
-func (c *logoutCmd) Execute(context.Context, *flag.FlagSet, ...interface{}) subcommands.ExitStatus {
-	if err := c.GCRLogout(); err != nil {
+func (c *logoutCmd) SetFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.localOnly, localOnlyFlag, false, "only delete the stored credentials, without revoking the refresh token")
+}
+
+func (c *logoutCmd) Execute(ctx context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if err := c.GCRLogout(ctx); err != nil {
+		slog.Error("gcr-logout: failed", "error", err)
 		fmt.Fprintf(os.Stderr, "Logout failure: %v\n", err)
 		return subcommands.ExitFailure
 	}
+	slog.Info("gcr-logout: succeeded")
 	return subcommands.ExitSuccess
 }
 
 // GCRLogout performs the actions necessary to remove any GCR credentials
-// from the credential store.
-func (*logoutCmd) GCRLogout() error {
+// from the credential store, revoking the refresh token unless --local-only
+// was given.
+func (c *logoutCmd) GCRLogout(ctx context.Context) error {
 	s, err := store.DefaultGCRCredStore()
 	if err != nil {
 		return err
 	}
+	return deleteGCRAuth(ctx, s, c.localOnly)
+}
+
+// deleteGCRAuth deletes the stored GCR credentials, first revoking the refresh
+// token unless localOnly or gcloud also uses it. Failure to revoke the token is
+// reported, but doesn't prevent the credentials from being deleted.
+func deleteGCRAuth(ctx context.Context, s store.GCRCredStore, localOnly bool) error {
+	gcrAuth, err := s.GetGCRAuth()
+	if err != nil || gcrAuth.RefreshToken() == "" {
+		// Nothing to revoke.
+		return s.DeleteGCRAuth()
+	}
+
+	if localOnly {
+		fmt.Println("Skipping revocation; the refresh token remains valid until revoked at https://myaccount.google.com/permissions")
+		return s.DeleteGCRAuth()
+	}
+
+	// Credentials imported with --from-gcloud are gcloud's too.
+	if sharedWithGcloud(gcrAuth.RefreshToken()) {
+		slog.Info("not revoking the stored refresh token, which gcloud uses")
+		fmt.Println("Skipping revocation; the refresh token is also used by gcloud, run `gcloud auth revoke` to revoke it.")
+		return s.DeleteGCRAuth()
+	}
+
+	switch err := auth.RevokeToken(ctx, gcrAuth.RefreshToken()); err {
+	case nil:
+		slog.Info("revoked the stored refresh token")
+		fmt.Println("Revoked the stored refresh token.")
+	case auth.ErrTokenInvalid:
+		slog.Info("the stored refresh token was already invalid")
+		fmt.Println("The stored refresh token had already been revoked or expired.")
+	default:
+		slog.Warn("unable to revoke the stored refresh token", "error", err)
+		fmt.Fprintf(os.Stderr, "WARNING: Unable to revoke the stored refresh token: %v\n", err)
+		fmt.Fprintln(os.Stderr, "It will be deleted locally, but remains valid until revoked at https://myaccount.google.com/permissions")
+	}
 	return s.DeleteGCRAuth()
 }
+
+// sharedWithGcloud reports whether gcloud stores the given refresh token.
+// If gcloud's credentials can't be read, it's assumed not to.
+func sharedWithGcloud(refreshToken string) bool {
+	cfg, err := gcloud.DefaultConfig()
+	if err != nil {
+		return false
+	}
+	shared, err := cfg.HasRefreshToken(refreshToken)
+	if err != nil {
+		slog.Debug("unable to read gcloud's credentials", "dir", cfg.Dir, "error", err)
+	}
+	return shared
+}
@@ -0,0 +1,119 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+	"golang.org/x/oauth2"
+)
+
+const testRefreshToken = "refreshplz"
+
+// setUpLogout returns a store containing GCR credentials and points the
+// revocation endpoint at a fake server which responds with the given status.
+// The returned counter records the number of revocation requests.
+func setUpLogout(t *testing.T, status int) (store.GCRCredStore, *int) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if got := r.PostFormValue("token"); got != testRefreshToken {
+			t.Errorf("Expected token %q, got: %q", testRefreshToken, got)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	orig := config.GCRRevokeURL
+	config.GCRRevokeURL = srv.URL
+	t.Cleanup(func() { config.GCRRevokeURL = orig })
+
+	s := store.NewGCRCredStore(filepath.Join(t.TempDir(), "docker_credentials.json"))
+	tok := &oauth2.Token{AccessToken: "t0k3n", RefreshToken: testRefreshToken, Expiry: time.Now().Add(time.Hour)}
+	if err := s.SetGCRAuth(tok, "", config.DefaultOAuthClient); err != nil {
+		t.Fatalf("Unable to store credentials: %v", err)
+	}
+	return s, &requests
+}
+
+func assertLoggedOut(t *testing.T, s store.GCRCredStore) {
+	if gcrAuth, err := s.GetGCRAuth(); err == nil {
+		t.Errorf("Expected the credentials to be deleted, got: %+v", gcrAuth)
+	}
+}
+
+func TestDeleteGCRAuth_Revokes(t *testing.T) {
+	s, requests := setUpLogout(t, http.StatusOK)
+
+	if err := deleteGCRAuth(context.Background(), s, false); err != nil {
+		t.Fatalf("deleteGCRAuth returned an error: %v", err)
+	}
+
+	if *requests != 1 {
+		t.Errorf("Expected 1 revocation request, got: %d", *requests)
+	}
+	assertLoggedOut(t, s)
+}
+
+func TestDeleteGCRAuth_RevocationFailure(t *testing.T) {
+	s, requests := setUpLogout(t, http.StatusInternalServerError)
+
+	if err := deleteGCRAuth(context.Background(), s, false); err != nil {
+		t.Fatalf("deleteGCRAuth returned an error: %v", err)
+	}
+
+	if *requests != 1 {
+		t.Errorf("Expected 1 revocation request, got: %d", *requests)
+	}
+	assertLoggedOut(t, s)
+}
+
+func TestDeleteGCRAuth_LocalOnly(t *testing.T) {
+	s, requests := setUpLogout(t, http.StatusOK)
+
+	if err := deleteGCRAuth(context.Background(), s, true); err != nil {
+		t.Fatalf("deleteGCRAuth returned an error: %v", err)
+	}
+
+	if *requests != 0 {
+		t.Errorf("Expected no revocation requests, got: %d", *requests)
+	}
+	assertLoggedOut(t, s)
+}
+
+func TestDeleteGCRAuth_SharedWithGcloud(t *testing.T) {
+	s, requests := setUpLogout(t, http.StatusOK)
+	t.Setenv("CLOUDSDK_CONFIG", "../gcloud/testdata/valid")
+	// e.g. imported with --from-gcloud
+	tok := &oauth2.Token{RefreshToken: "user-refresh-token"}
+	if err := s.SetGCRAuth(tok, "user@example.com", config.DefaultOAuthClient); err != nil {
+		t.Fatalf("Unable to store credentials: %v", err)
+	}
+
+	if err := deleteGCRAuth(context.Background(), s, false); err != nil {
+		t.Fatalf("deleteGCRAuth returned an error: %v", err)
+	}
+
+	if *requests != 0 {
+		t.Errorf("Expected gcloud's refresh token not to be revoked, got %d requests", *requests)
+	}
+	assertLoggedOut(t, s)
+}
@@ -0,0 +1,179 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/credhelper"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+	cliconfig "github.com/docker/cli/cli/config"
+	"github.com/google/subcommands"
+)
+
+type statusCmd struct {
+	cmd
+	// print JSON rather than a table
+	json bool
+}
+
+// NewStatusSubcommand returns a subcommands.Command which shows the identity
+// which would be used to authenticate to each configured registry.
+func NewStatusSubcommand() subcommands.Command {
+	return &statusCmd{
+		cmd: cmd{
+			name:     "status",
+			synopsis: "show the active identity for each configured registry",
+		},
+	}
+}
+
+// Usage returns the usage of the status command.
+func (c *statusCmd) Usage() string {
+	return fmt.Sprintf("%s: %s\n  %s [--json] [registry...]\n", c.Name(), c.Synopsis(), c.Name())
+}
+
+func (c *statusCmd) SetFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.json, "json", false, "print the status as JSON")
+}
+
+func (c *statusCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	statuses, err := registryStatuses(ctx, f.Args())
+	if err != nil {
+		slog.Error("status: failed", "error", err)
+		fmt.Fprintf(os.Stderr, "Failure: %v\n", err)
+		return subcommands.ExitFailure
+	}
+
+	if c.json {
+		err = printStatusJSON(os.Stdout, statuses)
+	} else {
+		err = printStatusTable(os.Stdout, statuses)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failure: %v\n", err)
+		return subcommands.ExitFailure
+	}
+	for _, st := range statuses {
+		if st.Error != "" || st.RefreshError != "" {
+			return subcommands.ExitFailure
+		}
+	}
+	return subcommands.ExitSuccess
+}
+
+// registryStatuses resolves the status of the given registries or, if none are
+// given, of every registry configured to use this helper.
+func registryStatuses(ctx context.Context, registries []string) ([]credhelper.RegistryStatus, error) {
+	if len(registries) == 0 {
+		registries = configuredRegistries()
+	}
+	s, err := store.DefaultGCRCredStore()
+	if err != nil {
+		return nil, err
+	}
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return nil, err
+	}
+	return credhelper.Status(ctx, s, userCfg, registries), nil
+}
+
+// configuredRegistries returns the registries which the Docker config directs
+// to this helper, or the default GCR registries if there are none.
+func configuredRegistries() []string {
+	suffix := helperSuffix()
+	var registries []string
+	if dockerConfig, err := cliconfig.Load(""); err != nil {
+		slog.Warn("status: unable to load docker config", "error", err)
+	} else {
+		for registry, helper := range dockerConfig.CredentialHelpers {
+			if helper == suffix {
+				registries = append(registries, registry)
+			}
+		}
+	}
+	if len(registries) == 0 {
+		return config.DefaultGCRRegistries[:]
+	}
+	sort.Strings(registries)
+	return registries
+}
+
+func printStatusJSON(out io.Writer, statuses []credhelper.RegistryStatus) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(statuses)
+}
+
+func printStatusTable(out io.Writer, statuses []credhelper.RegistryStatus) error {
+	// The "auto" token source's choice doesn't depend on the registry.
+	for _, st := range statuses {
+		if len(st.AutoTokenSources) == 0 {
+			continue
+		}
+		fmt.Fprintln(out, "The auto token source chose, in order:")
+		for _, auto := range st.AutoTokenSources {
+			fmt.Fprintf(out, "  %s: %s\n", auto.Source, auto.Reason)
+		}
+		fmt.Fprintln(out)
+		break
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REGISTRY\tSOURCE\tPRINCIPAL\tEXPIRES\tSCOPES")
+	for _, st := range statuses {
+		if st.Error != "" && st.TokenSource == "" {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t-\n", st.Registry)
+			fmt.Fprintf(w, "  error: %s\t\t\t\t\n", st.Error)
+			continue
+		}
+		principal, expires, scopes := "(unknown)", "(unknown)", "(unknown)"
+		if st.Principal != "" {
+			principal = st.Principal
+		}
+		if st.Expiry != nil {
+			expires = st.Expiry.Local().Format(time.RFC3339)
+		}
+		if len(st.Scopes) != 0 {
+			scopes = strings.Join(st.Scopes, ",")
+		}
+		source := st.TokenSource
+		if st.StoredCredential != "" {
+			source += " (" + st.StoredCredential + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", st.Registry, source, principal, expires, scopes)
+		if st.Error != "" {
+			fmt.Fprintf(w, "  error: %s\t\t\t\t\n", st.Error)
+		}
+		if st.RefreshError != "" {
+			fmt.Fprintf(w, "  refresh token: %s\t\t\t\t\n", st.RefreshError)
+		}
+		if st.Warning != "" {
+			fmt.Fprintf(w, "  warning: %s\t\t\t\t\n", st.Warning)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,69 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// OAuthClient identifies the OAuth2 client used to sign in with gcr-login and
+// to refresh the resulting credentials.
+type OAuthClient struct {
+	ID     string
+	Secret string
+}
+
+// DefaultOAuthClient is docker-credential-gcr's own OAuth2 client.
+var DefaultOAuthClient = OAuthClient{
+	ID:     GCRCredHelperClientID,
+	Secret: GCRCredHelperClientNotSoSecret,
+}
+
+// IsDefault reports whether the client is docker-credential-gcr's own, or
+// unset.
+func (c OAuthClient) IsDefault() bool {
+	return c == DefaultOAuthClient || c == OAuthClient{}
+}
+
+// LoadClientSecrets loads an OAuth2 client from a client_secrets.json file,
+// as downloaded from the Google Cloud console, for either a desktop ("installed")
+// or a web application.
+func LoadClientSecrets(path string) (OAuthClient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return OAuthClient{}, err
+	}
+	type client struct {
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+	}
+	var secrets struct {
+		Installed *client `json:"installed"`
+		Web       *client `json:"web"`
+	}
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return OAuthClient{}, fmt.Errorf("failed to decode client secrets from %s: %v", path, err)
+	}
+	c := secrets.Installed
+	if c == nil {
+		c = secrets.Web
+	}
+	if c == nil || c.ClientID == "" {
+		return OAuthClient{}, fmt.Errorf(`no "installed" or "web" client_id in client secrets %s`, path)
+	}
+	return OAuthClient{ID: c.ClientID, Secret: c.ClientSecret}, nil
+}
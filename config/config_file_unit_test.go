@@ -16,8 +16,10 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 const (
@@ -145,3 +147,426 @@ func TestDefaultTokenSources(t *testing.T) {
 	// ordering require user notification.
 	assertEqual(t, expctedDefaultTokSrcs, DefaultTokenSources[:])
 }
+
+func TestDecode_Unversioned(t *testing.T) {
+	tested, err := decode([]byte(`{"TokenSources":["store"]}`))
+
+	if err != nil {
+		t.Fatalf("decode returned an error: %v", err)
+	}
+	if tested.SchemaVersion != currentSchemaVersion {
+		t.Errorf("Expected schema version: %d, got: %d", currentSchemaVersion, tested.SchemaVersion)
+	}
+	assertEqual(t, []string{"store"}, tested.TokenSources())
+}
+
+func TestDecode_MigratesGcloudSdk(t *testing.T) {
+	// "gcloud_sdk" was the string initially used to specify gcloud.
+	tested, err := decode([]byte(`{"TokenSources":["gcloud_sdk","env"]}`))
+
+	if err != nil {
+		t.Fatalf("decode returned an error: %v", err)
+	}
+	assertEqual(t, []string{"gcloud", "env"}, tested.TokenSources())
+}
+
+func TestDecode_UnknownField(t *testing.T) {
+	tested, err := decode([]byte(`{"SchemaVersion":1,"TokenSources":["env"],"SomeFutureSetting":{"On":true}}`))
+
+	if err != nil {
+		t.Fatalf("Expected an unknown setting to be ignored, got: %v", err)
+	}
+	assertEqual(t, []string{"env"}, tested.TokenSources())
+	if v := string(tested.unknown["SomeFutureSetting"]); v != `{"On":true}` {
+		t.Errorf("Expected the unknown setting to be set aside, got: %s", v)
+	}
+}
+
+func TestDecode_UnknownNestedField(t *testing.T) {
+	_, err := decode([]byte(`{"SchemaVersion":1,"ExecSources":{"broker":{"Command":"broker","Comand":"typo"}}}`))
+
+	if err == nil {
+		t.Fatal("Expected an error for an unknown field within a setting")
+	}
+	if !strings.Contains(err.Error(), `"Comand"`) {
+		t.Errorf("Expected error to name the unknown field, got: %v", err)
+	}
+}
+
+func TestPersist_PreservesUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), expectedFilename)
+	t.Setenv(expectedConfigEnvVar, path)
+	if err := os.WriteFile(path, []byte(`{"SchemaVersion":1,"SomeFutureSetting":true}`), 0600); err != nil {
+		t.Fatalf("Unable to write config: %v", err)
+	}
+	tested, err := load()
+	if err != nil {
+		t.Fatalf("load returned an error: %v", err)
+	}
+
+	tested.persist = persist
+	if err := tested.SetTokenSources([]string{"env"}); err != nil {
+		t.Fatalf("SetTokenSources returned an error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read config: %v", err)
+	}
+	if !strings.Contains(string(data), `"SomeFutureSetting":true`) || !strings.Contains(string(data), `"TokenSources":["env"]`) {
+		t.Errorf("Expected the unknown setting to be preserved alongside the new one, got: %s", data)
+	}
+}
+
+func TestResetAll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), expectedFilename)
+	t.Setenv(expectedConfigEnvVar, path)
+	if err := os.WriteFile(path, []byte(`{"SchemaVersion":1,"TokenSources":["env"],"SomeFutureSetting":true}`), 0600); err != nil {
+		t.Fatalf("Unable to write config: %v", err)
+	}
+	tested, err := load()
+	if err != nil {
+		t.Fatalf("load returned an error: %v", err)
+	}
+	persisted := 0
+	tested.persist = func(*configFile) error {
+		persisted++
+		return nil
+	}
+
+	if err := tested.ResetAll(); err != nil {
+		t.Fatalf("ResetAll returned an error: %v", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the config file to be deleted, got: %v", err)
+	}
+	assertEqual(t, expctedDefaultTokSrcs, tested.TokenSources())
+	if tested.SchemaVersion != currentSchemaVersion || tested.unknown != nil || tested.path != "" {
+		t.Errorf("Expected a fresh config, got: %+v", tested)
+	}
+	if err := tested.SetTokenSources([]string{"store"}); err != nil || persisted != 1 {
+		t.Errorf("Expected the reset config to still persist changes, got: %v after %d writes", err, persisted)
+	}
+}
+
+func TestDecode_NewerSchemaVersion(t *testing.T) {
+	_, err := decode([]byte(`{"SchemaVersion":999,"SomeFutureSetting":true}`))
+
+	if err == nil {
+		t.Fatal("Expected an error for a newer schema version")
+	}
+	if !strings.Contains(err.Error(), "schema version 999") {
+		t.Errorf("Expected error to mention the schema version, got: %v", err)
+	}
+}
+
+func TestDecode_InvalidTokenSource(t *testing.T) {
+	_, err := decode([]byte(`{"SchemaVersion":1,"TokenSources":["invalid"]}`))
+
+	if err == nil {
+		t.Fatal("Expected an error for an unsupported token source")
+	}
+	if !strings.Contains(err.Error(), `"invalid"`) {
+		t.Errorf("Expected error to name the token source, got: %v", err)
+	}
+}
+
+func TestGcloudSelection_Registries(t *testing.T) {
+	tested, err := decode([]byte(`{"SchemaVersion":1,
+		"GcloudConfiguration":"default-config",
+		"GcloudRegistries":{
+			"*.pkg.dev":{"Configuration":"artifacts"},
+			"us-docker.pkg.dev":{"Account":"us@example.com"}
+		}}`))
+	if err != nil {
+		t.Fatalf("decode returned an error: %v", err)
+	}
+
+	for serverURL, expected := range map[string]GcloudSelection{
+		"https://us-docker.pkg.dev": {Account: "us@example.com"},
+		"eu-docker.pkg.dev":         {Configuration: "artifacts"},
+		"https://gcr.io":            {Configuration: "default-config"},
+	} {
+		if got := tested.GcloudSelection(serverURL); got != expected {
+			t.Errorf("GcloudSelection(%q): expected %+v, got: %+v", serverURL, expected, got)
+		}
+	}
+}
+
+func TestDecode_InvalidGcloudRegistries(t *testing.T) {
+	for _, contents := range []string{
+		`{"SchemaVersion":1,"GcloudRegistries":{"[gcr.io":{"Account":"me@example.com"}}}`,
+		`{"SchemaVersion":1,"GcloudRegistries":{"gcr.io":{}}}`,
+	} {
+		if _, err := decode([]byte(contents)); err == nil || !strings.Contains(err.Error(), `"GcloudRegistries"`) {
+			t.Errorf("Expected an error naming GcloudRegistries for %s, got: %v", contents, err)
+		}
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	tested := &configFile{}
+	if tested.KeyFile() != "" || !tested.KeyFileSelfSignedJWT() || tested.KeyRotationAge() != 0 {
+		t.Errorf("Expected no key file, self-signed JWTs and no rotation age by default, got: %q, %v, %v", tested.KeyFile(), tested.KeyFileSelfSignedJWT(), tested.KeyRotationAge())
+	}
+
+	tested, err := decode([]byte(`{"SchemaVersion":1,"TokenSources":["keyfile"],"KeyFile":"/etc/sa.json","KeyFileSelfSignedJWT":false,"KeyRotationAge":"2160h"}`))
+	if err != nil {
+		t.Fatalf("decode returned an error: %v", err)
+	}
+	if tested.KeyFile() != "/etc/sa.json" || tested.KeyFileSelfSignedJWT() || tested.KeyRotationAge() != 90*24*time.Hour {
+		t.Errorf("Expected the configured key file settings, got: %q, %v, %v", tested.KeyFile(), tested.KeyFileSelfSignedJWT(), tested.KeyRotationAge())
+	}
+
+	if _, err := decode([]byte(`{"SchemaVersion":1,"KeyRotationAge":"90d"}`)); err == nil || !strings.Contains(err.Error(), `"KeyRotationAge"`) {
+		t.Errorf("Expected an error naming KeyRotationAge, got: %v", err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	tested := &configFile{}
+	if tested.MetadataServiceAccount() != DefaultMetadataServiceAccount || tested.MetadataHost() != "" || tested.MetadataNegativeCacheTTL() != DefaultMetadataNegativeCacheTTL {
+		t.Errorf("Expected the default metadata settings, got: %q, %q, %v", tested.MetadataServiceAccount(), tested.MetadataHost(), tested.MetadataNegativeCacheTTL())
+	}
+
+	tested, err := decode([]byte(`{"SchemaVersion":1,"TokenSources":["metadata"],"MetadataServiceAccount":"ci@my-project.iam.gserviceaccount.com","MetadataHost":"metadata.google.internal","MetadataNegativeCacheTTL":"0"}`))
+	if err != nil {
+		t.Fatalf("decode returned an error: %v", err)
+	}
+	if tested.MetadataServiceAccount() != "ci@my-project.iam.gserviceaccount.com" || tested.MetadataHost() != "metadata.google.internal" || tested.MetadataNegativeCacheTTL() != 0 {
+		t.Errorf("Expected the configured metadata settings, got: %q, %q, %v", tested.MetadataServiceAccount(), tested.MetadataHost(), tested.MetadataNegativeCacheTTL())
+	}
+
+	for setting, contents := range map[string]string{
+		"MetadataServiceAccount":   `{"SchemaVersion":1,"MetadataServiceAccount":"../token"}`,
+		"MetadataHost":             `{"SchemaVersion":1,"MetadataHost":"http://metadata.google.internal"}`,
+		"MetadataNegativeCacheTTL": `{"SchemaVersion":1,"MetadataNegativeCacheTTL":"forever"}`,
+	} {
+		if _, err := decode([]byte(contents)); err == nil || !strings.Contains(err.Error(), `"`+setting+`"`) {
+			t.Errorf("Expected an error naming %s, got: %v", setting, err)
+		}
+	}
+}
+
+func TestExecSource(t *testing.T) {
+	tested, err := decode([]byte(`{"SchemaVersion":1,
+		"TokenSources":["exec:broker","gcloud"],
+		"ExecSources":{"broker":{"Command":"broker","Args":["token"],"Timeout":"5s","Format":"json"}}}`))
+	if err != nil {
+		t.Fatalf("decode returned an error: %v", err)
+	}
+
+	src, ok := tested.ExecSource("broker")
+	if !ok || src.Command != "broker" || src.ExecTimeout() != 5*time.Second || src.OutputFormat() != ExecFormatJSON {
+		t.Errorf("Expected the broker's program, got: %+v, %v", src, ok)
+	}
+	if _, ok := tested.ExecSource("other"); ok {
+		t.Error("Expected no other programs to be configured")
+	}
+	if src := (ExecSource{Command: "broker"}); src.OutputFormat() != ExecFormatRaw {
+		t.Errorf("Expected raw output by default, got: %s", src.OutputFormat())
+	}
+}
+
+func TestDecode_InvalidExecSources(t *testing.T) {
+	for _, contents := range []string{
+		`{"SchemaVersion":1,"ExecSources":{"broker":{"Args":["token"]}}}`,
+		`{"SchemaVersion":1,"ExecSources":{"broker":{"Command":"broker","Timeout":"soon"}}}`,
+		`{"SchemaVersion":1,"ExecSources":{"broker":{"Command":"broker","Format":"yaml"}}}`,
+	} {
+		if _, err := decode([]byte(contents)); err == nil || !strings.Contains(err.Error(), `"ExecSources"`) {
+			t.Errorf("Expected an error naming ExecSources for %s, got: %v", contents, err)
+		}
+	}
+	if _, err := decode([]byte(`{"SchemaVersion":1,"TokenSources":["exec:"]}`)); err == nil {
+		t.Error("Expected an error for an unnamed exec token source")
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	tested := &configFile{}
+	if tested.Timeout() != DefaultTimeout || tested.TokenSourceTimeout() != DefaultTokenSourceTimeout {
+		t.Errorf("Expected the default timeouts, got: %v, %v", tested.Timeout(), tested.TokenSourceTimeout())
+	}
+
+	tested, err := decode([]byte(`{"SchemaVersion":1,"Timeout":"0","TokenSourceTimeout":"1m30s"}`))
+	if err != nil {
+		t.Fatalf("decode returned an error: %v", err)
+	}
+	if tested.Timeout() != 0 {
+		t.Errorf("Expected no overall timeout, got: %v", tested.Timeout())
+	}
+	if expected := 90 * time.Second; tested.TokenSourceTimeout() != expected {
+		t.Errorf("Expected token source timeout: %v, got: %v", expected, tested.TokenSourceTimeout())
+	}
+}
+
+func TestDecode_InvalidTimeout(t *testing.T) {
+	for _, contents := range []string{
+		`{"SchemaVersion":1,"Timeout":"forever"}`,
+		`{"SchemaVersion":1,"Timeout":"-1s"}`,
+		`{"SchemaVersion":1,"TokenSourceTimeout":"10"}`,
+	} {
+		if _, err := decode([]byte(contents)); err == nil || !strings.Contains(err.Error(), `Timeout"`) {
+			t.Errorf("Expected an error naming the timeout for %s, got: %v", contents, err)
+		}
+	}
+}
+
+func TestTokenSourceRetries(t *testing.T) {
+	tested := &configFile{}
+	if tested.TokenSourceRetries() != DefaultTokenSourceRetries || tested.TokenSourceRetryBackoff() != DefaultTokenSourceRetryBackoff {
+		t.Errorf("Expected the default retry policy, got: %d, %v", tested.TokenSourceRetries(), tested.TokenSourceRetryBackoff())
+	}
+
+	tested, err := decode([]byte(`{"SchemaVersion":1,"TokenSourceRetries":0,"TokenSourceRetryBackoff":"1s"}`))
+	if err != nil {
+		t.Fatalf("decode returned an error: %v", err)
+	}
+	if tested.TokenSourceRetries() != 0 || tested.TokenSourceRetryBackoff() != time.Second {
+		t.Errorf("Expected retries to be disabled with a 1s backoff, got: %d, %v", tested.TokenSourceRetries(), tested.TokenSourceRetryBackoff())
+	}
+
+	if _, err := decode([]byte(`{"SchemaVersion":1,"TokenSourceRetries":-1}`)); err == nil {
+		t.Error("Expected an error for negative retries")
+	}
+}
+
+func TestInteractive(t *testing.T) {
+	if got := (&configFile{}).Interactive(); got != InteractiveAuto {
+		t.Errorf("Expected the default mode %q, got: %q", InteractiveAuto, got)
+	}
+	tested, err := decode([]byte(`{"SchemaVersion":1,"Interactive":"never"}`))
+	if err != nil {
+		t.Fatalf("decode returned an error: %v", err)
+	}
+	if got := tested.Interactive(); got != InteractiveNever {
+		t.Errorf("Expected mode %q, got: %q", InteractiveNever, got)
+	}
+	if _, err := decode([]byte(`{"SchemaVersion":1,"Interactive":"sometimes"}`)); err == nil || !strings.Contains(err.Error(), `"sometimes"`) {
+		t.Errorf("Expected an error naming the unsupported mode, got: %v", err)
+	}
+}
+
+func TestReauth(t *testing.T) {
+	tested := &configFile{}
+	if tested.ReauthPolicy() != ReauthPolicyRAPT || tested.ReauthNotifyCommand() != nil {
+		t.Errorf("Expected the default reauth settings, got: %q, %q", tested.ReauthPolicy(), tested.ReauthNotifyCommand())
+	}
+
+	tested, err := decode([]byte(`{"SchemaVersion":1,"ReauthPolicy":"any","ReauthNotifyCommand":"/opt/My Tools/notify","ReauthNotifyArgs":["-u","critical"]}`))
+	if err != nil {
+		t.Fatalf("decode returned an error: %v", err)
+	}
+	if tested.ReauthPolicy() != ReauthPolicyAny {
+		t.Errorf("Expected policy %q, got: %q", ReauthPolicyAny, tested.ReauthPolicy())
+	}
+	assertEqual(t, []string{"/opt/My Tools/notify", "-u", "critical"}, tested.ReauthNotifyCommand())
+
+	if _, err := decode([]byte(`{"SchemaVersion":1,"ReauthPolicy":"sometimes"}`)); err == nil {
+		t.Error("Expected an error for an unsupported reauth policy")
+	}
+}
+
+func TestOAuthClient(t *testing.T) {
+	if got, err := (&configFile{}).OAuthClient(); err != nil || got != DefaultOAuthClient {
+		t.Errorf("Expected the default client, got: %+v, %v", got, err)
+	}
+
+	tested, err := decode([]byte(`{"SchemaVersion":1,"OAuthClientId":"mine.apps.googleusercontent.com","OAuthClientSecret":"shh","LoginHint":"me@example.com","HostedDomain":"example.com"}`))
+	if err != nil {
+		t.Fatalf("decode returned an error: %v", err)
+	}
+	if got, expected := mustOAuthClient(t, tested), (OAuthClient{ID: "mine.apps.googleusercontent.com", Secret: "shh"}); got != expected {
+		t.Errorf("Expected client %+v, got: %+v", expected, got)
+	}
+	if tested.LoginHint() != "me@example.com" || tested.HostedDomain() != "example.com" {
+		t.Errorf("Expected the login parameters, got: %q, %q", tested.LoginHint(), tested.HostedDomain())
+	}
+
+	for _, contents := range []string{
+		`{"SchemaVersion":1,"OAuthClientSecret":"shh"}`,
+		`{"SchemaVersion":1,"OAuthClientId":"mine","OAuthClientSecretsFile":"client_secrets.json"}`,
+	} {
+		if _, err := decode([]byte(contents)); err == nil || !strings.Contains(err.Error(), `"OAuthClient`) {
+			t.Errorf("Expected an error naming the OAuth2 client for %s, got: %v", contents, err)
+		}
+	}
+}
+
+func TestLoadClientSecrets(t *testing.T) {
+	for _, tc := range []struct {
+		contents string
+		expected OAuthClient
+	}{
+		{`{"installed":{"client_id":"desktop","client_secret":"shh","redirect_uris":["http://localhost"]}}`, OAuthClient{ID: "desktop", Secret: "shh"}},
+		{`{"web":{"client_id":"web","client_secret":"hush"}}`, OAuthClient{ID: "web", Secret: "hush"}},
+	} {
+		path := filepath.Join(t.TempDir(), "client_secrets.json")
+		if err := os.WriteFile(path, []byte(tc.contents), 0600); err != nil {
+			t.Fatalf("Unable to write client secrets: %v", err)
+		}
+		if got, err := LoadClientSecrets(path); err != nil || got != tc.expected {
+			t.Errorf("Expected client %+v from %s, got: %+v, %v", tc.expected, tc.contents, got, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "client_secrets.json")
+	if err := os.WriteFile(path, []byte(`{"client_id":"not nested"}`), 0600); err != nil {
+		t.Fatalf("Unable to write client secrets: %v", err)
+	}
+	if _, err := LoadClientSecrets(path); err == nil {
+		t.Error("Expected an error for client secrets without an installed or web client")
+	}
+}
+
+func mustOAuthClient(t *testing.T, c interface{ OAuthClient() (OAuthClient, error) }) OAuthClient {
+	client, err := c.OAuthClient()
+	if err != nil {
+		t.Fatalf("OAuthClient returned an error: %v", err)
+	}
+	return client
+}
+
+func TestIsGoogleRegistry(t *testing.T) {
+	for serverURL, expected := range map[string]bool{
+		"gcr.io":                         true,
+		"https://eu.gcr.io/v2/":          true,
+		"us-west1-docker.pkg.dev":        true,
+		"docker.us-east1.rep.pkg.dev":    true,
+		"https://index.docker.io/v1/":    false,
+		"ghcr.io":                        false,
+		"notgcr.io":                      false,
+		"pkg.dev.attacker.example.com":   false,
+		"https://registry.example.com:5": false,
+	} {
+		if got := IsGoogleRegistry(serverURL); got != expected {
+			t.Errorf("IsGoogleRegistry(%q): expected %v, got: %v", serverURL, expected, got)
+		}
+	}
+}
+
+func TestSetCredsStore(t *testing.T) {
+	persisted := 0
+	tested := &configFile{persist: func(*configFile) error {
+		persisted++
+		return nil
+	}}
+	if tested.CredsStore() || tested.CredsStoreDelegate() != "" {
+		t.Errorf("Expected credsStore mode to be disabled by default")
+	}
+
+	if err := tested.SetCredsStore(true, "pass"); err != nil {
+		t.Fatalf("SetCredsStore returned an error: %v", err)
+	}
+	if !tested.CredsStore() || tested.CredsStoreDelegate() != "pass" || persisted != 1 {
+		t.Errorf("Expected credsStore mode delegating to pass to be persisted, got: %v, %q after %d writes", tested.CredsStore(), tested.CredsStoreDelegate(), persisted)
+	}
+	if err := tested.SetCredsStore(true, "pass"); err != nil || persisted != 1 {
+		t.Errorf("Expected an unchanged setting not to be persisted, got: %v after %d writes", err, persisted)
+	}
+	if err := tested.SetCredsStore(false, ""); err != nil || tested.CredsStore() || tested.AsCredsStore != nil {
+		t.Errorf("Expected credsStore mode to be unset, got: %v, %v", tested.CredsStore(), err)
+	}
+}
@@ -16,11 +16,11 @@
 package config
 
 import (
-	"context"
 	"fmt"
 	"regexp"
 	"runtime/debug"
 	"strings"
+	"time"
 
 	"golang.org/x/oauth2/google"
 )
@@ -160,23 +160,125 @@ var DefaultARRegistries = [...]string{
 	"asia-southeast3-docker.pkg.dev",
 }
 
+// IsGoogleRegistry reports whether the given registry is served by GCR or
+// Artifact Registry: gcr.io, pkg.dev or any of their subdomains.
+func IsGoogleRegistry(serverURL string) bool {
+	host := strings.ToLower(RegistryHost(serverURL))
+	for _, domain := range []string{"gcr.io", "pkg.dev"} {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
 // SupportedGCRTokenSources maps config keys to plain english explanations for
 // where the helper should search for a GCR access token.
 var SupportedGCRTokenSources = map[string]string{
-	"env":    "Application default credentials or GCE/AppEngine metadata.",
-	"gcloud": "'gcloud auth print-access-token'",
-	"store":  "The file store maintained by the credential helper.",
+	"auto":          "The sources suited to the detected environment, e.g. metadata on GCE or store and gcloud-native on a workstation.",
+	"env":           "Application default credentials or GCE/AppEngine metadata.",
+	"envvar":        "A pre-minted access token in " + AccessTokenEnvVar + ".",
+	"file":          "A pre-minted access token in the AccessTokenFile, re-read on each request.",
+	"gcloud":        "'gcloud auth print-access-token'",
+	"gcloud-native": "gcloud's credentials, read without executing gcloud.",
+	"keyfile":       "The service account key in the KeyFile.",
+	"metadata":      "The GCE metadata server's MetadataServiceAccount.",
+	"store":         "The file store maintained by the credential helper.",
 }
 
+// JSONKeyTokenSource names the service account keys stored for a registry by
+// `docker login -u _json_key`, which are used ahead of the token sources. It
+// isn't configurable as a token source, but policy forbids it unless it's
+// listed in AllowedTokenSources, if set, and pinned TokenSources, if any,
+// include "auto".
+const JSONKeyTokenSource = "json_key"
+
+// The environment variables read by the "envvar" token source.
+const (
+	// AccessTokenEnvVar holds a pre-minted access token.
+	AccessTokenEnvVar = "DOCKER_CREDENTIAL_GCR_ACCESS_TOKEN"
+	// AccessTokenExpiryEnvVar optionally holds the access token's expiry, in
+	// RFC 3339 format, e.g. "2026-01-02T15:04:05Z".
+	AccessTokenExpiryEnvVar = "DOCKER_CREDENTIAL_GCR_ACCESS_TOKEN_EXPIRY"
+)
+
 // GCROAuth2Endpoint describes the oauth2.Endpoint to be used when
 // authenticating a GCR user.
 var GCROAuth2Endpoint = google.Endpoint
 
+// GCRRevokeURL is the endpoint used to revoke the refresh token of a GCR user
+// on logout.
+var GCRRevokeURL = "https://oauth2.googleapis.com/revoke"
+
+// GCRTokenInfoURL is the endpoint used to look up the account, scopes and
+// expiry of an access token.
+var GCRTokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
 // GCRScopes is/are the OAuth2 scope(s) to request during access_token creation.
 var GCRScopes = []string{"https://www.googleapis.com/auth/devstorage.read_write"}
 
-// OAuthHTTPContext is the HTTP context to use when performing OAuth2 calls.
-var OAuthHTTPContext = context.Background()
+// DefaultTimeout is the default deadline for retrieving credentials from all of
+// the configured token sources.
+const DefaultTimeout = time.Minute
+
+// DefaultTokenSourceTimeout is the default deadline for retrieving credentials
+// from a single token source.
+const DefaultTokenSourceTimeout = 30 * time.Second
+
+// The supported interactivity modes, which control whether the helper may
+// interact with the user, e.g. by opening a browser to reauthenticate.
+const (
+	// InteractiveAuto interacts with the user only if the helper is attached
+	// to a terminal, outside of CI.
+	InteractiveAuto = "auto"
+	// InteractiveAlways always interacts with the user.
+	InteractiveAlways = "always"
+	// InteractiveNever never interacts with the user, failing instead.
+	InteractiveNever = "never"
+)
+
+// InteractiveModes is the set of supported interactivity modes.
+var InteractiveModes = map[string]bool{
+	InteractiveAuto:   true,
+	InteractiveAlways: true,
+	InteractiveNever:  true,
+}
+
+// The supported reauthentication policies, which control which failures to
+// refresh the stored credentials send the user to sign in again.
+const (
+	// ReauthPolicyRAPT signs the user in again only when Google requires
+	// reauthentication, e.g. under a session control policy.
+	ReauthPolicyRAPT = "rapt"
+	// ReauthPolicyAny also signs the user in again when the stored
+	// credentials have expired or been revoked.
+	ReauthPolicyAny = "any"
+	// ReauthPolicyNever never signs the user in again, failing instead.
+	ReauthPolicyNever = "never"
+)
+
+// ReauthPolicies is the set of supported reauthentication policies.
+var ReauthPolicies = map[string]bool{
+	ReauthPolicyRAPT:  true,
+	ReauthPolicyAny:   true,
+	ReauthPolicyNever: true,
+}
+
+// DefaultMetadataServiceAccount is the alias of the instance's default service
+// account on the metadata server.
+const DefaultMetadataServiceAccount = "default"
+
+// DefaultMetadataNegativeCacheTTL is how long, by default, the "metadata" token
+// source remembers that the metadata server couldn't be reached.
+const DefaultMetadataNegativeCacheTTL = time.Hour
+
+// DefaultTokenSourceRetries is the default number of times a token source which
+// failed with a transient error is retried.
+const DefaultTokenSourceRetries = 2
+
+// DefaultTokenSourceRetryBackoff is the default delay before the first retry of
+// a token source.
+const DefaultTokenSourceRetryBackoff = 250 * time.Millisecond
 
 // GcrOAuth2Username is the Basic auth username accompanying Docker requests to GCR.
 var GcrOAuth2Username string
@@ -0,0 +1,92 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ExecTokenSourcePrefix prefixes the token sources, e.g. "exec:broker", which
+// run the ExecSource of the same name.
+const ExecTokenSourcePrefix = "exec:"
+
+// The output formats of an ExecSource's program.
+const (
+	// ExecFormatRaw is an access token, alone.
+	ExecFormatRaw = "raw"
+	// ExecFormatJSON is a JSON object with an "access_token", its
+	// "expires_in", in seconds, and its "token_type".
+	ExecFormatJSON = "json"
+)
+
+// ExecFormats are the supported output formats of an ExecSource's program.
+var ExecFormats = map[string]bool{
+	ExecFormatRaw:  true,
+	ExecFormatJSON: true,
+}
+
+// ExecSource configures a token source which runs a program, e.g. a credential
+// broker's CLI, to obtain an access token.
+type ExecSource struct {
+	Command string   `json:"Command"`
+	Args    []string `json:"Args,omitempty"`
+	// Timeout bounds the program's execution, e.g. "10s", within the token
+	// source's own deadline; "" or "0" for no additional deadline.
+	Timeout string `json:"Timeout,omitempty"`
+	// Format is one of the ExecFormats, "" for ExecFormatRaw.
+	Format string `json:"Format,omitempty"`
+}
+
+// ExecTimeout returns the deadline for the program's execution, or 0 if there
+// is none.
+func (s ExecSource) ExecTimeout() time.Duration {
+	d, _ := parseTimeout(s.Timeout)
+	return d
+}
+
+// OutputFormat returns the program's output format, one of the ExecFormats.
+func (s ExecSource) OutputFormat() string {
+	if s.Format == "" {
+		return ExecFormatRaw
+	}
+	return s.Format
+}
+
+func (s ExecSource) validate() error {
+	if strings.TrimSpace(s.Command) == "" {
+		return fmt.Errorf("no Command is set")
+	}
+	if _, err := parseTimeout(s.Timeout); err != nil {
+		return fmt.Errorf("invalid Timeout: %v", err)
+	}
+	if s.Format != "" && !ExecFormats[s.Format] {
+		return fmt.Errorf("unsupported Format %q", s.Format)
+	}
+	return nil
+}
+
+// isSupportedTokenSource reports whether the given token source is one of the
+// SupportedGCRTokenSources or an "exec:<name>" source. Whether the named
+// ExecSource is configured is only checked when it's used, since it may be
+// configured in another layer.
+func isSupportedTokenSource(source string) bool {
+	if name, ok := strings.CutPrefix(source, ExecTokenSourcePrefix); ok {
+		return name != ""
+	}
+	_, supported := SupportedGCRTokenSources[source]
+	return supported
+}
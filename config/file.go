@@ -15,11 +15,17 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/util"
 )
@@ -29,37 +35,165 @@ const (
 	configFileName        = "docker_credential_gcr_config.json"
 )
 
+// migrations upgrade a config file from one schema version to the next:
+// migrations[i] upgrades a file with SchemaVersion i to SchemaVersion i+1.
+// Files written before schema versioning was introduced are version 0.
+// Adding a new optional setting doesn't require a new schema version: older
+// binaries ignore unknown settings with a warning, and preserve them when they
+// rewrite the file. Settings whose absence would change the meaning of others,
+// or new fields within a setting's object, do require one.
+var migrations = []func(*configFile){
+	// 0 -> 1: the "gcloud_sdk" token source was renamed to "gcloud".
+	func(c *configFile) {
+		for i, src := range c.TokenSrcs {
+			if src == "gcloud_sdk" {
+				c.TokenSrcs[i] = "gcloud"
+			}
+		}
+	},
+}
+
+// currentSchemaVersion is the schema version written by this binary.
+var currentSchemaVersion = len(migrations)
+
 // DefaultTokenSources designates which default source(s) should be used to
 // fetch a GCR access_token, and in which order.
 var DefaultTokenSources = [...]string{"store", "env"}
 
-// UserConfig describes
+// UserConfig describes the user-configurable application settings.
 type UserConfig interface {
 	TokenSources() []string
 	SetTokenSources([]string) error
+	Scopes() []string
+	ScopesPinned() bool
+	CheckRegistry(serverURL string) error
+	AllowsTokenSource(source string) bool
+	AuditLog() string
+	AuditLogMaxSize() int64
+	AccessTokenFile() string
+	KeyFile() string
+	KeyFileSelfSignedJWT() bool
+	KeyRotationAge() time.Duration
+	MetadataServiceAccount() string
+	MetadataHost() string
+	MetadataNegativeCacheTTL() time.Duration
+	GcloudSelection(serverURL string) GcloudSelection
+	Timeout() time.Duration
+	TokenSourceTimeout() time.Duration
+	TokenSourceRetries() int
+	TokenSourceRetryBackoff() time.Duration
+	Interactive() string
+	ReauthPolicy() string
+	ReauthNotifyCommand() []string
+	OAuthClient() (OAuthClient, error)
+	LoginHint() string
+	HostedDomain() string
+	CredsStore() bool
+	CredsStoreDelegate() string
+	SetCredsStore(enabled bool, delegate string) error
+	ExecSource(name string) (ExecSource, bool)
 	ResetAll() error
+	Settings() []Setting
+}
+
+// A Setting describes the effective value of a single user-configurable
+// setting and where that value came from.
+type Setting struct {
+	Name   string
+	Value  string
+	Origin string
+	// Shadowed lists the values of this setting in lower-precedence layers,
+	// in decreasing order of precedence, ending with the default.
+	Shadowed []Setting
+}
+
+// GcloudSelection selects the gcloud named configuration and/or account used
+// by the gcloud token sources. Empty fields use gcloud's own defaults.
+type GcloudSelection struct {
+	Configuration string `json:"Configuration,omitempty"`
+	Account       string `json:"Account,omitempty"`
 }
 
 // configFile describes the structure of the persistent config store.
 type configFile struct {
-	TokenSrcs []string `json:"TokenSources,omitempty"`
+	SchemaVersion int      `json:"SchemaVersion"`
+	TokenSrcs     []string `json:"TokenSources,omitempty"`
+	Scps          []string `json:"Scopes,omitempty"`
+	AuditLogPath  string   `json:"AuditLog,omitempty"`
+	AuditLogMaxSz int64    `json:"AuditLogMaxSize,omitempty"`
+	GcloudConfig  string   `json:"GcloudConfiguration,omitempty"`
+	GcloudAcct    string   `json:"GcloudAccount,omitempty"`
+	// GcloudRegs overrides the gcloud selection for registry hosts, which may
+	// be glob patterns.
+	GcloudRegs map[string]GcloudSelection `json:"GcloudRegistries,omitempty"`
+	// durations, e.g. "30s"; "0" disables the deadline
+	TotalTimeout string `json:"Timeout,omitempty"`
+	SrcTimeout   string `json:"TokenSourceTimeout,omitempty"`
+	// the number of times a failed token source is retried, nil for the
+	// default
+	SrcRetries      *int   `json:"TokenSourceRetries,omitempty"`
+	SrcRetryBackoff string `json:"TokenSourceRetryBackoff,omitempty"`
+	// one of the InteractiveModes
+	InteractiveMode string `json:"Interactive,omitempty"`
+	// one of the ReauthPolicies
+	Reauth string `json:"ReauthPolicy,omitempty"`
+	// the program run when the stored credentials need reauthentication, with
+	// ReauthNotifyArgs and a message as its arguments
+	ReauthNotifyCmd  string   `json:"ReauthNotifyCommand,omitempty"`
+	ReauthNotifyArgs []string `json:"ReauthNotifyArgs,omitempty"`
+	// the OAuth2 client used by gcr-login, either inline or as the path of a
+	// client_secrets.json file
+	ClientID          string `json:"OAuthClientId,omitempty"`
+	ClientSecret      string `json:"OAuthClientSecret,omitempty"`
+	ClientSecretsFile string `json:"OAuthClientSecretsFile,omitempty"`
+	// the login_hint and hd parameters sent by gcr-login
+	Hint   string `json:"LoginHint,omitempty"`
+	Domain string `json:"HostedDomain,omitempty"`
+	// whether the helper is installed as Docker's global credsStore, and the
+	// suffix of the credsStore it replaced, if any
+	AsCredsStore *bool  `json:"CredsStore,omitempty"`
+	Delegate     string `json:"CredsStoreDelegate,omitempty"`
+	// the file read by the "file" token source
+	AccessTokenPath string `json:"AccessTokenFile,omitempty"`
+	// the service account key used by the "keyfile" token source, whether its
+	// tokens are self-signed JWTs, nil for the default, and the age after
+	// which it should be rotated
+	KeyPath          string `json:"KeyFile,omitempty"`
+	KeySelfSignedJWT *bool  `json:"KeyFileSelfSignedJWT,omitempty"`
+	KeyRotation      string `json:"KeyRotationAge,omitempty"`
+	// the service account, metadata server and negative cache duration of the
+	// "metadata" token source
+	MetadataSA       string `json:"MetadataServiceAccount,omitempty"`
+	MetadataHostname string `json:"MetadataHost,omitempty"`
+	MetadataCacheTTL string `json:"MetadataNegativeCacheTTL,omitempty"`
+	// the programs run by "exec:<name>" token sources, keyed by name
+	ExecSrcs map[string]ExecSource `json:"ExecSources,omitempty"`
+
+	// the path the config was loaded from, if any
+	path string
+	// settings this version doesn't support, written by a newer one
+	unknown map[string]json.RawMessage
 
 	// package private helper, made a member variable and exposed for testing
 	persist func(*configFile) error
 }
 
 // LoadUserConfig returns the UserConfig which provides user-configurable
-// application settings, or a new on if it doesn't exist.
+// application settings, resolved from each of the configuration layers.
 func LoadUserConfig() (UserConfig, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
 	config, err := load()
 	if err != nil {
 		if !os.IsNotExist(err) {
 			return nil, err
 		}
-		config = &configFile{}
+		config = &configFile{SchemaVersion: currentSchemaVersion}
 	}
 	config.persist = persist
-	return config, nil
+	return loadLayers(config, path)
 }
 
 func load() (*configFile, error) {
@@ -67,20 +201,163 @@ func load() (*configFile, error) {
 	if err != nil {
 		return nil, err
 	}
-	f, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	var config configFile
-	if err := json.NewDecoder(f).Decode(&config); err != nil {
+	config, err := decode(data)
+	if err != nil {
 		return nil, fmt.Errorf("failed to load config from %s: %v", path, err)
 	}
+	config.path = path
+	config.warnUnknown(path)
 
+	return config, nil
+}
+
+// decode strictly decodes, migrates and validates a config file. Unknown
+// top-level settings, e.g. written by a newer binary, are set aside rather than
+// rejected.
+func decode(data []byte) (*configFile, error) {
+	// Check the schema version first, so that files written by a newer binary
+	// report a version mismatch rather than an unsupported setting.
+	var header struct {
+		SchemaVersion int
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	if header.SchemaVersion < 0 || header.SchemaVersion > currentSchemaVersion {
+		return nil, fmt.Errorf("unsupported schema version %d (this version of docker-credential-gcr supports up to %d)", header.SchemaVersion, currentSchemaVersion)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	unknown := map[string]json.RawMessage{}
+	for name, v := range fields {
+		if !isKnownSetting(name) {
+			unknown[name] = v
+			delete(fields, name)
+		}
+	}
+	if len(unknown) != 0 {
+		known, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		data = known
+	}
+
+	config := configFile{unknown: unknown}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&config); err != nil {
+		return nil, err
+	}
+
+	for v := config.SchemaVersion; v < currentSchemaVersion; v++ {
+		migrations[v](&config)
+	}
+	config.SchemaVersion = currentSchemaVersion
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
 	return &config, nil
 }
 
+// isKnownSetting reports whether name is one of configFile's JSON fields,
+// case-insensitively like encoding/json.
+func isKnownSetting(name string) bool {
+	t := reflect.TypeFor[configFile]()
+	for i := range t.NumField() {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if tag != "" && strings.EqualFold(tag, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// warnUnknown logs the settings of the config at the given path which this
+// version doesn't support, and so ignores.
+func (c *configFile) warnUnknown(path string) {
+	if len(c.unknown) == 0 {
+		return
+	}
+	var names []string
+	for name := range c.unknown {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	slog.Warn("ignoring settings unsupported by this version of docker-credential-gcr", "path", path, "settings", strings.Join(names, ","))
+}
+
+// validate verifies that all of the configured values are supported.
+func (c *configFile) validate() error {
+	for _, source := range c.TokenSrcs {
+		if !isSupportedTokenSource(source) {
+			return fmt.Errorf("invalid value for \"TokenSources\": unsupported token source %q", source)
+		}
+	}
+	if c.AuditLogMaxSz < 0 {
+		return fmt.Errorf("invalid value for \"AuditLogMaxSize\": %d is negative", c.AuditLogMaxSz)
+	}
+	if _, err := parseTimeout(c.TotalTimeout); err != nil {
+		return fmt.Errorf("invalid value for \"Timeout\": %v", err)
+	}
+	if _, err := parseTimeout(c.SrcTimeout); err != nil {
+		return fmt.Errorf("invalid value for \"TokenSourceTimeout\": %v", err)
+	}
+	if c.SrcRetries != nil && *c.SrcRetries < 0 {
+		return fmt.Errorf("invalid value for \"TokenSourceRetries\": %d is negative", *c.SrcRetries)
+	}
+	if _, err := parseTimeout(c.SrcRetryBackoff); err != nil {
+		return fmt.Errorf("invalid value for \"TokenSourceRetryBackoff\": %v", err)
+	}
+	if c.InteractiveMode != "" && !InteractiveModes[c.InteractiveMode] {
+		return fmt.Errorf("invalid value for \"Interactive\": unsupported mode %q", c.InteractiveMode)
+	}
+	if c.Reauth != "" && !ReauthPolicies[c.Reauth] {
+		return fmt.Errorf("invalid value for \"ReauthPolicy\": unsupported policy %q", c.Reauth)
+	}
+	if c.ClientSecret != "" && c.ClientID == "" {
+		return fmt.Errorf("invalid value for \"OAuthClientSecret\": no \"OAuthClientId\" is set")
+	}
+	if c.ClientID != "" && c.ClientSecretsFile != "" {
+		return fmt.Errorf("invalid value for \"OAuthClientSecretsFile\": \"OAuthClientId\" is also set")
+	}
+	if _, err := parseTimeout(c.KeyRotation); err != nil {
+		return fmt.Errorf("invalid value for \"KeyRotationAge\": %v", err)
+	}
+	if strings.ContainsAny(c.MetadataSA, "/?#") {
+		return fmt.Errorf("invalid value for \"MetadataServiceAccount\": %q isn't a service account alias or email", c.MetadataSA)
+	}
+	if strings.ContainsAny(c.MetadataHostname, "/?#") {
+		return fmt.Errorf("invalid value for \"MetadataHost\": %q isn't a host", c.MetadataHostname)
+	}
+	if _, err := parseTimeout(c.MetadataCacheTTL); err != nil {
+		return fmt.Errorf("invalid value for \"MetadataNegativeCacheTTL\": %v", err)
+	}
+	for name, src := range c.ExecSrcs {
+		if err := src.validate(); err != nil {
+			return fmt.Errorf("invalid value for \"ExecSources\": %q: %v", name, err)
+		}
+	}
+	for pattern, sel := range c.GcloudRegs {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return fmt.Errorf("invalid value for \"GcloudRegistries\": invalid registry pattern %q", pattern)
+		}
+		if sel == (GcloudSelection{}) {
+			return fmt.Errorf("invalid value for \"GcloudRegistries\": no configuration or account for %q", pattern)
+		}
+	}
+	return nil
+}
+
 // TokenSources returns the configured token sources, or the DefaultTokenSources
 // if none are set.
 func (c *configFile) TokenSources() []string {
@@ -92,8 +369,257 @@ func (c *configFile) TokenSources() []string {
 	return ret
 }
 
+// Scopes returns the configured OAuth2 scopes, or GCRScopes if none are set.
+func (c *configFile) Scopes() []string {
+	if len(c.Scps) == 0 {
+		return GCRScopes
+	}
+	ret := make([]string, len(c.Scps))
+	copy(ret, c.Scps)
+	return ret
+}
+
+// AuditLog returns the path of the audit log, or "" if auditing is disabled.
+func (c *configFile) AuditLog() string {
+	return c.AuditLogPath
+}
+
+// AuditLogMaxSize returns the size, in bytes, at which the audit log is
+// rotated, or 0 for the default.
+func (c *configFile) AuditLogMaxSize() int64 {
+	return c.AuditLogMaxSz
+}
+
+// Timeout returns the deadline for retrieving credentials from all token
+// sources, or 0 if there is none.
+func (c *configFile) Timeout() time.Duration {
+	if c.TotalTimeout == "" {
+		return DefaultTimeout
+	}
+	d, _ := parseTimeout(c.TotalTimeout)
+	return d
+}
+
+// TokenSourceTimeout returns the deadline for retrieving credentials from a
+// single token source, or 0 if there is none.
+func (c *configFile) TokenSourceTimeout() time.Duration {
+	if c.SrcTimeout == "" {
+		return DefaultTokenSourceTimeout
+	}
+	d, _ := parseTimeout(c.SrcTimeout)
+	return d
+}
+
+// TokenSourceRetries returns the number of times a token source which failed
+// with a transient error is retried.
+func (c *configFile) TokenSourceRetries() int {
+	if c.SrcRetries == nil {
+		return DefaultTokenSourceRetries
+	}
+	return *c.SrcRetries
+}
+
+// TokenSourceRetryBackoff returns the delay before the first retry of a token
+// source, which doubles for each subsequent retry.
+func (c *configFile) TokenSourceRetryBackoff() time.Duration {
+	if c.SrcRetryBackoff == "" {
+		return DefaultTokenSourceRetryBackoff
+	}
+	d, _ := parseTimeout(c.SrcRetryBackoff)
+	return d
+}
+
+// Interactive returns whether the helper may interact with the user, e.g. by
+// opening a browser to reauthenticate: one of the InteractiveModes.
+func (c *configFile) Interactive() string {
+	if c.InteractiveMode == "" {
+		return InteractiveAuto
+	}
+	return c.InteractiveMode
+}
+
+// ReauthPolicy returns which failures to refresh the stored credentials send
+// the user to reauthenticate: one of the ReauthPolicies.
+func (c *configFile) ReauthPolicy() string {
+	if c.Reauth == "" {
+		return ReauthPolicyRAPT
+	}
+	return c.Reauth
+}
+
+// ReauthNotifyCommand returns the command and arguments to run when the stored
+// credentials need reauthentication, or nil if there's none.
+func (c *configFile) ReauthNotifyCommand() []string {
+	if c.ReauthNotifyCmd == "" {
+		return nil
+	}
+	return append([]string{c.ReauthNotifyCmd}, c.ReauthNotifyArgs...)
+}
+
+// OAuthClient returns the OAuth2 client used by gcr-login: that loaded from
+// OAuthClientSecretsFile, if set, else OAuthClientId and OAuthClientSecret,
+// else the DefaultOAuthClient.
+func (c *configFile) OAuthClient() (OAuthClient, error) {
+	switch {
+	case c.ClientSecretsFile != "":
+		return LoadClientSecrets(c.ClientSecretsFile)
+	case c.ClientID != "":
+		return OAuthClient{ID: c.ClientID, Secret: c.ClientSecret}, nil
+	}
+	return DefaultOAuthClient, nil
+}
+
+// LoginHint returns the account suggested to the user by gcr-login, or "".
+func (c *configFile) LoginHint() string {
+	return c.Hint
+}
+
+// HostedDomain returns the Google Workspace domain whose accounts gcr-login
+// offers the user, or "".
+func (c *configFile) HostedDomain() string {
+	return c.Domain
+}
+
+// CredsStore returns whether the helper is installed as Docker's global
+// credsStore, in which case it only serves Google's registries itself.
+func (c *configFile) CredsStore() bool {
+	return c.AsCredsStore != nil && *c.AsCredsStore
+}
+
+// CredsStoreDelegate returns the suffix of the credential helper, e.g. "pass",
+// to which requests for other registries are delegated in credsStore mode, or
+// "" if there's none. It's ignored outside of credsStore mode.
+func (c *configFile) CredsStoreDelegate() string {
+	return c.Delegate
+}
+
+// SetCredsStore sets (and persists) whether the helper is installed as
+// Docker's global credsStore, and the credential helper it delegates to.
+func (c *configFile) SetCredsStore(enabled bool, delegate string) error {
+	if !enabled && delegate != "" {
+		return fmt.Errorf("a credsStore delegate requires credsStore mode")
+	}
+	if c.CredsStore() == enabled && c.Delegate == delegate {
+		return nil
+	}
+	if enabled {
+		c.AsCredsStore = &enabled
+	} else {
+		c.AsCredsStore = nil
+	}
+	c.Delegate = delegate
+	return c.persist(c)
+}
+
+// AccessTokenFile returns the path of the file holding the access token served
+// by the "file" token source, or "" if there's none.
+func (c *configFile) AccessTokenFile() string {
+	return c.AccessTokenPath
+}
+
+// KeyFile returns the path of the service account key used by the "keyfile"
+// token source, or "" if there's none.
+func (c *configFile) KeyFile() string {
+	return c.KeyPath
+}
+
+// KeyFileSelfSignedJWT returns whether the "keyfile" token source issues
+// self-signed JWTs, rather than exchanging them for access tokens at the key's
+// token endpoint. It's true by default.
+func (c *configFile) KeyFileSelfSignedJWT() bool {
+	return c.KeySelfSignedJWT == nil || *c.KeySelfSignedJWT
+}
+
+// KeyRotationAge returns the age after which the "keyfile" token source warns
+// that its key should be rotated, or 0 if it never does.
+func (c *configFile) KeyRotationAge() time.Duration {
+	d, _ := parseTimeout(c.KeyRotation)
+	return d
+}
+
+// MetadataServiceAccount returns the alias, e.g. "default", or email of the
+// instance's service account used by the "metadata" token source.
+func (c *configFile) MetadataServiceAccount() string {
+	if c.MetadataSA == "" {
+		return DefaultMetadataServiceAccount
+	}
+	return c.MetadataSA
+}
+
+// MetadataHost returns the host, and optionally port, of the metadata server
+// used by the "metadata" token source, or "" for the default.
+func (c *configFile) MetadataHost() string {
+	return c.MetadataHostname
+}
+
+// MetadataNegativeCacheTTL returns how long the "metadata" token source
+// remembers that the metadata server couldn't be reached, or 0 if it doesn't.
+func (c *configFile) MetadataNegativeCacheTTL() time.Duration {
+	if c.MetadataCacheTTL == "" {
+		return DefaultMetadataNegativeCacheTTL
+	}
+	d, _ := parseTimeout(c.MetadataCacheTTL)
+	return d
+}
+
+// ExecSource returns the program run by the "exec:<name>" token source of the
+// given name, and whether it's configured.
+func (c *configFile) ExecSource(name string) (ExecSource, bool) {
+	src, ok := c.ExecSrcs[name]
+	return src, ok
+}
+
+// parseTimeout parses a non-negative duration, e.g. "30s". An unset timeout
+// parses as 0.
+func parseTimeout(v string) (time.Duration, error) {
+	if v == "" || v == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s is negative", v)
+	}
+	return d, nil
+}
+
+// GcloudSelection returns the gcloud configuration and account to use for the
+// given registry: those of the most specific matching GcloudRegistries entry,
+// if any, else the GcloudConfiguration and GcloudAccount settings.
+func (c *configFile) GcloudSelection(serverURL string) GcloudSelection {
+	if sel, ok := c.gcloudRegistry(serverURL); ok {
+		return sel
+	}
+	return GcloudSelection{Configuration: c.GcloudConfig, Account: c.GcloudAcct}
+}
+
+// gcloudRegistry returns the GcloudRegistries entry matching the given
+// registry. An exact match is preferred, followed by the longest matching
+// pattern.
+func (c *configFile) gcloudRegistry(serverURL string) (GcloudSelection, bool) {
+	host := RegistryHost(serverURL)
+	if sel, ok := c.GcloudRegs[host]; ok {
+		return sel, true
+	}
+	var best string
+	for pattern := range c.GcloudRegs {
+		if matched, _ := path.Match(pattern, host); !matched {
+			continue
+		}
+		if len(pattern) > len(best) || (len(pattern) == len(best) && pattern < best) {
+			best = pattern
+		}
+	}
+	if best == "" {
+		return GcloudSelection{}, false
+	}
+	return c.GcloudRegs[best], true
+}
+
 // SetTokenSources sets (and persists) the token sources. Valid token sources
-// are defined by config.SupportedGCRTokenSources.
+// are defined by config.SupportedGCRTokenSources, along with "exec:<name>".
 func (c *configFile) SetTokenSources(newSources []string) error {
 	if len(newSources) == 0 {
 		newSources = nil
@@ -104,7 +630,7 @@ func (c *configFile) SetTokenSources(newSources []string) error {
 	}
 
 	for _, source := range newSources {
-		if _, supported := SupportedGCRTokenSources[source]; !supported {
+		if !isSupportedTokenSource(source) {
 			return fmt.Errorf("Unsupported token source: %s", source)
 		}
 	}
@@ -121,7 +647,24 @@ func persist(c *configFile) error {
 	}
 	defer f.Close()
 
-	return json.NewEncoder(f).Encode(c)
+	c.SchemaVersion = currentSchemaVersion
+	c.path = f.Name()
+	if len(c.unknown) == 0 {
+		return json.NewEncoder(f).Encode(c)
+	}
+	// Preserve the settings written by a newer binary.
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for name, v := range c.unknown {
+		fields[name] = v
+	}
+	return json.NewEncoder(f).Encode(fields)
 }
 
 func equal(a, b []string) bool {
@@ -148,7 +691,7 @@ func (c *configFile) ResetAll() error {
 	if err != nil {
 		return err
 	}
-	c.TokenSrcs = nil
+	*c = configFile{SchemaVersion: currentSchemaVersion, persist: c.persist}
 	return nil
 }
 
@@ -176,6 +719,11 @@ func createConfigFile() (*os.File, error) {
 	return os.Create(path)
 }
 
+// UserConfigPath returns the full path of the user config file.
+func UserConfigPath() (string, error) {
+	return configPath()
+}
+
 // configPath returns the full path of our user config file.
 func configPath() (string, error) {
 	if path := os.Getenv(configFileEnvVariable); strings.TrimSpace(path) != "" {
@@ -0,0 +1,994 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Settings are resolved from the following layers, in decreasing order of
+precedence:
+
+ 0. Settings pinned by the administrator's policy file,
+    /etc/docker-credential-gcr/policy.json. See policy.
+ 1. Command-line flags passed before the subcommand, e.g.
+    `docker-credential-gcr --token-source=env get`.
+ 2. Environment variables, e.g. DOCKER_CREDENTIAL_GCR_TOKEN_SOURCES.
+ 3. The user config file, see DOCKER_CREDENTIAL_GCR_CONFIG.
+ 4. The system-wide config file, /etc/docker-credential-gcr/config.json
+    (%ProgramData%\docker-credential-gcr\config.json on Windows), see
+    DOCKER_CREDENTIAL_GCR_SYSTEM_CONFIG.
+ 5. The built-in defaults.
+
+Each setting is resolved independently: the first layer which sets a value
+for it wins. Only the user config file is ever written by the helper.
+*/
+
+const (
+	systemConfigFileEnvVariable = "DOCKER_CREDENTIAL_GCR_SYSTEM_CONFIG"
+	systemConfigDirName         = "docker-credential-gcr"
+	systemConfigFileName        = "config.json"
+)
+
+// redacted is displayed in place of a secret setting's value.
+const redacted = "(redacted)"
+
+// OriginDefault is the Setting.Origin of a setting which has not been
+// configured in any layer.
+const OriginDefault = "default"
+
+// settingDef describes a user-configurable setting and how it may be
+// overridden.
+type settingDef struct {
+	// name is the setting's key in the config files.
+	name string
+	// envVar is the environment variable which overrides the setting.
+	envVar string
+	// flag is the global command-line flag which overrides the setting.
+	flag  string
+	usage string
+
+	// isSet reports whether the setting is set in the given layer.
+	isSet func(*configFile) bool
+	// value returns the setting's value in the given layer, formatted for
+	// display.
+	value func(*configFile) string
+	// parse sets the setting in the given layer from an env var or flag value.
+	parse func(*configFile, string) error
+}
+
+var tokenSourcesSetting = &settingDef{
+	name:   "TokenSources",
+	envVar: "DOCKER_CREDENTIAL_GCR_TOKEN_SOURCES",
+	flag:   "token-source",
+	usage:  "Overrides the comma-separated source(s), in order, to search for credentials",
+	isSet:  func(c *configFile) bool { return len(c.TokenSrcs) != 0 },
+	value:  func(c *configFile) string { return strings.Join(c.TokenSources(), ",") },
+	parse: func(c *configFile, v string) error {
+		c.TokenSrcs = splitList(v)
+		return nil
+	},
+}
+
+var scopesSetting = &settingDef{
+	name:   "Scopes",
+	envVar: "DOCKER_CREDENTIAL_GCR_SCOPES",
+	flag:   "scopes",
+	usage:  "Overrides the comma-separated OAuth2 scope(s) to request",
+	isSet:  func(c *configFile) bool { return len(c.Scps) != 0 },
+	value:  func(c *configFile) string { return strings.Join(c.Scopes(), ",") },
+	parse: func(c *configFile, v string) error {
+		c.Scps = splitList(v)
+		return nil
+	},
+}
+
+var auditLogSetting = &settingDef{
+	name:   "AuditLog",
+	envVar: "DOCKER_CREDENTIAL_GCR_AUDIT_LOG",
+	flag:   "audit-log",
+	usage:  "Overrides the path of the audit log of issued credentials",
+	isSet:  func(c *configFile) bool { return c.AuditLogPath != "" },
+	value:  func(c *configFile) string { return c.AuditLogPath },
+	parse: func(c *configFile, v string) error {
+		c.AuditLogPath = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var auditLogMaxSizeSetting = &settingDef{
+	name:   "AuditLogMaxSize",
+	envVar: "DOCKER_CREDENTIAL_GCR_AUDIT_LOG_MAX_SIZE",
+	flag:   "audit-log-max-size",
+	usage:  "Overrides the size, in bytes, at which the audit log is rotated",
+	isSet:  func(c *configFile) bool { return c.AuditLogMaxSz != 0 },
+	value:  func(c *configFile) string { return strconv.FormatInt(c.AuditLogMaxSz, 10) },
+	parse: func(c *configFile, v string) error {
+		var err error
+		c.AuditLogMaxSz, err = strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return err
+	},
+}
+
+var gcloudConfigurationSetting = &settingDef{
+	name:   "GcloudConfiguration",
+	envVar: "DOCKER_CREDENTIAL_GCR_GCLOUD_CONFIGURATION",
+	flag:   "gcloud-configuration",
+	usage:  "Overrides the gcloud named configuration used by the gcloud token sources",
+	isSet:  func(c *configFile) bool { return c.GcloudConfig != "" },
+	value:  func(c *configFile) string { return c.GcloudConfig },
+	parse: func(c *configFile, v string) error {
+		c.GcloudConfig = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var gcloudAccountSetting = &settingDef{
+	name:   "GcloudAccount",
+	envVar: "DOCKER_CREDENTIAL_GCR_GCLOUD_ACCOUNT",
+	flag:   "gcloud-account",
+	usage:  "Overrides the gcloud account used by the gcloud token sources",
+	isSet:  func(c *configFile) bool { return c.GcloudAcct != "" },
+	value:  func(c *configFile) string { return c.GcloudAcct },
+	parse: func(c *configFile, v string) error {
+		c.GcloudAcct = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var gcloudRegistriesSetting = &settingDef{
+	name:   "GcloudRegistries",
+	envVar: "DOCKER_CREDENTIAL_GCR_GCLOUD_REGISTRIES",
+	flag:   "gcloud-registries",
+	usage:  `Overrides the per-registry gcloud configuration and account, as JSON, e.g. {"us-docker.pkg.dev":{"Configuration":"prod"}}`,
+	isSet:  func(c *configFile) bool { return len(c.GcloudRegs) != 0 },
+	value: func(c *configFile) string {
+		if len(c.GcloudRegs) == 0 {
+			return ""
+		}
+		// Map keys are sorted, so this is stable.
+		b, _ := json.Marshal(c.GcloudRegs)
+		return string(b)
+	},
+	parse: func(c *configFile, v string) error {
+		return json.Unmarshal([]byte(v), &c.GcloudRegs)
+	},
+}
+
+var timeoutSetting = &settingDef{
+	name:   "Timeout",
+	envVar: "DOCKER_CREDENTIAL_GCR_TIMEOUT",
+	flag:   "timeout",
+	usage:  `Overrides the deadline for retrieving credentials from all token sources, e.g. "1m", or "0" for none`,
+	isSet:  func(c *configFile) bool { return c.TotalTimeout != "" },
+	value:  func(c *configFile) string { return c.Timeout().String() },
+	parse: func(c *configFile, v string) error {
+		c.TotalTimeout = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var tokenSourceTimeoutSetting = &settingDef{
+	name:   "TokenSourceTimeout",
+	envVar: "DOCKER_CREDENTIAL_GCR_TOKEN_SOURCE_TIMEOUT",
+	flag:   "token-source-timeout",
+	usage:  `Overrides the deadline for retrieving credentials from each token source, e.g. "30s", or "0" for none`,
+	isSet:  func(c *configFile) bool { return c.SrcTimeout != "" },
+	value:  func(c *configFile) string { return c.TokenSourceTimeout().String() },
+	parse: func(c *configFile, v string) error {
+		c.SrcTimeout = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var tokenSourceRetriesSetting = &settingDef{
+	name:   "TokenSourceRetries",
+	envVar: "DOCKER_CREDENTIAL_GCR_TOKEN_SOURCE_RETRIES",
+	flag:   "token-source-retries",
+	usage:  "Overrides the number of times a token source which failed with a transient error is retried",
+	isSet:  func(c *configFile) bool { return c.SrcRetries != nil },
+	value:  func(c *configFile) string { return strconv.Itoa(c.TokenSourceRetries()) },
+	parse: func(c *configFile, v string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return err
+		}
+		c.SrcRetries = &n
+		return nil
+	},
+}
+
+var tokenSourceRetryBackoffSetting = &settingDef{
+	name:   "TokenSourceRetryBackoff",
+	envVar: "DOCKER_CREDENTIAL_GCR_TOKEN_SOURCE_RETRY_BACKOFF",
+	flag:   "token-source-retry-backoff",
+	usage:  `Overrides the delay before the first retry of a token source, e.g. "250ms", which doubles for each subsequent retry`,
+	isSet:  func(c *configFile) bool { return c.SrcRetryBackoff != "" },
+	value:  func(c *configFile) string { return c.TokenSourceRetryBackoff().String() },
+	parse: func(c *configFile, v string) error {
+		c.SrcRetryBackoff = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var interactiveSetting = &settingDef{
+	name:   "Interactive",
+	envVar: "DOCKER_CREDENTIAL_GCR_INTERACTIVE",
+	flag:   "interactive",
+	usage:  `Overrides whether the helper may open a browser to reauthenticate: "auto" (if attached to a terminal), "always" or "never"`,
+	isSet:  func(c *configFile) bool { return c.InteractiveMode != "" },
+	value:  func(c *configFile) string { return c.Interactive() },
+	parse: func(c *configFile, v string) error {
+		c.InteractiveMode = strings.ToLower(strings.TrimSpace(v))
+		return nil
+	},
+}
+
+var reauthPolicySetting = &settingDef{
+	name:   "ReauthPolicy",
+	envVar: "DOCKER_CREDENTIAL_GCR_REAUTH_POLICY",
+	flag:   "reauth-policy",
+	usage:  `Overrides which failures to refresh the stored credentials open a browser to sign in again: "rapt" (reauthentication challenges), "any" or "never"`,
+	isSet:  func(c *configFile) bool { return c.Reauth != "" },
+	value:  func(c *configFile) string { return c.ReauthPolicy() },
+	parse: func(c *configFile, v string) error {
+		c.Reauth = strings.ToLower(strings.TrimSpace(v))
+		return nil
+	},
+}
+
+var reauthNotifyCommandSetting = &settingDef{
+	name:   "ReauthNotifyCommand",
+	envVar: "DOCKER_CREDENTIAL_GCR_REAUTH_NOTIFY_COMMAND",
+	flag:   "reauth-notify-command",
+	usage:  "Overrides the program run, with ReauthNotifyArgs and a message as its arguments, when the stored credentials need reauthentication",
+	isSet:  func(c *configFile) bool { return c.ReauthNotifyCmd != "" },
+	value:  func(c *configFile) string { return c.ReauthNotifyCmd },
+	parse: func(c *configFile, v string) error {
+		c.ReauthNotifyCmd = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var reauthNotifyArgsSetting = &settingDef{
+	name:   "ReauthNotifyArgs",
+	envVar: "DOCKER_CREDENTIAL_GCR_REAUTH_NOTIFY_ARGS",
+	flag:   "reauth-notify-args",
+	usage:  `Overrides the arguments passed to ReauthNotifyCommand before the message, as a JSON array, e.g. ["--urgency=critical"]`,
+	isSet:  func(c *configFile) bool { return len(c.ReauthNotifyArgs) != 0 },
+	value: func(c *configFile) string {
+		if len(c.ReauthNotifyArgs) == 0 {
+			return ""
+		}
+		b, _ := json.Marshal(c.ReauthNotifyArgs)
+		return string(b)
+	},
+	parse: func(c *configFile, v string) error {
+		return json.Unmarshal([]byte(v), &c.ReauthNotifyArgs)
+	},
+}
+
+var oauthClientIDSetting = &settingDef{
+	name:   "OAuthClientId",
+	envVar: "DOCKER_CREDENTIAL_GCR_OAUTH_CLIENT_ID",
+	flag:   "oauth-client-id",
+	usage:  "Overrides the ID of the OAuth2 client used by gcr-login",
+	isSet:  func(c *configFile) bool { return c.ClientID != "" },
+	value:  func(c *configFile) string { return c.ClientID },
+	parse: func(c *configFile, v string) error {
+		c.ClientID = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var oauthClientSecretSetting = &settingDef{
+	name:   "OAuthClientSecret",
+	envVar: "DOCKER_CREDENTIAL_GCR_OAUTH_CLIENT_SECRET",
+	flag:   "oauth-client-secret",
+	usage:  "Overrides the secret of the OAuth2 client used by gcr-login",
+	isSet:  func(c *configFile) bool { return c.ClientSecret != "" },
+	value: func(c *configFile) string {
+		if c.ClientSecret == "" {
+			return ""
+		}
+		return redacted
+	},
+	parse: func(c *configFile, v string) error {
+		c.ClientSecret = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var oauthClientSecretsFileSetting = &settingDef{
+	name:   "OAuthClientSecretsFile",
+	envVar: "DOCKER_CREDENTIAL_GCR_OAUTH_CLIENT_SECRETS_FILE",
+	flag:   "oauth-client-secrets-file",
+	usage:  "Overrides the path of the client_secrets.json file of the OAuth2 client used by gcr-login",
+	isSet:  func(c *configFile) bool { return c.ClientSecretsFile != "" },
+	value:  func(c *configFile) string { return c.ClientSecretsFile },
+	parse: func(c *configFile, v string) error {
+		c.ClientSecretsFile = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var loginHintSetting = &settingDef{
+	name:   "LoginHint",
+	envVar: "DOCKER_CREDENTIAL_GCR_LOGIN_HINT",
+	flag:   "login-hint",
+	usage:  "Overrides the account, e.g. an email address, suggested to the user by gcr-login",
+	isSet:  func(c *configFile) bool { return c.Hint != "" },
+	value:  func(c *configFile) string { return c.Hint },
+	parse: func(c *configFile, v string) error {
+		c.Hint = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var hostedDomainSetting = &settingDef{
+	name:   "HostedDomain",
+	envVar: "DOCKER_CREDENTIAL_GCR_HOSTED_DOMAIN",
+	flag:   "hosted-domain",
+	usage:  "Overrides the Google Workspace domain whose accounts gcr-login offers the user",
+	isSet:  func(c *configFile) bool { return c.Domain != "" },
+	value:  func(c *configFile) string { return c.Domain },
+	parse: func(c *configFile, v string) error {
+		c.Domain = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var credsStoreSetting = &settingDef{
+	name:   "CredsStore",
+	envVar: "DOCKER_CREDENTIAL_GCR_CREDS_STORE",
+	flag:   "creds-store",
+	usage:  "Overrides whether the helper is installed as Docker's global credsStore, only serving Google's registries itself",
+	isSet:  func(c *configFile) bool { return c.AsCredsStore != nil },
+	value:  func(c *configFile) string { return strconv.FormatBool(c.CredsStore()) },
+	parse: func(c *configFile, v string) error {
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return err
+		}
+		c.AsCredsStore = &b
+		return nil
+	},
+}
+
+var credsStoreDelegateSetting = &settingDef{
+	name:   "CredsStoreDelegate",
+	envVar: "DOCKER_CREDENTIAL_GCR_CREDS_STORE_DELEGATE",
+	flag:   "creds-store-delegate",
+	usage:  `Overrides the credential helper, e.g. "pass", to which other registries are delegated in credsStore mode`,
+	isSet:  func(c *configFile) bool { return c.Delegate != "" },
+	value:  func(c *configFile) string { return c.Delegate },
+	parse: func(c *configFile, v string) error {
+		c.Delegate = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var accessTokenFileSetting = &settingDef{
+	name:   "AccessTokenFile",
+	envVar: "DOCKER_CREDENTIAL_GCR_ACCESS_TOKEN_FILE",
+	flag:   "access-token-file",
+	usage:  `Overrides the path of the file holding the access token served by the "file" token source`,
+	isSet:  func(c *configFile) bool { return c.AccessTokenPath != "" },
+	value:  func(c *configFile) string { return c.AccessTokenPath },
+	parse: func(c *configFile, v string) error {
+		c.AccessTokenPath = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var keyFileSetting = &settingDef{
+	name:   "KeyFile",
+	envVar: "DOCKER_CREDENTIAL_GCR_KEYFILE",
+	flag:   "keyfile",
+	usage:  `Overrides the path of the service account key used by the "keyfile" token source`,
+	isSet:  func(c *configFile) bool { return c.KeyPath != "" },
+	value:  func(c *configFile) string { return c.KeyPath },
+	parse: func(c *configFile, v string) error {
+		c.KeyPath = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var keyFileSelfSignedJWTSetting = &settingDef{
+	name:   "KeyFileSelfSignedJWT",
+	envVar: "DOCKER_CREDENTIAL_GCR_KEYFILE_SELF_SIGNED_JWT",
+	flag:   "keyfile-self-signed-jwt",
+	usage:  `Overrides whether the "keyfile" token source issues self-signed JWTs, rather than exchanging them at the key's token endpoint`,
+	isSet:  func(c *configFile) bool { return c.KeySelfSignedJWT != nil },
+	value:  func(c *configFile) string { return strconv.FormatBool(c.KeyFileSelfSignedJWT()) },
+	parse: func(c *configFile, v string) error {
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return err
+		}
+		c.KeySelfSignedJWT = &b
+		return nil
+	},
+}
+
+var keyRotationAgeSetting = &settingDef{
+	name:   "KeyRotationAge",
+	envVar: "DOCKER_CREDENTIAL_GCR_KEY_ROTATION_AGE",
+	flag:   "key-rotation-age",
+	usage:  `Overrides the age of the "keyfile" token source's key after which it warns that the key should be rotated, e.g. "2160h", or "0" for never`,
+	isSet:  func(c *configFile) bool { return c.KeyRotation != "" },
+	value:  func(c *configFile) string { return c.KeyRotationAge().String() },
+	parse: func(c *configFile, v string) error {
+		c.KeyRotation = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var metadataServiceAccountSetting = &settingDef{
+	name:   "MetadataServiceAccount",
+	envVar: "DOCKER_CREDENTIAL_GCR_METADATA_SERVICE_ACCOUNT",
+	flag:   "metadata-service-account",
+	usage:  `Overrides the alias or email of the instance's service account used by the "metadata" token source`,
+	isSet:  func(c *configFile) bool { return c.MetadataSA != "" },
+	value:  func(c *configFile) string { return c.MetadataServiceAccount() },
+	parse: func(c *configFile, v string) error {
+		c.MetadataSA = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var metadataHostSetting = &settingDef{
+	name:   "MetadataHost",
+	envVar: "DOCKER_CREDENTIAL_GCR_METADATA_HOST",
+	flag:   "metadata-host",
+	usage:  `Overrides the host of the metadata server used by the "metadata" token source`,
+	isSet:  func(c *configFile) bool { return c.MetadataHostname != "" },
+	value:  func(c *configFile) string { return c.MetadataHostname },
+	parse: func(c *configFile, v string) error {
+		c.MetadataHostname = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var metadataNegativeCacheTTLSetting = &settingDef{
+	name:   "MetadataNegativeCacheTTL",
+	envVar: "DOCKER_CREDENTIAL_GCR_METADATA_NEGATIVE_CACHE_TTL",
+	flag:   "metadata-negative-cache-ttl",
+	usage:  `Overrides how long the "metadata" token source remembers that the metadata server couldn't be reached, e.g. "1h", or "0" for not at all`,
+	isSet:  func(c *configFile) bool { return c.MetadataCacheTTL != "" },
+	value:  func(c *configFile) string { return c.MetadataNegativeCacheTTL().String() },
+	parse: func(c *configFile, v string) error {
+		c.MetadataCacheTTL = strings.TrimSpace(v)
+		return nil
+	},
+}
+
+var execSourcesSetting = &settingDef{
+	name:   "ExecSources",
+	envVar: "DOCKER_CREDENTIAL_GCR_EXEC_SOURCES",
+	flag:   "exec-sources",
+	usage:  `Overrides the programs run by "exec:<name>" token sources, as JSON, e.g. {"broker":{"Command":"broker","Args":["token"],"Format":"json"}}`,
+	isSet:  func(c *configFile) bool { return len(c.ExecSrcs) != 0 },
+	value: func(c *configFile) string {
+		if len(c.ExecSrcs) == 0 {
+			return ""
+		}
+		// Map keys are sorted, so this is stable.
+		b, _ := json.Marshal(c.ExecSrcs)
+		return string(b)
+	},
+	parse: func(c *configFile, v string) error {
+		return json.Unmarshal([]byte(v), &c.ExecSrcs)
+	},
+}
+
+// settingDefs lists every user-configurable setting.
+var settingDefs = []*settingDef{
+	tokenSourcesSetting,
+	scopesSetting,
+	auditLogSetting,
+	auditLogMaxSizeSetting,
+	gcloudConfigurationSetting,
+	gcloudAccountSetting,
+	gcloudRegistriesSetting,
+	timeoutSetting,
+	tokenSourceTimeoutSetting,
+	tokenSourceRetriesSetting,
+	tokenSourceRetryBackoffSetting,
+	interactiveSetting,
+	reauthPolicySetting,
+	reauthNotifyCommandSetting,
+	reauthNotifyArgsSetting,
+	oauthClientIDSetting,
+	oauthClientSecretSetting,
+	oauthClientSecretsFileSetting,
+	loginHintSetting,
+	hostedDomainSetting,
+	credsStoreSetting,
+	credsStoreDelegateSetting,
+	accessTokenFileSetting,
+	keyFileSetting,
+	keyFileSelfSignedJWTSetting,
+	keyRotationAgeSetting,
+	metadataServiceAccountSetting,
+	metadataHostSetting,
+	metadataNegativeCacheTTLSetting,
+	execSourcesSetting,
+}
+
+// flagOverrides holds the raw values of any global flags registered via
+// RegisterFlags, keyed by setting name.
+var flagOverrides = map[string]string{}
+
+// RegisterFlags registers a global command-line flag for each setting which
+// may be overridden on the command line. It must be called before fs is
+// parsed.
+func RegisterFlags(fs *flag.FlagSet) {
+	for _, def := range settingDefs {
+		if def.flag == "" {
+			continue
+		}
+		name := def.name
+		fs.Func(def.flag, def.usage, func(v string) error {
+			flagOverrides[name] = v
+			return nil
+		})
+	}
+}
+
+// layer is a single source of configuration.
+type layer struct {
+	// origin describes where the given setting came from in this layer.
+	origin func(*settingDef) string
+	file   *configFile
+	// admin is set for the layers only an administrator can write: the policy
+	// and the system config.
+	admin bool
+}
+
+// fileOrigin returns a layer origin which reports the given file path.
+func fileOrigin(path string) func(*settingDef) string {
+	return func(*settingDef) string { return path }
+}
+
+// layeredConfig is a UserConfig which resolves each setting from its
+// configuration layers, in order. Changes are persisted to the user layer.
+type layeredConfig struct {
+	// layers, in decreasing order of precedence, excluding the defaults.
+	layers []layer
+	user   *configFile
+	// policy restricts the resolved settings, may be nil.
+	policy *policy
+}
+
+func loadLayers(user *configFile, userOrigin string) (*layeredConfig, error) {
+	var layers []layer
+
+	pol, err := loadPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if pol != nil {
+		layers = append(layers, pol.layer())
+	}
+
+	flagOrigin := func(def *settingDef) string { return "--" + def.flag }
+	flagLayer, err := overrideLayer(flagOrigin, func(def *settingDef) (string, bool) {
+		v, ok := flagOverrides[def.name]
+		return v, ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	layers = append(layers, layer{origin: flagOrigin, file: flagLayer})
+
+	envOrigin := func(def *settingDef) string { return "$" + def.envVar }
+	envLayer, err := overrideLayer(envOrigin, func(def *settingDef) (string, bool) {
+		v := os.Getenv(def.envVar)
+		return v, strings.TrimSpace(v) != ""
+	})
+	if err != nil {
+		return nil, err
+	}
+	layers = append(layers, layer{origin: envOrigin, file: envLayer})
+
+	layers = append(layers, layer{origin: fileOrigin(userOrigin), file: user})
+
+	system, systemOrigin, err := loadSystemConfig()
+	if err != nil {
+		return nil, err
+	}
+	if system != nil {
+		layers = append(layers, layer{origin: fileOrigin(systemOrigin), file: system, admin: true})
+	}
+
+	return &layeredConfig{layers: layers, user: user, policy: pol}, nil
+}
+
+// overrideLayer builds a layer from string overrides, such as flags or
+// environment variables. lookup returns the override's value and whether it
+// was set.
+func overrideLayer(origin func(*settingDef) string, lookup func(*settingDef) (string, bool)) (*configFile, error) {
+	c := &configFile{}
+	for _, def := range settingDefs {
+		v, ok := lookup(def)
+		if !ok {
+			continue
+		}
+		name := origin(def)
+		if err := def.parse(c, v); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %v", name, err)
+		}
+		if err := c.validate(); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %v", name, err)
+		}
+	}
+	return c, nil
+}
+
+// loadSystemConfig loads the system-wide config file, returning nil if it
+// doesn't exist.
+func loadSystemConfig() (*configFile, string, error) {
+	path := systemConfigPath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, path, nil
+		}
+		return nil, path, err
+	}
+	c, err := decode(data)
+	if err != nil {
+		return nil, path, fmt.Errorf("failed to load system config from %s: %v", path, err)
+	}
+	c.path = path
+	c.warnUnknown(path)
+	return c, path, nil
+}
+
+// systemConfigPath returns the full path of the system-wide config file.
+func systemConfigPath() string {
+	if path := os.Getenv(systemConfigFileEnvVariable); strings.TrimSpace(path) != "" {
+		return path
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), systemConfigDirName, systemConfigFileName)
+	}
+	return filepath.Join("/etc", systemConfigDirName, systemConfigFileName)
+}
+
+// effective returns the highest-precedence layer which sets the given setting,
+// or the defaults.
+func (c *layeredConfig) effective(def *settingDef) *configFile {
+	for _, l := range c.layers {
+		if def.isSet(l.file) {
+			return l.file
+		}
+	}
+	return &configFile{}
+}
+
+// effectiveAny returns the highest-precedence layer which sets any of the given
+// settings, or the defaults, for settings which only make sense together.
+func (c *layeredConfig) effectiveAny(defs ...*settingDef) *configFile {
+	for _, l := range c.layers {
+		for _, def := range defs {
+			if def.isSet(l.file) {
+				return l.file
+			}
+		}
+	}
+	return &configFile{}
+}
+
+// trusts reports whether the given setting may be taken from the given layer:
+// when policy names an "exec:<name>" token source, ExecSources are only taken
+// from the administrator's layers.
+func (c *layeredConfig) trusts(def *settingDef, l layer) bool {
+	return l.admin || def != execSourcesSetting || !c.policy.namesExecSource()
+}
+
+// precedence returns the index of the highest-precedence layer which sets the
+// given setting, or len(c.layers) if none does.
+func (c *layeredConfig) precedence(def *settingDef) int {
+	for i, l := range c.layers {
+		if def.isSet(l.file) {
+			return i
+		}
+	}
+	return len(c.layers)
+}
+
+// TokenSources returns the effective token sources, excluding any which are
+// forbidden by policy.
+func (c *layeredConfig) TokenSources() []string {
+	var ret []string
+	for _, source := range c.effective(tokenSourcesSetting).TokenSources() {
+		if c.policy.allowsTokenSource(source) {
+			ret = append(ret, source)
+		}
+	}
+	return ret
+}
+
+// AllowsTokenSource reports whether policy permits the given token source.
+func (c *layeredConfig) AllowsTokenSource(source string) bool {
+	return c.policy.allowsTokenSource(source)
+}
+
+// SetTokenSources sets (and persists) the token sources in the user config,
+// unless they are forbidden by policy.
+func (c *layeredConfig) SetTokenSources(newSources []string) error {
+	if err := c.policy.checkTokenSources(newSources); err != nil {
+		return err
+	}
+	return c.user.SetTokenSources(newSources)
+}
+
+// Scopes returns the effective OAuth2 scopes.
+func (c *layeredConfig) Scopes() []string {
+	return c.effective(scopesSetting).Scopes()
+}
+
+// ScopesPinned reports whether policy pins the OAuth2 scopes, which mustn't
+// then be widened.
+func (c *layeredConfig) ScopesPinned() bool {
+	return c.policy != nil && len(c.policy.Scopes) > 0
+}
+
+// AuditLog returns the effective path of the audit log, or "" if auditing is
+// disabled.
+func (c *layeredConfig) AuditLog() string {
+	return c.effective(auditLogSetting).AuditLog()
+}
+
+// AuditLogMaxSize returns the effective size, in bytes, at which the audit log
+// is rotated, or 0 for the default.
+func (c *layeredConfig) AuditLogMaxSize() int64 {
+	return c.effective(auditLogMaxSizeSetting).AuditLogMaxSize()
+}
+
+// GcloudSelection returns the gcloud configuration and account to use for the
+// given registry, from the highest-precedence layer which either has a
+// GcloudRegistries entry matching it or sets GcloudConfiguration or
+// GcloudAccount. Within a layer, the matching entry is preferred. The
+// GcloudConfiguration and GcloudAccount settings are then each resolved as
+// usual.
+func (c *layeredConfig) GcloudSelection(serverURL string) GcloudSelection {
+	for _, l := range c.layers {
+		if sel, ok := l.file.gcloudRegistry(serverURL); ok {
+			return sel
+		}
+		if gcloudConfigurationSetting.isSet(l.file) || gcloudAccountSetting.isSet(l.file) {
+			break
+		}
+	}
+	return GcloudSelection{
+		Configuration: c.effective(gcloudConfigurationSetting).GcloudConfig,
+		Account:       c.effective(gcloudAccountSetting).GcloudAcct,
+	}
+}
+
+// Timeout returns the effective deadline for retrieving credentials from all
+// token sources, or 0 if there is none.
+func (c *layeredConfig) Timeout() time.Duration {
+	return c.effective(timeoutSetting).Timeout()
+}
+
+// TokenSourceTimeout returns the effective deadline for retrieving credentials
+// from a single token source, or 0 if there is none.
+func (c *layeredConfig) TokenSourceTimeout() time.Duration {
+	return c.effective(tokenSourceTimeoutSetting).TokenSourceTimeout()
+}
+
+// TokenSourceRetries returns the effective number of times a token source
+// which failed with a transient error is retried.
+func (c *layeredConfig) TokenSourceRetries() int {
+	return c.effective(tokenSourceRetriesSetting).TokenSourceRetries()
+}
+
+// TokenSourceRetryBackoff returns the effective delay before the first retry of
+// a token source.
+func (c *layeredConfig) TokenSourceRetryBackoff() time.Duration {
+	return c.effective(tokenSourceRetryBackoffSetting).TokenSourceRetryBackoff()
+}
+
+// Interactive returns the effective interactivity mode, one of the
+// InteractiveModes.
+func (c *layeredConfig) Interactive() string {
+	return c.effective(interactiveSetting).Interactive()
+}
+
+// ReauthPolicy returns the effective reauthentication policy, one of the
+// ReauthPolicies.
+func (c *layeredConfig) ReauthPolicy() string {
+	return c.effective(reauthPolicySetting).ReauthPolicy()
+}
+
+// ReauthNotifyCommand returns the effective command and arguments to run when
+// the stored credentials need reauthentication, or nil if there's none. The
+// program and its arguments are taken together from the highest-precedence
+// layer which sets either, so that arguments meant for one program aren't
+// passed to another.
+func (c *layeredConfig) ReauthNotifyCommand() []string {
+	return c.effectiveAny(reauthNotifyCommandSetting, reauthNotifyArgsSetting).ReauthNotifyCommand()
+}
+
+// OAuthClient returns the effective OAuth2 client used by gcr-login. The
+// higher-precedence of OAuthClientSecretsFile and OAuthClientId wins; the
+// secret of an OAuthClientId is its effective OAuthClientSecret.
+func (c *layeredConfig) OAuthClient() (OAuthClient, error) {
+	id, file := c.precedence(oauthClientIDSetting), c.precedence(oauthClientSecretsFileSetting)
+	switch {
+	case file < id:
+		return LoadClientSecrets(c.layers[file].file.ClientSecretsFile)
+	case id < len(c.layers):
+		return OAuthClient{
+			ID:     c.layers[id].file.ClientID,
+			Secret: c.effective(oauthClientSecretSetting).ClientSecret,
+		}, nil
+	}
+	return DefaultOAuthClient, nil
+}
+
+// LoginHint returns the effective account suggested to the user by gcr-login,
+// or "".
+func (c *layeredConfig) LoginHint() string {
+	return c.effective(loginHintSetting).LoginHint()
+}
+
+// HostedDomain returns the effective Google Workspace domain whose accounts
+// gcr-login offers the user, or "".
+func (c *layeredConfig) HostedDomain() string {
+	return c.effective(hostedDomainSetting).HostedDomain()
+}
+
+// CredsStore returns whether the helper is effectively installed as Docker's
+// global credsStore.
+func (c *layeredConfig) CredsStore() bool {
+	return c.effective(credsStoreSetting).CredsStore()
+}
+
+// CredsStoreDelegate returns the effective credential helper to which other
+// registries are delegated in credsStore mode, or "" if there's none.
+func (c *layeredConfig) CredsStoreDelegate() string {
+	return c.effective(credsStoreDelegateSetting).CredsStoreDelegate()
+}
+
+// AccessTokenFile returns the effective path of the file holding the access
+// token served by the "file" token source, or "" if there's none.
+func (c *layeredConfig) AccessTokenFile() string {
+	return c.effective(accessTokenFileSetting).AccessTokenFile()
+}
+
+// KeyFile returns the effective path of the service account key used by the
+// "keyfile" token source, or "" if there's none.
+func (c *layeredConfig) KeyFile() string {
+	return c.effective(keyFileSetting).KeyFile()
+}
+
+// KeyFileSelfSignedJWT returns whether the "keyfile" token source effectively
+// issues self-signed JWTs.
+func (c *layeredConfig) KeyFileSelfSignedJWT() bool {
+	return c.effective(keyFileSelfSignedJWTSetting).KeyFileSelfSignedJWT()
+}
+
+// KeyRotationAge returns the effective age after which the "keyfile" token
+// source warns that its key should be rotated, or 0 if it never does.
+func (c *layeredConfig) KeyRotationAge() time.Duration {
+	return c.effective(keyRotationAgeSetting).KeyRotationAge()
+}
+
+// MetadataServiceAccount returns the alias or email of the instance's service
+// account effectively used by the "metadata" token source.
+func (c *layeredConfig) MetadataServiceAccount() string {
+	return c.effective(metadataServiceAccountSetting).MetadataServiceAccount()
+}
+
+// MetadataHost returns the host of the metadata server effectively used by the
+// "metadata" token source, or "" for the default.
+func (c *layeredConfig) MetadataHost() string {
+	return c.effective(metadataHostSetting).MetadataHost()
+}
+
+// MetadataNegativeCacheTTL returns how long the "metadata" token source
+// effectively remembers that the metadata server couldn't be reached.
+func (c *layeredConfig) MetadataNegativeCacheTTL() time.Duration {
+	return c.effective(metadataNegativeCacheTTLSetting).MetadataNegativeCacheTTL()
+}
+
+// ExecSource returns the program effectively run by the "exec:<name>" token
+// source of the given name, and whether it's configured.
+func (c *layeredConfig) ExecSource(name string) (ExecSource, bool) {
+	for _, l := range c.layers {
+		if c.trusts(execSourcesSetting, l) && execSourcesSetting.isSet(l.file) {
+			return l.file.ExecSource(name)
+		}
+	}
+	return (&configFile{}).ExecSource(name)
+}
+
+// SetCredsStore sets (and persists) credsStore mode and its delegate in the
+// user config.
+func (c *layeredConfig) SetCredsStore(enabled bool, delegate string) error {
+	return c.user.SetCredsStore(enabled, delegate)
+}
+
+// CheckRegistry returns an error if policy forbids issuing credentials for the
+// given registry.
+func (c *layeredConfig) CheckRegistry(serverURL string) error {
+	return c.policy.checkRegistry(serverURL)
+}
+
+// ResetAll clears all user configuration. Other layers are unaffected.
+func (c *layeredConfig) ResetAll() error {
+	return c.user.ResetAll()
+}
+
+// Settings returns every user-configurable setting along with its effective
+// value and origin.
+func (c *layeredConfig) Settings() []Setting {
+	ret := make([]Setting, 0, len(settingDefs))
+	for _, def := range settingDefs {
+		var s *Setting
+		for _, l := range c.layers {
+			if !def.isSet(l.file) || !c.trusts(def, l) {
+				continue
+			}
+			setting := Setting{Name: def.name, Value: def.value(l.file), Origin: l.origin(def)}
+			if s == nil {
+				s = &setting
+			} else {
+				s.Shadowed = append(s.Shadowed, setting)
+			}
+		}
+		defaults := Setting{Name: def.name, Value: def.value(&configFile{}), Origin: OriginDefault}
+		if s == nil {
+			s = &defaults
+		} else {
+			s.Shadowed = append(s.Shadowed, defaults)
+		}
+		if def == tokenSourcesSetting && c.policy != nil && len(c.policy.AllowedTokenSources) != 0 {
+			s.Value = strings.Join(c.TokenSources(), ",")
+			s.Origin += " (restricted by policy " + c.policy.path + ")"
+		}
+		if def == execSourcesSetting && c.policy.namesExecSource() {
+			s.Origin += " (restricted by policy " + c.policy.path + ")"
+		}
+		ret = append(ret, *s)
+	}
+	return ret
+}
+
+// splitList splits a comma-separated list, trimming whitespace from each
+// element and dropping empty ones.
+func splitList(v string) []string {
+	var ret []string
+	for _, e := range strings.Split(v, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,221 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const expectedTokenSourcesEnvVar = "DOCKER_CREDENTIAL_GCR_TOKEN_SOURCES"
+
+// setUpLayers points the user and system config files at the given contents
+// within a temporary directory. Empty contents designate a missing file.
+func setUpLayers(t *testing.T, userContents, systemContents string) {
+	dir := t.TempDir()
+	userPath := filepath.Join(dir, "user.json")
+	systemPath := filepath.Join(dir, "system.json")
+	if userContents != "" {
+		if err := os.WriteFile(userPath, []byte(userContents), 0600); err != nil {
+			t.Fatalf("Unable to write user config: %v", err)
+		}
+	}
+	if systemContents != "" {
+		if err := os.WriteFile(systemPath, []byte(systemContents), 0600); err != nil {
+			t.Fatalf("Unable to write system config: %v", err)
+		}
+	}
+	t.Setenv(expectedConfigEnvVar, userPath)
+	t.Setenv(systemConfigFileEnvVariable, systemPath)
+	t.Setenv(expectedTokenSourcesEnvVar, "")
+}
+
+func TestLoadUserConfig_Defaults(t *testing.T) {
+	setUpLayers(t, "", "")
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	assertEqual(t, expctedDefaultTokSrcs, tested.TokenSources())
+	if origin := tested.Settings()[0].Origin; origin != OriginDefault {
+		t.Errorf("Expected origin: %s, got: %s", OriginDefault, origin)
+	}
+}
+
+func TestLoadUserConfig_SystemFile(t *testing.T) {
+	setUpLayers(t, "", `{"SchemaVersion":1,"TokenSources":["env"]}`)
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	assertEqual(t, []string{"env"}, tested.TokenSources())
+	if origin := tested.Settings()[0].Origin; origin != os.Getenv(systemConfigFileEnvVariable) {
+		t.Errorf("Expected origin: %s, got: %s", os.Getenv(systemConfigFileEnvVariable), origin)
+	}
+}
+
+func TestLoadUserConfig_UserFileOverridesSystemFile(t *testing.T) {
+	setUpLayers(t, `{"SchemaVersion":1,"TokenSources":["gcloud"]}`, `{"SchemaVersion":1,"TokenSources":["env"]}`)
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	assertEqual(t, []string{"gcloud"}, tested.TokenSources())
+	setting := tested.Settings()[0]
+	if setting.Origin != os.Getenv(expectedConfigEnvVar) {
+		t.Errorf("Expected origin: %s, got: %s", os.Getenv(expectedConfigEnvVar), setting.Origin)
+	}
+	if len(setting.Shadowed) != 2 || setting.Shadowed[0].Value != "env" || setting.Shadowed[1].Origin != OriginDefault {
+		t.Errorf("Expected the system file and default to be shadowed, got: %+v", setting.Shadowed)
+	}
+}
+
+func TestLoadUserConfig_EnvOverridesFiles(t *testing.T) {
+	setUpLayers(t, `{"SchemaVersion":1,"TokenSources":["gcloud"]}`, `{"SchemaVersion":1,"TokenSources":["env"]}`)
+	t.Setenv(expectedTokenSourcesEnvVar, " env , store ")
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	assertEqual(t, []string{"env", "store"}, tested.TokenSources())
+	if origin := tested.Settings()[0].Origin; origin != "$"+expectedTokenSourcesEnvVar {
+		t.Errorf("Expected origin: $%s, got: %s", expectedTokenSourcesEnvVar, origin)
+	}
+}
+
+func TestLoadUserConfig_FlagOverridesEnv(t *testing.T) {
+	setUpLayers(t, "", "")
+	t.Setenv(expectedTokenSourcesEnvVar, "env")
+	flagOverrides[tokenSourcesSetting.name] = "gcloud"
+	defer delete(flagOverrides, tokenSourcesSetting.name)
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	assertEqual(t, []string{"gcloud"}, tested.TokenSources())
+	if origin := tested.Settings()[0].Origin; origin != "--token-source" {
+		t.Errorf("Expected origin: --token-source, got: %s", origin)
+	}
+}
+
+func TestLoadUserConfig_InvalidEnv(t *testing.T) {
+	setUpLayers(t, "", "")
+	t.Setenv(expectedTokenSourcesEnvVar, "invalid")
+
+	if _, err := LoadUserConfig(); err == nil {
+		t.Fatal("Expected an error for an unsupported token source")
+	}
+}
+
+func TestLoadUserConfig_GcloudSelection(t *testing.T) {
+	setUpLayers(t, `{"SchemaVersion":1,"GcloudAccount":"file@example.com","GcloudRegistries":{"gcr.io":{"Configuration":"gcr"}}}`, "")
+	t.Setenv("DOCKER_CREDENTIAL_GCR_GCLOUD_CONFIGURATION", "")
+	t.Setenv("DOCKER_CREDENTIAL_GCR_GCLOUD_REGISTRIES", "")
+	t.Setenv("DOCKER_CREDENTIAL_GCR_GCLOUD_ACCOUNT", "env@example.com")
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	// The environment's account overrides the user config's entry.
+	for _, registry := range []string{"https://gcr.io", "https://us-docker.pkg.dev"} {
+		if got, expected := tested.GcloudSelection(registry), (GcloudSelection{Account: "env@example.com"}); got != expected {
+			t.Errorf("Expected the environment's selection %+v for %s, got: %+v", expected, registry, got)
+		}
+	}
+
+	// Entries in the environment override the user config's account.
+	t.Setenv("DOCKER_CREDENTIAL_GCR_GCLOUD_ACCOUNT", "")
+	t.Setenv("DOCKER_CREDENTIAL_GCR_GCLOUD_REGISTRIES", `{"*.pkg.dev":{"Configuration":"prod"}}`)
+	if tested, err = LoadUserConfig(); err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+	if got, expected := tested.GcloudSelection("https://us-docker.pkg.dev"), (GcloudSelection{Configuration: "prod"}); got != expected {
+		t.Errorf("Expected the environment's entry %+v, got: %+v", expected, got)
+	}
+	// Other registries still use the user config's entries and settings.
+	if got, expected := tested.GcloudSelection("https://gcr.io"), (GcloudSelection{Configuration: "gcr"}); got != expected {
+		t.Errorf("Expected the user config's entry %+v, got: %+v", expected, got)
+	}
+	if got, expected := tested.GcloudSelection("https://eu.gcr.io"), (GcloudSelection{Account: "file@example.com"}); got != expected {
+		t.Errorf("Expected the user config's selection %+v, got: %+v", expected, got)
+	}
+}
+
+func TestLoadUserConfig_ReauthNotifyCommand(t *testing.T) {
+	setUpLayers(t, `{"SchemaVersion":1,"ReauthNotifyCommand":"notify-send","ReauthNotifyArgs":["--urgency=critical"]}`, "")
+	t.Setenv("DOCKER_CREDENTIAL_GCR_REAUTH_NOTIFY_ARGS", "")
+	t.Setenv("DOCKER_CREDENTIAL_GCR_REAUTH_NOTIFY_COMMAND", "/opt/My Tools/notify")
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	// The user config's arguments aren't passed to the environment's program.
+	assertEqual(t, []string{"/opt/My Tools/notify"}, tested.ReauthNotifyCommand())
+
+	t.Setenv("DOCKER_CREDENTIAL_GCR_REAUTH_NOTIFY_ARGS", `["--title", "Docker login"]`)
+	if tested, err = LoadUserConfig(); err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+	assertEqual(t, []string{"/opt/My Tools/notify", "--title", "Docker login"}, tested.ReauthNotifyCommand())
+}
+
+func TestLoadUserConfig_OAuthClient(t *testing.T) {
+	secretsPath := filepath.Join(t.TempDir(), "client_secrets.json")
+	if err := os.WriteFile(secretsPath, []byte(`{"installed":{"client_id":"from-file","client_secret":"file secret"}}`), 0600); err != nil {
+		t.Fatalf("Unable to write client secrets: %v", err)
+	}
+	setUpLayers(t, `{"SchemaVersion":1,"OAuthClientId":"from-user-config","OAuthClientSecret":"user secret"}`, "")
+	t.Setenv("DOCKER_CREDENTIAL_GCR_OAUTH_CLIENT_ID", "")
+	t.Setenv("DOCKER_CREDENTIAL_GCR_OAUTH_CLIENT_SECRET", "")
+	t.Setenv("DOCKER_CREDENTIAL_GCR_OAUTH_CLIENT_SECRETS_FILE", "")
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+	if got, expected := mustOAuthClient(t, tested), (OAuthClient{ID: "from-user-config", Secret: "user secret"}); got != expected {
+		t.Errorf("Expected the user config's client %+v, got: %+v", expected, got)
+	}
+	for _, s := range tested.Settings() {
+		if s.Name == "OAuthClientSecret" && s.Value != redacted {
+			t.Errorf("Expected the client secret to be redacted, got: %q", s.Value)
+		}
+	}
+
+	// A client secrets file in a higher-precedence layer wins.
+	t.Setenv("DOCKER_CREDENTIAL_GCR_OAUTH_CLIENT_SECRETS_FILE", secretsPath)
+	tested, err = LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+	if got, expected := mustOAuthClient(t, tested), (OAuthClient{ID: "from-file", Secret: "file secret"}); got != expected {
+		t.Errorf("Expected the client secrets file's client %+v, got: %+v", expected, got)
+	}
+}
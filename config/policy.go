@@ -0,0 +1,212 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+const policyFileName = "policy.json"
+
+// policy is an administrator-controlled restriction of the helper's settings.
+// Unlike the config layers, it can't be overridden by the user: it must be
+// owned by root (on Unix systems) and its path can't be changed.
+//
+// TokenSources and AllowedTokenSources combine: only token sources both
+// pinned and allowed are used. Pinning "auto" pins whichever token sources it
+// chooses, which are never those AllowedTokenSources forbids; "auto" itself
+// needn't be listed in AllowedTokenSources.
+//
+// If either names an "exec:<name>" token source, the programs they run are
+// only taken from the policy's ExecSources or the system config, so that users
+// can't substitute their own.
+type policy struct {
+	SchemaVersion int `json:"SchemaVersion"`
+	// TokenSources, if set, pins the token sources regardless of any other
+	// configuration.
+	TokenSources []string `json:"TokenSources,omitempty"`
+	// AllowedTokenSources, if set, restricts the token sources which may be
+	// configured or used. It may include JSONKeyTokenSource.
+	AllowedTokenSources []string `json:"AllowedTokenSources,omitempty"`
+	// AllowedRegistries, if set, restricts the registry hosts for which
+	// credentials will be issued. Entries may be glob patterns, e.g.
+	// "*.pkg.dev".
+	AllowedRegistries []string `json:"AllowedRegistries,omitempty"`
+	// Scopes, if set, pins the OAuth2 scopes regardless of any other
+	// configuration.
+	Scopes []string `json:"Scopes,omitempty"`
+	// ExecSources, if set, pins the programs run by "exec:<name>" token
+	// sources regardless of any other configuration.
+	ExecSources map[string]ExecSource `json:"ExecSources,omitempty"`
+
+	// the path the policy was loaded from
+	path string
+}
+
+// policyPath returns the full path of the policy file, made a variable for
+// testing.
+var policyPath = func() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), systemConfigDirName, policyFileName)
+	}
+	return filepath.Join("/etc", systemConfigDirName, policyFileName)
+}
+
+// verifyPolicyOwner verifies that the policy file can only have been written
+// by an administrator, made a variable for testing.
+var verifyPolicyOwner = checkPolicyOwner
+
+// loadPolicy loads the policy file, returning nil if it doesn't exist.
+func loadPolicy() (*policy, error) {
+	path := policyPath()
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyPolicyOwner(fi); err != nil {
+		return nil, fmt.Errorf("refusing to use policy %s: %v", path, err)
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	var p policy
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("failed to load policy from %s: %v", path, err)
+	}
+	if p.SchemaVersion != 1 {
+		return nil, fmt.Errorf("failed to load policy from %s: unsupported schema version %d", path, p.SchemaVersion)
+	}
+	sources := append([]string{}, p.TokenSources...)
+	for _, source := range p.AllowedTokenSources {
+		if source != JSONKeyTokenSource {
+			sources = append(sources, source)
+		}
+	}
+	if err := (&configFile{TokenSrcs: sources, ExecSrcs: p.ExecSources}).validate(); err != nil {
+		return nil, fmt.Errorf("failed to load policy from %s: %v", path, err)
+	}
+	p.path = path
+	return &p, nil
+}
+
+// layer returns the settings pinned by the policy.
+func (p *policy) layer() layer {
+	return layer{
+		origin: fileOrigin("policy " + p.path),
+		file: &configFile{
+			TokenSrcs: p.TokenSources,
+			Scps:      p.Scopes,
+			ExecSrcs:  p.ExecSources,
+		},
+		admin: true,
+	}
+}
+
+// namesExecSource reports whether the pinned or allowed token sources include
+// any "exec:<name>" token source.
+func (p *policy) namesExecSource() bool {
+	if p == nil {
+		return false
+	}
+	for _, source := range append(slices.Clone(p.TokenSources), p.AllowedTokenSources...) {
+		if strings.HasPrefix(source, ExecTokenSourcePrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTokenSources returns an error if any of the given token sources are
+// forbidden.
+func (p *policy) checkTokenSources(sources []string) error {
+	if p == nil {
+		return nil
+	}
+	if len(p.TokenSources) != 0 {
+		return fmt.Errorf("token sources are locked to %q by policy %s", strings.Join(p.TokenSources, ","), p.path)
+	}
+	for _, source := range sources {
+		if !p.allowsTokenSource(source) {
+			return fmt.Errorf("token source %q is forbidden by policy %s", source, p.path)
+		}
+	}
+	return nil
+}
+
+// allowsTokenSource reports whether the given token source may be used: the
+// intersection of the pinned token sources, if any, and the
+// AllowedTokenSources, if any. See policy.
+func (p *policy) allowsTokenSource(source string) bool {
+	if p == nil {
+		return true
+	}
+	pinned := len(p.TokenSources) == 0 || slices.Contains(p.TokenSources, source) || slices.Contains(p.TokenSources, "auto")
+	if source == "auto" {
+		// It only ever chooses allowed token sources.
+		return pinned
+	}
+	return pinned && (len(p.AllowedTokenSources) == 0 || slices.Contains(p.AllowedTokenSources, source))
+}
+
+// checkRegistry returns an error if credentials may not be issued for the
+// given registry.
+func (p *policy) checkRegistry(serverURL string) error {
+	if p == nil || len(p.AllowedRegistries) == 0 {
+		return nil
+	}
+	host := RegistryHost(serverURL)
+	for _, pattern := range p.AllowedRegistries {
+		if matched, err := path.Match(pattern, host); err == nil && matched {
+			return nil
+		}
+	}
+	return fmt.Errorf("registry %q is forbidden by policy %s", host, p.path)
+}
+
+// RegistryHost returns the hostname of a Docker server URL, which may or may
+// not include a scheme.
+func RegistryHost(serverURL string) string {
+	if !strings.Contains(serverURL, "://") {
+		serverURL = "https://" + serverURL
+	}
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return serverURL
+	}
+	return u.Hostname()
+}
@@ -0,0 +1,226 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setUpPolicy points the policy file at the given contents within a
+// temporary directory, skipping the ownership check.
+func setUpPolicy(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("Unable to write policy: %v", err)
+	}
+	oldPath, oldVerify := policyPath, verifyPolicyOwner
+	policyPath = func() string { return path }
+	verifyPolicyOwner = func(os.FileInfo) error { return nil }
+	t.Cleanup(func() {
+		policyPath, verifyPolicyOwner = oldPath, oldVerify
+	})
+	return path
+}
+
+func TestPolicy_PinsTokenSources(t *testing.T) {
+	setUpLayers(t, `{"SchemaVersion":1,"TokenSources":["store"]}`, "")
+	setUpPolicy(t, `{"SchemaVersion":1,"TokenSources":["env"]}`)
+	t.Setenv(expectedTokenSourcesEnvVar, "gcloud")
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	assertEqual(t, []string{"env"}, tested.TokenSources())
+	if err := tested.SetTokenSources([]string{"gcloud"}); err == nil {
+		t.Error("Expected SetTokenSources to fail for pinned token sources")
+	}
+	// Only the pinned token sources may be used, e.g. not stored keys.
+	if tested.AllowsTokenSource(JSONKeyTokenSource) || !tested.AllowsTokenSource("env") {
+		t.Error("Expected only the pinned token sources to be allowed")
+	}
+}
+
+func TestPolicy_AllowsJSONKeys(t *testing.T) {
+	setUpLayers(t, "", "")
+	setUpPolicy(t, `{"SchemaVersion":1,"AllowedTokenSources":["env","json_key"]}`)
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	if !tested.AllowsTokenSource(JSONKeyTokenSource) || tested.AllowsTokenSource("store") {
+		t.Error("Expected stored keys and env to be allowed")
+	}
+	if err := tested.SetTokenSources([]string{JSONKeyTokenSource}); err == nil {
+		t.Error("Expected json_key not to be configurable as a token source")
+	}
+}
+
+func TestPolicy_AllowedTokenSources(t *testing.T) {
+	// The user file was edited by hand to include a forbidden source.
+	setUpLayers(t, `{"SchemaVersion":1,"TokenSources":["store","env"]}`, "")
+	path := setUpPolicy(t, `{"SchemaVersion":1,"AllowedTokenSources":["env","gcloud"]}`)
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	assertEqual(t, []string{"env"}, tested.TokenSources())
+
+	err = tested.SetTokenSources([]string{"store"})
+	if err == nil {
+		t.Fatal("Expected SetTokenSources to fail for a forbidden token source")
+	}
+	if !strings.Contains(err.Error(), `"store"`) || !strings.Contains(err.Error(), path) {
+		t.Errorf("Expected error to name the token source and policy, got: %v", err)
+	}
+}
+
+func TestPolicy_PinsAutoWithinAllowedTokenSources(t *testing.T) {
+	setUpLayers(t, "", "")
+	setUpPolicy(t, `{"SchemaVersion":1,"TokenSources":["auto"],"AllowedTokenSources":["env","metadata"]}`)
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	assertEqual(t, []string{"auto"}, tested.TokenSources())
+	for source, expected := range map[string]bool{"auto": true, "env": true, "metadata": true, "store": false, "gcloud": false, JSONKeyTokenSource: false} {
+		if got := tested.AllowsTokenSource(source); got != expected {
+			t.Errorf("Expected AllowsTokenSource(%q) to be %v, got: %v", source, expected, got)
+		}
+	}
+}
+
+func TestPolicy_AllowedRegistries(t *testing.T) {
+	setUpLayers(t, "", "")
+	setUpPolicy(t, `{"SchemaVersion":1,"AllowedRegistries":["gcr.io","*.pkg.dev"]}`)
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	for _, allowed := range []string{"https://gcr.io", "gcr.io", "us-docker.pkg.dev", "https://europe-west1-docker.pkg.dev/v2/"} {
+		if err := tested.CheckRegistry(allowed); err != nil {
+			t.Errorf("Expected %s to be allowed, got: %v", allowed, err)
+		}
+	}
+	for _, forbidden := range []string{"https://eu.gcr.io", "docker.io", "https://pkg.dev.evil.com"} {
+		if err := tested.CheckRegistry(forbidden); err == nil {
+			t.Errorf("Expected %s to be forbidden", forbidden)
+		}
+	}
+}
+
+func TestPolicy_PinsScopes(t *testing.T) {
+	setUpLayers(t, `{"SchemaVersion":1,"Scopes":["https://www.googleapis.com/auth/cloud-platform"]}`, "")
+	setUpPolicy(t, `{"SchemaVersion":1,"Scopes":["https://www.googleapis.com/auth/devstorage.read_only"]}`)
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	assertEqual(t, []string{"https://www.googleapis.com/auth/devstorage.read_only"}, tested.Scopes())
+	if !tested.ScopesPinned() {
+		t.Error("Expected the scopes to be reported as pinned")
+	}
+}
+
+func TestPolicy_ExecSourcesFromSystemConfig(t *testing.T) {
+	setUpLayers(t,
+		`{"SchemaVersion":1,"ExecSources":{"broker":{"Command":"evil"}}}`,
+		`{"SchemaVersion":1,"ExecSources":{"broker":{"Command":"broker"}}}`)
+	t.Setenv("DOCKER_CREDENTIAL_GCR_EXEC_SOURCES", `{"broker":{"Command":"eviler"}}`)
+	setUpPolicy(t, `{"SchemaVersion":1,"AllowedTokenSources":["exec:broker"]}`)
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	if src, ok := tested.ExecSource("broker"); !ok || src.Command != "broker" {
+		t.Errorf("Expected the system config's program to be run, got: %+v", src)
+	}
+}
+
+func TestPolicy_PinsExecSources(t *testing.T) {
+	setUpLayers(t, `{"SchemaVersion":1,"ExecSources":{"other":{"Command":"other"}}}`, "")
+	setUpPolicy(t, `{"SchemaVersion":1,"TokenSources":["exec:broker"],"ExecSources":{"broker":{"Command":"broker","Format":"json"}}}`)
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	if src, ok := tested.ExecSource("broker"); !ok || src.Command != "broker" || src.OutputFormat() != ExecFormatJSON {
+		t.Errorf("Expected the policy's program to be run, got: %+v", src)
+	}
+	if _, ok := tested.ExecSource("other"); ok {
+		t.Error("Expected the user's programs to be ignored")
+	}
+}
+
+func TestPolicy_ExecSourcesUnrestricted(t *testing.T) {
+	setUpLayers(t, `{"SchemaVersion":1,"ExecSources":{"broker":{"Command":"mine"}}}`, "")
+	setUpPolicy(t, `{"SchemaVersion":1,"AllowedTokenSources":["env"]}`)
+
+	tested, err := LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig returned an error: %v", err)
+	}
+
+	if src, ok := tested.ExecSource("broker"); !ok || src.Command != "mine" {
+		t.Errorf("Expected the user's program, got: %+v", src)
+	}
+}
+
+func TestPolicy_InvalidExecSources(t *testing.T) {
+	setUpLayers(t, "", "")
+	setUpPolicy(t, `{"SchemaVersion":1,"ExecSources":{"broker":{"Command":""}}}`)
+
+	if _, err := LoadUserConfig(); err == nil {
+		t.Fatal("Expected an error for an invalid ExecSource")
+	}
+}
+
+func TestPolicy_UnknownField(t *testing.T) {
+	setUpLayers(t, "", "")
+	setUpPolicy(t, `{"SchemaVersion":1,"AllowedTokenSauces":["env"]}`)
+
+	if _, err := LoadUserConfig(); err == nil {
+		t.Fatal("Expected an error for an unknown policy setting")
+	}
+}
+
+func TestPolicy_RejectsUnverifiedOwner(t *testing.T) {
+	setUpLayers(t, "", "")
+	setUpPolicy(t, `{"SchemaVersion":1,"TokenSources":["env"]}`)
+	verifyPolicyOwner = func(os.FileInfo) error { return errors.New("file must be owned by root") }
+
+	if _, err := LoadUserConfig(); err == nil {
+		t.Fatal("Expected an error for a policy file not owned by root")
+	}
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+// +build !windows
+
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// checkPolicyOwner verifies that the policy file is owned by root and isn't
+// writable by anyone else.
+func checkPolicyOwner(fi os.FileInfo) error {
+	if st, ok := fi.Sys().(*syscall.Stat_t); !ok || st.Uid != 0 {
+		return errors.New("file must be owned by root")
+	}
+	if fi.Mode().Perm()&0022 != 0 {
+		return errors.New("file must not be writable by group or others")
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/gcloud"
+)
+
+const (
+	// autoTokenSource is replaced by the token sources suited to the detected
+	// environment.
+	autoTokenSource = "auto"
+	// cloudBuildEnvVar is set in every step of a Cloud Build build.
+	cloudBuildEnvVar = "BUILDER_OUTPUT"
+	// adcEnvVar names a credential file for application default credentials,
+	// e.g. one written by a CI system's workload identity federation.
+	adcEnvVar = "GOOGLE_APPLICATION_CREDENTIALS"
+	// adcFilename is gcloud's application default credentials file, in its
+	// config directory.
+	adcFilename = "application_default_credentials.json"
+	// metadataProbeTimeout bounds probing the metadata server while detecting
+	// the environment.
+	metadataProbeTimeout = 2 * time.Second
+)
+
+// An AutoTokenSource is a token source chosen by the "auto" token source, and
+// why it was chosen.
+type AutoTokenSource struct {
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// expandTokenSources replaces the "auto" token source, if present, with the
+// token sources it chooses, omitting any already listed.
+func (ch *gcrCredHelper) expandTokenSources(ctx context.Context, sources []string) []string {
+	var ret []string
+	seen := map[string]bool{}
+	add := func(source string) {
+		if !seen[source] {
+			seen[source] = true
+			ret = append(ret, source)
+		}
+	}
+	for _, source := range sources {
+		if source != autoTokenSource {
+			add(source)
+			continue
+		}
+		for _, auto := range ch.autoTokenSources(ctx) {
+			add(auto.Source)
+		}
+	}
+	return ret
+}
+
+// autoTokenSources returns the token sources chosen by the "auto" token
+// source. The environment is only detected once per helper.
+func (ch *gcrCredHelper) autoTokenSources(ctx context.Context) []AutoTokenSource {
+	if ch.auto == nil {
+		ch.auto = ch.detectTokenSources(ctx)
+		for _, auto := range ch.auto {
+			slog.Debug("auto: chose token source", "token_source", auto.Source, "reason", auto.Reason)
+		}
+	}
+	return ch.auto
+}
+
+// detectTokenSources chooses token sources for the environment, from the most
+// to the least explicitly configured: a pre-minted token or key, a CI system's
+// credentials, credentials the user signed in with, and finally the instance's
+// service account.
+func (ch *gcrCredHelper) detectTokenSources(ctx context.Context) []AutoTokenSource {
+	var ret []AutoTokenSource
+	chosen := func(source string) bool {
+		for _, auto := range ret {
+			if auto.Source == source {
+				return true
+			}
+		}
+		return false
+	}
+	add := func(source, reason string) {
+		if chosen(source) {
+			return
+		}
+		if !ch.userCfg.AllowsTokenSource(source) {
+			slog.Debug("auto: token source forbidden by policy", "token_source", source, "reason", reason)
+			return
+		}
+		ret = append(ret, AutoTokenSource{Source: source, Reason: reason})
+	}
+
+	if _, ok := os.LookupEnv(config.AccessTokenEnvVar); ok {
+		add("envvar", config.AccessTokenEnvVar+" is set")
+	}
+	if path := ch.userCfg.AccessTokenFile(); path != "" {
+		add("file", "the AccessTokenFile "+path+" is configured")
+	}
+	if path := ch.userCfg.KeyFile(); path != "" {
+		add("keyfile", "the KeyFile "+path+" is configured")
+	}
+	if _, ok := os.LookupEnv(cloudBuildEnvVar); ok {
+		add("metadata", fmt.Sprintf("running in Cloud Build (%s is set), whose builds authenticate as their service account via the metadata server", cloudBuildEnvVar))
+	}
+	if path := os.Getenv(adcEnvVar); path != "" {
+		if os.Getenv("GITHUB_ACTIONS") == "true" {
+			add("env", fmt.Sprintf("running in GitHub Actions with %s set, e.g. by workload identity federation", adcEnvVar))
+		} else {
+			add("env", adcEnvVar+" is set")
+		}
+	}
+	if _, err := ch.store.GetGCRAuth(); err == nil {
+		add("store", "a user is signed in with gcr-login")
+	} else if imported, err := ch.store.GetImportedCredential(); err == nil {
+		add("store", fmt.Sprintf("a %s credential was imported with gcr-login", imported.Type))
+	}
+	if cfg, err := gcloud.DefaultConfig(); err == nil {
+		if account, err := cfg.ResolveAccount("", ""); err == nil {
+			add("gcloud-native", "gcloud's active account is "+account)
+		} else if path, lerr := exec.LookPath("gcloud"); errors.Is(err, gcloud.ErrUnsupported) && lerr == nil {
+			add("gcloud", "gcloud is installed at "+path+", but its config directory isn't understood")
+		}
+		if _, err := os.Stat(filepath.Join(cfg.Dir, adcFilename)); err == nil {
+			add("env", "gcloud's application default credentials exist")
+		}
+	}
+	if !chosen("metadata") {
+		if email, err := ch.probeMetadata(ctx); err == nil {
+			add("metadata", "the metadata server serves the service account "+email)
+		} else {
+			slog.Debug("auto: metadata server not used", "error", err)
+		}
+	}
+
+	if len(ret) == 0 {
+		add("env", "no other credentials were detected")
+	}
+	return ret
+}
+
+// probeMetadata returns the email of the "metadata" token source's service
+// account, if the metadata server can be reached. Like the token source, it
+// records that the metadata server is unreachable.
+func (ch *gcrCredHelper) probeMetadata(ctx context.Context) (string, error) {
+	host := metadataHost(ch.userCfg.MetadataHost())
+	if err := checkMetadataUnavailable(ch.store, host); err != nil {
+		return "", err
+	}
+	ctx, cancel := withTimeout(ctx, metadataProbeTimeout)
+	defer cancel()
+	email, err := requestMetadataEmail(ctx, host, ch.userCfg.MetadataServiceAccount())
+	recordMetadataAvailability(ctx, ch.store, host, ch.userCfg.MetadataNegativeCacheTTL(), err)
+	return email, err
+}
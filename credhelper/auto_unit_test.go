@@ -0,0 +1,169 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/mock/mock_config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+	"github.com/golang/mock/gomock"
+	"golang.org/x/oauth2"
+)
+
+// setUpAutoEnv clears the environment variables read while detecting the
+// environment, and points gcloud at an empty config directory.
+func setUpAutoEnv(t *testing.T) {
+	for _, name := range []string{config.AccessTokenEnvVar, cloudBuildEnvVar, adcEnvVar, "GITHUB_ACTIONS", "CLOUDSDK_CORE_ACCOUNT", "CLOUDSDK_ACTIVE_CONFIG_NAME"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+}
+
+// newAutoHelper returns a helper in an environment with nothing configured,
+// whose metadata server is at metadataHost, and which policy forbids the
+// given token sources.
+func newAutoHelper(t *testing.T, metadataHost string, forbidden ...string) *gcrCredHelper {
+	mockCtrl := gomock.NewController(t)
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().AccessTokenFile().Return("").AnyTimes()
+	mockUserCfg.EXPECT().KeyFile().Return("").AnyTimes()
+	mockUserCfg.EXPECT().MetadataHost().Return(metadataHost).AnyTimes()
+	mockUserCfg.EXPECT().MetadataServiceAccount().Return(config.DefaultMetadataServiceAccount).AnyTimes()
+	mockUserCfg.EXPECT().MetadataNegativeCacheTTL().Return(time.Hour).AnyTimes()
+	mockUserCfg.EXPECT().AllowsTokenSource(gomock.Any()).DoAndReturn(func(source string) bool {
+		for _, f := range forbidden {
+			if source == f {
+				return false
+			}
+		}
+		return true
+	}).AnyTimes()
+	return &gcrCredHelper{
+		store:   store.NewGCRCredStore(filepath.Join(t.TempDir(), "docker_credentials.json")),
+		userCfg: mockUserCfg,
+	}
+}
+
+func autoSources(auto []AutoTokenSource) []string {
+	var ret []string
+	for _, a := range auto {
+		ret = append(ret, a.Source)
+	}
+	return ret
+}
+
+func TestDetectTokenSources_Workstation(t *testing.T) {
+	setUpAutoEnv(t)
+	t.Setenv("CLOUDSDK_CONFIG", "../gcloud/testdata/valid")
+	tested := newAutoHelper(t, unreachableHost(t))
+	if err := tested.store.SetGCRAuth(&oauth2.Token{AccessToken: "a", RefreshToken: "r"}, "me@example.com", config.DefaultOAuthClient); err != nil {
+		t.Fatalf("SetGCRAuth returned an error: %v", err)
+	}
+
+	auto := tested.autoTokenSources(context.Background())
+
+	if expected := []string{"store", "gcloud-native"}; !reflect.DeepEqual(autoSources(auto), expected) {
+		t.Errorf("Expected %v, got: %+v", expected, auto)
+	}
+	if !strings.Contains(auto[1].Reason, "user@example.com") {
+		t.Errorf("Expected the reason to name gcloud's account, got: %s", auto[1].Reason)
+	}
+	// Laptops don't probe the metadata server again.
+	if cached, err := tested.store.GetMetadataUnavailable(); err != nil || cached == nil {
+		t.Errorf("Expected the metadata server to be cached as unavailable, got: %+v, %v", cached, err)
+	}
+}
+
+func TestDetectTokenSources_CloudBuild(t *testing.T) {
+	setUpAutoEnv(t)
+	t.Setenv(cloudBuildEnvVar, "/builder/outputs")
+	tested := newAutoHelper(t, unreachableHost(t))
+
+	auto := tested.autoTokenSources(context.Background())
+
+	if expected := []string{"metadata"}; !reflect.DeepEqual(autoSources(auto), expected) {
+		t.Errorf("Expected %v, got: %+v", expected, auto)
+	}
+}
+
+func TestDetectTokenSources_GitHubActions(t *testing.T) {
+	setUpAutoEnv(t)
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv(adcEnvVar, "/home/runner/work/gha-creds.json")
+	tested := newAutoHelper(t, unreachableHost(t))
+
+	auto := tested.autoTokenSources(context.Background())
+
+	if expected := []string{"env"}; !reflect.DeepEqual(autoSources(auto), expected) {
+		t.Errorf("Expected %v, got: %+v", expected, auto)
+	}
+	if !strings.Contains(auto[0].Reason, "GitHub Actions") {
+		t.Errorf("Expected the reason to name GitHub Actions, got: %s", auto[0].Reason)
+	}
+}
+
+func TestDetectTokenSources_MetadataServer(t *testing.T) {
+	setUpAutoEnv(t)
+	t.Setenv(config.AccessTokenEnvVar, "ya29.env")
+	tested := newAutoHelper(t, newMetadataServer(t))
+
+	auto := tested.autoTokenSources(context.Background())
+
+	if expected := []string{"envvar", "metadata"}; !reflect.DeepEqual(autoSources(auto), expected) {
+		t.Errorf("Expected %v, got: %+v", expected, auto)
+	}
+	if !strings.Contains(auto[1].Reason, testServiceAccount) {
+		t.Errorf("Expected the reason to name the service account, got: %s", auto[1].Reason)
+	}
+}
+
+func TestDetectTokenSources_Nothing(t *testing.T) {
+	setUpAutoEnv(t)
+	tested := newAutoHelper(t, unreachableHost(t))
+
+	if auto := tested.autoTokenSources(context.Background()); !reflect.DeepEqual(autoSources(auto), []string{"env"}) {
+		t.Errorf("Expected to fall back to env, got: %+v", auto)
+	}
+}
+
+func TestDetectTokenSources_Policy(t *testing.T) {
+	setUpAutoEnv(t)
+	t.Setenv(config.AccessTokenEnvVar, "ya29.env")
+	t.Setenv(cloudBuildEnvVar, "/builder/outputs")
+	tested := newAutoHelper(t, unreachableHost(t), "envvar")
+
+	if auto := tested.autoTokenSources(context.Background()); !reflect.DeepEqual(autoSources(auto), []string{"metadata"}) {
+		t.Errorf("Expected forbidden token sources to be omitted, got: %+v", auto)
+	}
+}
+
+func TestExpandTokenSources(t *testing.T) {
+	tested := &gcrCredHelper{auto: []AutoTokenSource{{Source: "store"}, {Source: "gcloud"}}}
+
+	actual := tested.expandTokenSources(context.Background(), []string{"gcloud", "auto", "env"})
+
+	if expected := []string{"gcloud", "store", "env"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got: %v", expected, actual)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+)
+
+// credHelperPrefix prefixes the binary name of every Docker credential
+// helper.
+const credHelperPrefix = "docker-credential-"
+
+// delegatingHelper is installed as Docker's global credsStore. It serves
+// Google's registries with the GCR credential helper and delegates every other
+// registry to the credsStore it replaced, if any.
+type delegatingHelper struct {
+	google credentials.Helper
+	// delegate runs the replaced credsStore, nil if there was none
+	delegate client.ProgramFunc
+	// the replaced credsStore's suffix, e.g. "pass"
+	delegateName string
+}
+
+func newDelegatingHelper(ctx context.Context, google credentials.Helper, delegateName string) *delegatingHelper {
+	h := &delegatingHelper{google: google, delegateName: delegateName}
+	if delegateName != "" {
+		h.delegate = delegateProgram(ctx, credHelperPrefix+delegateName)
+	}
+	return h
+}
+
+// List lists the service account keys stored by the GCR credential helper for
+// Google's registries, and the credentials stored by the delegate.
+func (h *delegatingHelper) List() (map[string]string, error) {
+	creds, err := h.google.List()
+	if err != nil || h.delegate == nil {
+		return creds, err
+	}
+	slog.Debug("list: delegating", "delegate", h.delegateName)
+	delegated, err := client.List(h.delegate)
+	if err != nil {
+		return nil, err
+	}
+	for serverURL, username := range delegated {
+		if !config.IsGoogleRegistry(serverURL) {
+			creds[serverURL] = username
+		}
+	}
+	return creds, nil
+}
+
+// Add stores credentials for Google's registries with the GCR credential
+// helper, and any others with the delegate.
+func (h *delegatingHelper) Add(creds *credentials.Credentials) error {
+	if config.IsGoogleRegistry(creds.ServerURL) {
+		return h.google.Add(creds)
+	}
+	if h.delegate == nil {
+		return fmt.Errorf("no credential store to save credentials for %s in: set CredsStoreDelegate in the config file", creds.ServerURL)
+	}
+	slog.Debug("store: delegating", "server_url", creds.ServerURL, "delegate", h.delegateName)
+	return client.Store(h.delegate, creds)
+}
+
+// Delete removes credentials for Google's registries with the GCR credential
+// helper, and any others with the delegate.
+func (h *delegatingHelper) Delete(serverURL string) error {
+	if config.IsGoogleRegistry(serverURL) {
+		return h.google.Delete(serverURL)
+	}
+	if h.delegate == nil {
+		// There's nowhere they could be stored.
+		return nil
+	}
+	slog.Debug("erase: delegating", "server_url", serverURL, "delegate", h.delegateName)
+	return client.Erase(h.delegate, serverURL)
+}
+
+// Get returns credentials for Google's registries from the GCR credential
+// helper, and any others from the delegate. Without a delegate, other
+// registries have no credentials, so that they are accessed anonymously.
+func (h *delegatingHelper) Get(serverURL string) (string, string, error) {
+	if config.IsGoogleRegistry(serverURL) {
+		return h.google.Get(serverURL)
+	}
+	if h.delegate == nil {
+		return "", "", credentials.NewErrCredentialsNotFound()
+	}
+	slog.Debug("get: delegating", "server_url", serverURL, "delegate", h.delegateName)
+	creds, err := client.Get(h.delegate, serverURL)
+	if err != nil {
+		return "", "", err
+	}
+	return creds.Username, creds.Secret, nil
+}
+
+// delegateProgram returns a client.ProgramFunc which runs the named credential
+// helper, killing it once ctx is done.
+func delegateProgram(ctx context.Context, name string) client.ProgramFunc {
+	return func(args ...string) client.Program {
+		c := exec.CommandContext(ctx, name, args...)
+		c.Stderr = os.Stderr
+		return &delegateCmd{c}
+	}
+}
+
+// delegateCmd implements client.Program with an exec.Cmd.
+type delegateCmd struct {
+	cmd *exec.Cmd
+}
+
+func (c *delegateCmd) Output() ([]byte, error) {
+	return c.cmd.Output()
+}
+
+func (c *delegateCmd) Input(in io.Reader) {
+	c.cmd.Stdin = in
+}
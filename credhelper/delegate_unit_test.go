@@ -0,0 +1,164 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+)
+
+// fakeHelper is a credentials.Helper which records the registries it served.
+type fakeHelper struct {
+	served []string
+}
+
+func (h *fakeHelper) Add(creds *credentials.Credentials) error {
+	h.served = append(h.served, creds.ServerURL)
+	return nil
+}
+
+func (h *fakeHelper) Delete(serverURL string) error {
+	h.served = append(h.served, serverURL)
+	return nil
+}
+
+func (h *fakeHelper) Get(serverURL string) (string, string, error) {
+	h.served = append(h.served, serverURL)
+	return "google", "token", nil
+}
+
+func (h *fakeHelper) List() (map[string]string, error) {
+	return map[string]string{"gcr.io": "_json_key"}, nil
+}
+
+// fakeDelegate is a client.Program which records the action and input it was
+// run with, and returns the given output.
+type fakeDelegate struct {
+	output  string
+	err     error
+	actions []string
+	inputs  []string
+}
+
+func (d *fakeDelegate) program(args ...string) client.Program {
+	d.actions = append(d.actions, args[0])
+	return d
+}
+
+func (d *fakeDelegate) Output() ([]byte, error) {
+	return []byte(d.output), d.err
+}
+
+func (d *fakeDelegate) Input(in io.Reader) {
+	b, _ := io.ReadAll(in)
+	d.inputs = append(d.inputs, string(b))
+}
+
+func TestDelegatingHelper_ServesGoogleRegistries(t *testing.T) {
+	google := &fakeHelper{}
+	delegate := &fakeDelegate{}
+	tested := &delegatingHelper{google: google, delegate: delegate.program, delegateName: "fake"}
+
+	for _, serverURL := range []string{"https://gcr.io", "us-west1-docker.pkg.dev", "https://marketplace.gcr.io/v2/"} {
+		if user, _, err := tested.Get(serverURL); err != nil || user != "google" {
+			t.Errorf("Expected %s to be served by the GCR helper, got: %s, %v", serverURL, user, err)
+		}
+	}
+	if err := tested.Delete("https://gcr.io"); err != nil {
+		t.Errorf("Delete returned an error: %v", err)
+	}
+
+	if len(google.served) != 4 || len(delegate.actions) != 0 {
+		t.Errorf("Expected only the GCR helper to be used, got: %v, %v", google.served, delegate.actions)
+	}
+}
+
+func TestDelegatingHelper_DelegatesOtherRegistries(t *testing.T) {
+	google := &fakeHelper{}
+	delegate := &fakeDelegate{output: `{"Username":"someone","Secret":"hunter2"}`}
+	tested := &delegatingHelper{google: google, delegate: delegate.program, delegateName: "fake"}
+
+	user, secret, err := tested.Get("https://index.docker.io/v1/")
+	if err != nil || user != "someone" || secret != "hunter2" {
+		t.Errorf("Expected the delegate's credentials, got: %s, %s, %v", user, secret, err)
+	}
+	if err := tested.Add(&credentials.Credentials{ServerURL: "ghcr.io", Username: "someone", Secret: "hunter2"}); err != nil {
+		t.Errorf("Add returned an error: %v", err)
+	}
+	delegate.output = ""
+	if err := tested.Delete("ghcr.io"); err != nil {
+		t.Errorf("Delete returned an error: %v", err)
+	}
+
+	expected := []string{"get", "store", "erase"}
+	if len(delegate.actions) != len(expected) {
+		t.Fatalf("Expected delegate actions %v, got: %v", expected, delegate.actions)
+	}
+	for i := range expected {
+		if delegate.actions[i] != expected[i] {
+			t.Errorf("Expected delegate actions %v, got: %v", expected, delegate.actions)
+		}
+	}
+	if delegate.inputs[0] != "https://index.docker.io/v1/" || delegate.inputs[2] != "ghcr.io" {
+		t.Errorf("Expected the server URLs to be passed to the delegate, got: %q", delegate.inputs)
+	}
+	if len(google.served) != 0 {
+		t.Errorf("Expected the GCR helper not to be used, got: %v", google.served)
+	}
+}
+
+func TestDelegatingHelper_DelegateNotFound(t *testing.T) {
+	delegate := &fakeDelegate{output: credentials.NewErrCredentialsNotFound().Error(), err: errors.New("exit status 1")}
+	tested := &delegatingHelper{google: &fakeHelper{}, delegate: delegate.program, delegateName: "fake"}
+
+	if _, _, err := tested.Get("https://index.docker.io/v1/"); !credentials.IsErrCredentialsNotFound(err) {
+		t.Errorf("Expected credentials not found, got: %v", err)
+	}
+}
+
+func TestDelegatingHelper_NoDelegate(t *testing.T) {
+	google := &fakeHelper{}
+	tested := &delegatingHelper{google: google}
+
+	if _, _, err := tested.Get("https://index.docker.io/v1/"); !credentials.IsErrCredentialsNotFound(err) {
+		t.Errorf("Expected other registries to have no credentials, got: %v", err)
+	}
+	if err := tested.Add(&credentials.Credentials{ServerURL: "ghcr.io"}); err == nil {
+		t.Error("Expected an error storing credentials without a delegate")
+	}
+	if creds, err := tested.List(); err != nil || len(creds) != 1 {
+		t.Errorf("Expected only the GCR helper's credentials, got: %v, %v", creds, err)
+	}
+	if len(google.served) != 0 {
+		t.Errorf("Expected the GCR helper not to be used, got: %v", google.served)
+	}
+}
+
+func TestDelegatingHelper_ListMergesCredentials(t *testing.T) {
+	delegate := &fakeDelegate{output: `{"ghcr.io":"someone","https://gcr.io":"stale"}`}
+	tested := &delegatingHelper{google: &fakeHelper{}, delegate: delegate.program, delegateName: "fake"}
+
+	creds, err := tested.List()
+	if err != nil {
+		t.Fatalf("List returned an error: %v", err)
+	}
+	if len(creds) != 2 || creds["gcr.io"] != "_json_key" || creds["ghcr.io"] != "someone" {
+		t.Errorf("Expected the GCR helper's and the delegate's other credentials, got: %v", creds)
+	}
+}
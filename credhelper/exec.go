@@ -0,0 +1,96 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	gauth "cloud.google.com/go/auth"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/util/cmd"
+)
+
+// execTokenOutput is the JSON output of an ExecSource's program in the
+// config.ExecFormatJSON format.
+type execTokenOutput struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   *int64 `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+// newExecCommand returns the command run by an ExecSource.
+func newExecCommand(command string) cmd.Command {
+	return &cmd.RealImpl{Command: command}
+}
+
+// execTokenSource returns the "exec:<name>" token source's retrieval function,
+// or false if no program of that name is configured.
+func (ch *gcrCredHelper) execTokenSource(ctx context.Context, name string) (func() (*accessToken, error), bool) {
+	src, ok := ch.userCfg.ExecSource(name)
+	if !ok {
+		return nil, false
+	}
+	return func() (*accessToken, error) { return tokenFromExec(ctx, ch.execCmd(src.Command), src) }, true
+}
+
+// tokenFromExec runs an ExecSource's program and validates the access token it
+// prints.
+func tokenFromExec(ctx context.Context, c cmd.Command, src config.ExecSource) (*accessToken, error) {
+	ctx, cancel := withTimeout(ctx, src.ExecTimeout())
+	defer cancel()
+	stdout, err := c.Exec(ctx, src.Args...)
+	if err != nil {
+		return nil, helperErr(fmt.Sprintf("`%s` failed", src.Command), err)
+	}
+
+	if src.OutputFormat() == config.ExecFormatRaw {
+		token := strings.TrimSpace(string(stdout))
+		if token == "" {
+			return nil, helperErr(fmt.Sprintf("`%s` printed an empty access token", src.Command), nil)
+		}
+		if strings.ContainsAny(token, " \t\r\n") {
+			return nil, helperErr(fmt.Sprintf("`%s` printed more than an access token", src.Command), nil)
+		}
+		return &accessToken{value: token}, nil
+	}
+
+	var out execTokenOutput
+	if err := json.Unmarshal(stdout, &out); err != nil {
+		return nil, helperErr(fmt.Sprintf("failed to parse the output of `%s`", src.Command), err)
+	}
+	token := &gauth.Token{Value: strings.TrimSpace(out.AccessToken)}
+	if token.Value == "" {
+		return nil, helperErr(fmt.Sprintf("`%s` returned an empty access_token", src.Command), nil)
+	}
+	if out.TokenType == "" {
+		return nil, helperErr(fmt.Sprintf("`%s` returned no token_type", src.Command), nil)
+	}
+	// token_type is case insensitive, as in any OAuth2 token response.
+	if !strings.EqualFold(out.TokenType, "Bearer") {
+		return nil, helperErr(fmt.Sprintf("expected token type \"Bearer\" but got \"%s\"", out.TokenType), nil)
+	}
+	if out.ExpiresIn == nil {
+		return nil, helperErr(fmt.Sprintf("`%s` returned no expires_in", src.Command), nil)
+	}
+	token.Expiry = time.Now().Add(time.Duration(*out.ExpiresIn) * time.Second)
+	if !isValidToken(token) {
+		return nil, helperErr(fmt.Sprintf("`%s` returned an expired access_token", src.Command), nil)
+	}
+	return &accessToken{value: token.Value, expiry: token.Expiry}, nil
+}
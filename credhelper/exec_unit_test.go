@@ -0,0 +1,137 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/mock/mock_cmd"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/mock/mock_config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/util/cmd"
+	"github.com/golang/mock/gomock"
+)
+
+func TestGetGCRAccessToken_ExecSource(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"exec:broker"})
+	mockUserCfg.EXPECT().ExecSource("broker").Return(config.ExecSource{Command: "broker-cli", Args: []string{"token", "--scope=gcr"}}, true)
+
+	mockCmd := mock_cmd.NewMockCommand(mockCtrl)
+	mockCmd.EXPECT().Exec(gomock.Any(), "token", "--scope=gcr").Return([]byte("ya29.broker\n"), nil)
+
+	var ran string
+	tested := &gcrCredHelper{
+		userCfg: mockUserCfg,
+		execCmd: func(command string) cmd.Command {
+			ran = command
+			return mockCmd
+		},
+	}
+
+	token, err := tested.getGCRAccessToken("gcr.io")
+
+	if err != nil {
+		t.Fatalf("getGCRAccessToken returned an error: %v", err)
+	}
+	if token.value != "ya29.broker" || token.source != "exec:broker" || ran != "broker-cli" {
+		t.Errorf("Expected the token printed by broker-cli, got: %+v from %s", token, ran)
+	}
+}
+
+func TestGetGCRAccessToken_ExecSourceNotConfigured(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"exec:broker"})
+	mockUserCfg.EXPECT().ExecSource("broker").Return(config.ExecSource{}, false)
+
+	tested := &gcrCredHelper{userCfg: mockUserCfg}
+
+	if token, err := tested.getGCRAccessToken("gcr.io"); err == nil {
+		t.Fatalf("Expected an error, got token: %s", token.value)
+	}
+}
+
+func TestTokenFromExec(t *testing.T) {
+	tests := []struct {
+		name          string
+		format        string
+		output        string
+		expectedToken string
+		expectExpiry  bool
+		wantErr       bool
+	}{
+		{"raw", "", "ya29.raw\n", "ya29.raw", false, false},
+		{"raw empty", config.ExecFormatRaw, "\n", "", false, true},
+		{"raw json", config.ExecFormatRaw, `{"access_token": "ya29.json"}`, "", false, true},
+		{"json", config.ExecFormatJSON, `{"access_token":"ya29.json","expires_in":3599,"token_type":"Bearer"}`, "ya29.json", true, false},
+		{"json lower case type", config.ExecFormatJSON, `{"access_token":"ya29.json","expires_in":3599,"token_type":"bearer"}`, "ya29.json", true, false},
+		{"json no expiry", config.ExecFormatJSON, `{"access_token":"ya29.json","token_type":"Bearer"}`, "", false, true},
+		{"json no type", config.ExecFormatJSON, `{"access_token":"ya29.json","expires_in":3599}`, "", false, true},
+		{"json expiring", config.ExecFormatJSON, `{"access_token":"ya29.json","expires_in":5,"token_type":"Bearer"}`, "", false, true},
+		{"json wrong type", config.ExecFormatJSON, `{"access_token":"ya29.json","expires_in":3599,"token_type":"MAC"}`, "", false, true},
+		{"json no token", config.ExecFormatJSON, `{"expires_in":3599,"token_type":"Bearer"}`, "", false, true},
+		{"json raw", config.ExecFormatJSON, "ya29.raw", "", false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockCmd := mock_cmd.NewMockCommand(mockCtrl)
+			mockCmd.EXPECT().Exec(gomock.Any()).Return([]byte(test.output), nil)
+
+			token, err := tokenFromExec(context.Background(), mockCmd, config.ExecSource{Command: "broker", Format: test.format})
+
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got token: %s", token.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("tokenFromExec returned an error: %v", err)
+			}
+			if token.value != test.expectedToken || token.expiry.IsZero() == test.expectExpiry {
+				t.Errorf("Expected token %s, with expiry: %v, got: %+v", test.expectedToken, test.expectExpiry, token)
+			}
+		})
+	}
+}
+
+func TestTokenFromExec_Timeout(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockCmd := mock_cmd.NewMockCommand(mockCtrl)
+	mockCmd.EXPECT().Exec(gomock.Any()).DoAndReturn(func(ctx context.Context, _ ...string) ([]byte, error) {
+		deadline, ok := ctx.Deadline()
+		if !ok || time.Until(deadline) > time.Minute {
+			t.Errorf("Expected the program's timeout to bound its execution, got deadline: %v", deadline)
+		}
+		return nil, errors.New("killed")
+	})
+
+	if _, err := tokenFromExec(context.Background(), mockCmd, config.ExecSource{Command: "broker", Timeout: "1m"}); err == nil {
+		t.Error("Expected the program's failure to be returned")
+	}
+}
@@ -23,130 +23,390 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	gauth "cloud.google.com/go/auth"
 	cloudcreds "cloud.google.com/go/auth/credentials"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/audit"
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/auth"
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/gcloud"
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/util"
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/util/cmd"
 	"github.com/docker/docker-credential-helpers/credentials"
 	"golang.org/x/oauth2"
 )
 
+// accessToken is an access token retrieved from a token source, along with
+// any metadata which was cheaply available.
+type accessToken struct {
+	value string
+	// expiry is the zero time if unknown.
+	expiry time.Time
+	// principal is the account the token was issued to, if known.
+	principal string
+	// source is the token source which issued the token.
+	source string
+	// credentialType is the type of the stored credential which issued the
+	// token, for the "store" source, if known.
+	credentialType string
+	// warning is set if the token was issued from a credential which needs
+	// attention, e.g. a key due for rotation.
+	warning string
+}
+
 // gcrCredHelper implements a credentials.Helper interface backed by a GCR
 // credential store.
 type gcrCredHelper struct {
+	// ctx bounds every request made by the helper, since credentials.Helper
+	// doesn't accept one.
+	ctx     context.Context
 	store   store.GCRCredStore
 	userCfg config.UserConfig
+	// the OAuth2 scopes to request, and whether policy pins them
+	scopes       []string
+	scopesPinned bool
+	// the deadlines for retrieving a token from all sources and from each
+	// source, 0 if unbounded
+	timeout       time.Duration
+	sourceTimeout time.Duration
+	// how token sources which fail with transient errors are retried
+	retry retryPolicy
+	// whether the user may be sent to a browser to reauthenticate
+	interactive bool
+	// which failures to refresh the stored credentials send the user to
+	// reauthenticate, one of config.ReauthPolicies
+	reauthPolicy string
+	// the command, and its arguments, run when the stored credentials need
+	// reauthentication; nil if there's none
+	notifyCmd  cmd.Command
+	notifyArgs []string
+	// the audit log of issued credentials, nil if auditing is disabled
+	auditLog *audit.Log
+	// the token sources chosen by the "auto" token source, once detected
+	auto []AutoTokenSource
 
 	// helper methods, package exposed for testing
-	envToken       func() (string, error)
-	gcloudSDKToken func(cmd.Command) (string, error)
-	credStoreToken func(store.GCRCredStore) (string, error)
+	envToken          func(ctx context.Context, scopes []string) (*accessToken, error)
+	gcloudSDKToken    func(context.Context, cmd.Command, config.GcloudSelection) (*accessToken, error)
+	gcloudNativeToken func(ctx context.Context, scopes []string, gcloudCmd cmd.Command, sel config.GcloudSelection) (*accessToken, error)
+	credStoreToken    func(ctx context.Context, scopes []string, s store.GCRCredStore) (*accessToken, error)
+	jsonKeyToken      func(ctx context.Context, scopes []string, key []byte) (*accessToken, error)
 
 	// `gcloud` exec interface, package exposed for testing
 	gcloudCmd cmd.Command
+	// the exec interface of "exec:<name>" token sources' programs, package
+	// exposed for testing
+	execCmd func(command string) cmd.Command
+
+	// tokeninfo lookup, package exposed for testing
+	tokenInfo func(ctx context.Context, accessToken string) (*auth.TokenInfo, error)
+
+	// interactive login, package exposed for testing
+	login func(ctx context.Context, agent *auth.GCRLoginAgent) (*oauth2.Token, error)
 }
 
 // NewGCRCredentialHelper returns a Docker credential helper which
-// specializes in GCR's authentication schemes.
-func NewGCRCredentialHelper(store store.GCRCredStore, userCfg config.UserConfig) credentials.Helper {
-	return &gcrCredHelper{
-		store:          store,
-		userCfg:        userCfg,
-		credStoreToken: tokenFromPrivateStore,
-		gcloudSDKToken: tokenFromGcloudSDK,
-		envToken:       tokenFromEnv,
-		gcloudCmd:      &cmd.RealImpl{Command: "gcloud"},
+// specializes in GCR's authentication schemes. Requests made on behalf of the
+// helper are abandoned once ctx is done. In credsStore mode, registries other
+// than Google's are delegated to the configured CredsStoreDelegate.
+func NewGCRCredentialHelper(ctx context.Context, store store.GCRCredStore, userCfg config.UserConfig) credentials.Helper {
+	ch := newGCRCredHelper(ctx, store, userCfg)
+	if userCfg.CredsStore() {
+		return newDelegatingHelper(ctx, ch, userCfg.CredsStoreDelegate())
 	}
+	return ch
 }
 
-// Delete lists all stored credentials and associated usernames.
-func (*gcrCredHelper) List() (map[string]string, error) {
-	return nil, errors.New("list is unimplemented")
+func newGCRCredHelper(ctx context.Context, store store.GCRCredStore, userCfg config.UserConfig) *gcrCredHelper {
+	ch := &gcrCredHelper{
+		ctx:               ctx,
+		store:             store,
+		userCfg:           userCfg,
+		scopes:            userCfg.Scopes(),
+		scopesPinned:      userCfg.ScopesPinned(),
+		timeout:           userCfg.Timeout(),
+		sourceTimeout:     userCfg.TokenSourceTimeout(),
+		retry:             retryPolicy{retries: userCfg.TokenSourceRetries(), backoff: userCfg.TokenSourceRetryBackoff()},
+		interactive:       isInteractive(userCfg.Interactive()),
+		reauthPolicy:      userCfg.ReauthPolicy(),
+		credStoreToken:    tokenFromPrivateStore,
+		gcloudSDKToken:    tokenFromGcloudSDK,
+		gcloudNativeToken: tokenFromGcloudNative,
+		envToken:          tokenFromEnv,
+		jsonKeyToken:      tokenFromJSONKey,
+		gcloudCmd:         &cmd.RealImpl{Command: "gcloud"},
+		execCmd:           newExecCommand,
+		tokenInfo:         auth.GetTokenInfo,
+		login:             performLogin,
+	}
+	ch.notifyCmd, ch.notifyArgs = newNotifyCommand(userCfg.ReauthNotifyCommand())
+	if path := userCfg.AuditLog(); path != "" {
+		ch.auditLog = &audit.Log{Path: path, MaxSize: userCfg.AuditLogMaxSize()}
+	}
+	return ch
 }
 
-// Add adds new third-party credentials to the keychain.
-func (*gcrCredHelper) Add(*credentials.Credentials) error {
-	return errors.New("add is unimplemented")
+// Get returns the username and secret to use for a given registry server URL.
+func (ch *gcrCredHelper) Get(serverURL string) (string, string, error) {
+	start := time.Now()
+	slog.Debug("get: credentials requested", "server_url", serverURL)
+	if err := ch.userCfg.CheckRegistry(serverURL); err != nil {
+		slog.Error("get: registry forbidden", "server_url", serverURL, "error", err)
+		err = helperErr("refusing to issue credentials", err)
+		ch.audit(serverURL, audit.OutcomeDenied, nil, err)
+		return "", "", err
+	}
+	tok, err := ch.registryCreds(serverURL)
+	outcome := audit.OutcomeSuccess
+	if err != nil {
+		outcome = audit.OutcomeFailure
+	}
+	ch.audit(serverURL, outcome, tok, err)
+	if err != nil {
+		slog.Error("get: failed", "server_url", serverURL, "duration", time.Since(start), "error", err)
+		return "", "", err
+	}
+	slog.Info("get: succeeded", "server_url", serverURL, "token_source", tok.source, "duration", time.Since(start))
+	return config.GcrOAuth2Username, tok.value, nil
 }
 
-// Delete removes third-party credentials from the store.
-func (*gcrCredHelper) Delete(string) error {
-	return errors.New("delete is unimplemented")
+// audit records a credential request and its outcome in the audit log, if
+// enabled. Failure to write the audit log is logged but doesn't fail the
+// request.
+func (ch *gcrCredHelper) audit(serverURL, outcome string, tok *accessToken, err error) {
+	if ch.auditLog == nil {
+		return
+	}
+	r := &audit.Record{
+		Time:      time.Now().UTC(),
+		ServerURL: serverURL,
+		Outcome:   outcome,
+	}
+	r.ParentPID, r.ParentName = audit.ParentProcess()
+	if tok != nil {
+		r.TokenSource = tok.source
+		r.Principal = tok.principal
+		if !tok.expiry.IsZero() {
+			expiry := tok.expiry.UTC()
+			r.TokenExpiry = &expiry
+		}
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if err := ch.auditLog.Append(r); err != nil {
+		slog.Error("unable to write the audit log", "path", ch.auditLog.Path, "error", err)
+	}
 }
 
-// Get returns the username and secret to use for a given registry server URL.
-func (ch *gcrCredHelper) Get(serverURL string) (string, string, error) {
-	return ch.gcrCreds()
-}
+// gcrCreds retrieves a GCR access token for the given registry. If the stored
+// credentials need reauthentication, the notification command is run and, if
+// permitted by the reauth policy and interactivity, the user is sent to sign in
+// again.
+func (ch *gcrCredHelper) gcrCreds(serverURL string) (*accessToken, error) {
+	token, err := ch.getGCRAccessToken(serverURL)
+	if err == nil {
+		return token, nil
+	}
+	// Only the credentials signed in with gcr-login can be renewed by signing
+	// in again, not e.g. gcloud's.
+	reason := reauthNone
+	var serr *tokenSourceError
+	if errors.As(err, &serr) && serr.source == "store" {
+		reason = classifyRefreshError(err)
+	}
+	if reason == reauthNone {
+		return nil, helperErr("could not retrieve GCR's access token", err)
+	}
 
-func (ch *gcrCredHelper) gcrCreds() (string, string, error) {
-	accessToken, err := ch.getGCRAccessToken()
+	slog.Warn("reauth required", "reason", reason, "error", err)
+	ch.notifyReauth(reason)
+	if !allowsLogin(ch.reauthPolicy, reason) || !ch.interactive {
+		return nil, helperErr(reason.String()+"; run `docker-credential-gcr gcr-login` to sign in again", nil)
+	}
+	fmt.Fprintf(os.Stderr, "Reauth required (%v); opening a browser to proceed...\n", reason)
+	// The user's login isn't bound by the token deadlines.
+	agent := ch.reauthAgent()
+	tok, err := ch.login(ch.context(), agent)
 	if err != nil {
-		if rerr, ok := err.(*oauth2.RetrieveError); ok {
-			var resp struct {
-				Error        string `json:"error"`
-				ErrorSubtype string `json:"error_subtype"`
-			}
-			if err := json.Unmarshal(rerr.Body, &resp); err == nil &&
-				resp.Error == "invalid_grant" &&
-				resp.ErrorSubtype == "invalid_rapt" {
-				fmt.Fprintln(os.Stderr, "Reauth required; opening a browser to proceed...")
-				tok, err := (&auth.GCRLoginAgent{}).PerformLogin()
-				if err != nil {
-					return "", "", fmt.Errorf("unable to authenticate user: %v", err)
-				}
-				if err = ch.store.SetGCRAuth(tok); err != nil {
-					return "", "", fmt.Errorf("unable to persist access token: %v", err)
-				}
-				fmt.Fprintln(os.Stderr, "Reauth successful!")
-				// Attempt the refresh dance again, using the new token.
-				if accessToken, err := ch.getGCRAccessToken(); err != nil {
-					return "", "", err
-				} else {
-					return config.GcrOAuth2Username, accessToken, nil
-				}
-			}
+		return nil, fmt.Errorf("unable to authenticate user: %v", err)
+	}
+	if err = ch.store.SetGCRAuth(tok, auth.LookupAccount(ch.context(), tok), agent.Client); err != nil {
+		return nil, fmt.Errorf("unable to persist access token: %v", err)
+	}
+	slog.Info("reauth succeeded")
+	fmt.Fprintln(os.Stderr, "Reauth successful!")
+	// Attempt the refresh dance again, using the new token.
+	return ch.getGCRAccessToken(serverURL)
+}
+
+// hasTerminal reports whether the user may be interacted with, made a variable
+// for testing.
+var hasTerminal = util.HasTerminal
+
+// isInteractive resolves the given interactivity mode: in the "auto" mode, the
+// helper is interactive if it's attached to a terminal, outside of CI.
+func isInteractive(mode string) bool {
+	switch mode {
+	case config.InteractiveAlways:
+		return true
+	case config.InteractiveNever:
+		return false
+	}
+	if ci, _ := strconv.ParseBool(os.Getenv("CI")); ci {
+		return false
+	}
+	return hasTerminal()
+}
+
+// reauthAgent returns the agent with which the user signs in again: with the
+// OAuth2 client which issued the stored credentials, suggesting the same
+// account.
+func (ch *gcrCredHelper) reauthAgent() *auth.GCRLoginAgent {
+	agent := &auth.GCRLoginAgent{Scopes: ch.scopes, ScopesPinned: ch.scopesPinned}
+	if stored, err := ch.store.GetGCRAuth(); err == nil {
+		agent.Client = stored.Client
+		agent.LoginHint = stored.Account
+	}
+	return agent
+}
+
+// performLogin signs the user in via their browser.
+func performLogin(ctx context.Context, agent *auth.GCRLoginAgent) (*oauth2.Token, error) {
+	return agent.PerformLogin(ctx)
+}
+
+// context returns the context bounding the helper's requests.
+func (ch *gcrCredHelper) context() context.Context {
+	if ch.ctx == nil {
+		return context.Background()
+	}
+	return ch.ctx
+}
+
+// withTimeout returns a copy of ctx with the given timeout, unless it's 0.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// tokenSource returns a function which retrieves a token for the given registry
+// from the named source, or false if the source is unknown.
+func (ch *gcrCredHelper) tokenSource(ctx context.Context, source, serverURL string) (func() (*accessToken, error), bool) {
+	switch source {
+	case "env":
+		return func() (*accessToken, error) { return ch.envToken(ctx, ch.scopes) }, true
+	case "envvar":
+		return tokenFromEnvVar, true
+	case "file":
+		path := ch.userCfg.AccessTokenFile()
+		return func() (*accessToken, error) { return tokenFromFile(path) }, true
+	case "gcloud":
+		sel := ch.userCfg.GcloudSelection(serverURL)
+		return func() (*accessToken, error) { return ch.gcloudSDKToken(ctx, ch.gcloudCmd, sel) }, true
+	case "gcloud-native":
+		sel := ch.userCfg.GcloudSelection(serverURL)
+		return func() (*accessToken, error) { return ch.gcloudNativeToken(ctx, ch.scopes, ch.gcloudCmd, sel) }, true
+	case "keyfile":
+		key := keyFile{
+			path:          ch.userCfg.KeyFile(),
+			selfSignedJWT: ch.userCfg.KeyFileSelfSignedJWT(),
+			rotationAge:   ch.userCfg.KeyRotationAge(),
 		}
-		if err != nil {
-			return "", "", helperErr("could not retrieve GCR's access token", err)
+		return func() (*accessToken, error) { return tokenFromKeyFile(ctx, ch.scopes, key) }, true
+	case "metadata":
+		src := metadataSource{
+			host:             ch.userCfg.MetadataHost(),
+			account:          ch.userCfg.MetadataServiceAccount(),
+			negativeCacheTTL: ch.userCfg.MetadataNegativeCacheTTL(),
 		}
+		return func() (*accessToken, error) { return tokenFromMetadata(ctx, ch.scopes, src, ch.store) }, true
+	case "store":
+		return func() (*accessToken, error) { return ch.credStoreToken(ctx, ch.scopes, ch.store) }, true
 	}
-	return config.GcrOAuth2Username, accessToken, nil
+	if name, ok := strings.CutPrefix(source, config.ExecTokenSourcePrefix); ok {
+		return ch.execTokenSource(ctx, name)
+	}
+	return nil, false
 }
 
-// getGCRAccessToken attempts to retrieve a GCR access token from the sources
-// listed by ch.tokenSources, in order.
-func (ch *gcrCredHelper) getGCRAccessToken() (string, error) {
-	var token string
+// getGCRAccessToken attempts to retrieve a GCR access token for the given
+// registry from the sources listed by ch.tokenSources, in order, within
+// ch.timeout. Each source is retried per ch.retry if it fails with a transient
+// error, and abandoned after ch.sourceTimeout.
+func (ch *gcrCredHelper) getGCRAccessToken(serverURL string) (*accessToken, error) {
+	var token *accessToken
 	var err error
 	tokenSources := ch.userCfg.TokenSources()
+	if len(tokenSources) == 0 {
+		return nil, helperErr("no permitted token sources are configured", nil)
+	}
+	ctx, cancel := withTimeout(ch.context(), ch.timeout)
+	defer cancel()
+	tokenSources = ch.expandTokenSources(ctx, tokenSources)
 	for _, source := range tokenSources {
-		switch source {
-		case "env":
-			token, err = ch.envToken()
-		case "gcloud", "gcloud_sdk": // gcloud_sdk supported for legacy reasons
-			token, err = ch.gcloudSDKToken(ch.gcloudCmd)
-		case "store":
-			token, err = ch.credStoreToken(ch.store)
-		default:
-			return "", helperErr("unknown token source: "+source, nil)
+		if ctx.Err() != nil {
+			break
+		}
+		start := time.Now()
+		sctx, cancel := withTimeout(ctx, ch.sourceTimeout)
+		get, ok := ch.tokenSource(sctx, source, serverURL)
+		if !ok {
+			cancel()
+			return nil, helperErr("unknown token source: "+source, nil)
+		}
+		token, err = ch.retry.do(sctx, source, get)
+		if err != nil && ctx.Err() == nil && sctx.Err() == context.DeadlineExceeded {
+			err = helperErr(fmt.Sprintf("token source %q timed out after %v", source, ch.sourceTimeout), nil)
 		}
+		cancel()
 
 		// if we successfully retrieved a token, break.
 		if err == nil {
+			token.source = source
+			slog.Debug("token source succeeded", "token_source", source, "duration", time.Since(start))
+			if token.warning != "" {
+				slog.Warn(token.warning, "token_source", source)
+			}
 			break
 		}
+		slog.Debug("token source failed", "token_source", source, "duration", time.Since(start), "error", err)
+		err = &tokenSourceError{source: source, err: err}
 	}
 
+	switch {
+	case err == nil && token != nil:
+	case ctx.Err() == context.DeadlineExceeded && ch.context().Err() == nil:
+		return nil, helperErr(fmt.Sprintf("timed out after %v retrieving a token", ch.timeout), nil)
+	case ctx.Err() != nil:
+		return nil, helperErr("abandoned retrieving a token", ctx.Err())
+	}
 	return token, err
 }
 
+// tokenSourceError is the failure of a token source, which is named so that
+// failures may be handled according to their source.
+type tokenSourceError struct {
+	source string
+	err    error
+}
+
+func (e *tokenSourceError) Error() string {
+	return e.err.Error()
+}
+
+func (e *tokenSourceError) Unwrap() error {
+	return e.err
+}
+
 /*
 tokenFromEnv retrieves a JWT access_token from the environment.
 
@@ -162,29 +422,41 @@ It looks for credentials in the following places, preferring the first location
     credentials from the metadata server.
     (In this final case any provided scopes are ignored.)
 */
-func tokenFromEnv() (string, error) {
+func tokenFromEnv(ctx context.Context, scopes []string) (*accessToken, error) {
 	creds, err := cloudcreds.DetectDefault(&cloudcreds.DetectOptions{
-		Scopes:           config.GCRScopes,
+		Scopes:           scopes,
 		UseSelfSignedJWT: true,
 	})
 	if err != nil {
-		return "", helperErr("failed to detect default credentials", err)
+		return nil, helperErr("failed to detect default credentials", err)
 	}
+	return credentialsToken(ctx, creds)
+}
 
-	token, err := creds.Token(context.Background())
+// credentialsToken retrieves and validates an access token from creds.
+func credentialsToken(ctx context.Context, creds *gauth.Credentials) (*accessToken, error) {
+	token, err := creds.Token(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if !isValidToken(token) {
-		return "", helperErr("token was invalid", nil)
+		return nil, helperErr("token was invalid", nil)
 	}
 
 	if token.Type != "Bearer" {
-		return "", helperErr(fmt.Sprintf("expected token type \"Bearer\" but got \"%s\"", token.Type), nil)
+		return nil, helperErr(fmt.Sprintf("expected token type \"Bearer\" but got \"%s\"", token.Type), nil)
+	}
+
+	// Service account key files identify their principal.
+	var key struct {
+		ClientEmail string `json:"client_email"`
+	}
+	if raw := creds.JSON(); raw != nil {
+		json.Unmarshal(raw, &key)
 	}
 
-	return token.Value, nil
+	return &accessToken{value: token.Value, expiry: token.Expiry, principal: key.ClientEmail}, nil
 }
 
 // isValidToken validates that the token is not empty, is not expired, and will
@@ -205,42 +477,107 @@ func isValidToken(t *gauth.Token) bool {
 	return true
 }
 
-// tokenFromGcloudSDK attempts to generate an access_token using the gcloud SDK.
-func tokenFromGcloudSDK(gcloudCmd cmd.Command) (string, error) {
+// gcloudConfigHelperOutput is the subset of the JSON output of
+// `gcloud config config-helper` used by the helper.
+type gcloudConfigHelperOutput struct {
+	Configuration struct {
+		Properties struct {
+			Core struct {
+				Account string `json:"account"`
+			} `json:"core"`
+		} `json:"properties"`
+	} `json:"configuration"`
+	Credential struct {
+		AccessToken string    `json:"access_token"`
+		TokenExpiry time.Time `json:"token_expiry"`
+	} `json:"credential"`
+}
+
+// tokenFromGcloudSDK attempts to generate an access_token using the gcloud SDK,
+// with the selected configuration and account.
+func tokenFromGcloudSDK(ctx context.Context, gcloudCmd cmd.Command, sel config.GcloudSelection) (*accessToken, error) {
 	// shelling out to gcloud is the only currently supported way of
 	// obtaining the gcloud access_token
-	stdout, err := gcloudCmd.Exec("config", "config-helper", "--force-auth-refresh", "--format=value(credential.access_token)")
+	args := []string{"config", "config-helper", "--force-auth-refresh", "--format=json"}
+	if sel.Configuration != "" {
+		args = append(args, "--configuration="+sel.Configuration)
+	}
+	if sel.Account != "" {
+		args = append(args, "--account="+sel.Account)
+	}
+	stdout, err := gcloudCmd.Exec(ctx, args...)
 	if err != nil {
-		return "", helperErr("`gcloud config config-helper` failed", err)
+		return nil, helperErr("`gcloud config config-helper` failed", err)
 	}
 
-	token := strings.TrimSpace(string(stdout))
+	var out gcloudConfigHelperOutput
+	if err := json.Unmarshal(stdout, &out); err != nil {
+		return nil, helperErr("failed to parse `gcloud config config-helper` output", err)
+	}
+
+	token := strings.TrimSpace(out.Credential.AccessToken)
 	if token == "" {
-		return "", helperErr("`gcloud config config-helper` returned an empty access_token", nil)
+		return nil, helperErr("`gcloud config config-helper` returned an empty access_token", nil)
 	}
-	return token, nil
+	return &accessToken{
+		value:     token,
+		expiry:    out.Credential.TokenExpiry,
+		principal: out.Configuration.Properties.Core.Account,
+	}, nil
 }
 
-func tokenFromPrivateStore(store store.GCRCredStore) (string, error) {
-	gcrAuth, err := store.GetGCRAuth()
+// tokenFromGcloudNative reads an access token for the selected gcloud account
+// directly from the gcloud config directory, falling back to executing gcloud
+// if the directory's layout isn't understood.
+func tokenFromGcloudNative(ctx context.Context, scopes []string, gcloudCmd cmd.Command, sel config.GcloudSelection) (*accessToken, error) {
+	cfg, err := gcloud.DefaultConfig()
+	if err != nil {
+		return nil, helperErr("unable to locate the gcloud config directory", err)
+	}
+	account, err := cfg.ResolveAccount(sel.Configuration, sel.Account)
+	if err == nil {
+		var tok *oauth2.Token
+		if tok, err = cfg.Token(ctx, account, scopes); err == nil {
+			return &accessToken{value: tok.AccessToken, expiry: tok.Expiry, principal: account}, nil
+		}
+	}
+	if errors.Is(err, gcloud.ErrUnsupported) {
+		slog.Debug("gcloud config not understood, executing gcloud", "dir", cfg.Dir, "error", err)
+		return tokenFromGcloudSDK(ctx, gcloudCmd, sel)
+	}
+	return nil, helperErr("unable to read gcloud credentials", err)
+}
+
+// tokenFromPrivateStore retrieves an access token from the helper's store:
+// minted from the imported credential, if any, and otherwise refreshed with the
+// signed-in user's refresh token.
+func tokenFromPrivateStore(ctx context.Context, scopes []string, s store.GCRCredStore) (*accessToken, error) {
+	imported, err := s.GetImportedCredential()
+	if err == nil {
+		return tokenFromImportedCredential(ctx, scopes, imported)
+	}
+	if !credentials.IsErrCredentialsNotFound(err) {
+		return nil, err
+	}
+	gcrAuth, err := s.GetGCRAuth()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	ts := gcrAuth.TokenSource(config.OAuthHTTPContext)
+	ts := gcrAuth.TokenSource(ctx)
 	tok, err := ts.Token()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if !tok.Valid() {
-		return "", helperErr("token was invalid", nil)
+		return nil, helperErr("token was invalid", nil)
 	}
 
-	return tok.AccessToken, nil
+	return &accessToken{value: tok.AccessToken, expiry: tok.Expiry, principal: gcrAuth.Account, credentialType: store.UserCredential}, nil
 }
 
 func helperErr(message string, err error) error {
 	if err == nil {
 		return fmt.Errorf("docker-credential-gcr/helper: %s", message)
 	}
-	return fmt.Errorf("docker-credential-gcr/helper: %s: %v", message, err)
+	return fmt.Errorf("docker-credential-gcr/helper: %s: %w", message, err)
 }
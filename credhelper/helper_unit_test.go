@@ -15,19 +15,27 @@
 package credhelper
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/mock/mock_cmd"
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/mock/mock_config" // mocks must be generated before test execution
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/mock/mock_store"
 
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/audit"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/auth"
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/util/cmd"
+	"github.com/docker/docker-credential-helpers/credentials"
 	"github.com/golang/mock/gomock"
+	"golang.org/x/oauth2"
 )
 
 var expectedGCRUsername = fmt.Sprintf("_dcgcr_%s_token", strings.ReplaceAll(config.Version, ".", "_"))
@@ -57,19 +65,21 @@ func TestGet_GCRCredentials(t *testing.T) {
 	tested := &gcrCredHelper{
 		store:   mockStore,
 		userCfg: mockUserCfg,
-		envToken: func() (string, error) {
-			return expectedSecret, nil
+		envToken: func(_ context.Context, _ []string) (*accessToken, error) {
+			return &accessToken{value: expectedSecret}, nil
 		},
-		gcloudSDKToken: func(_ cmd.Command) (string, error) {
-			return "", errors.New("no token here")
+		gcloudSDKToken: func(_ context.Context, _ cmd.Command, _ config.GcloudSelection) (*accessToken, error) {
+			return nil, errors.New("no token here")
 		},
-		credStoreToken: func(_ store.GCRCredStore) (string, error) {
-			return "", errors.New("no token here")
+		credStoreToken: func(_ context.Context, _ []string, _ store.GCRCredStore) (*accessToken, error) {
+			return nil, errors.New("no token here")
 		},
 	}
 
 	// Verify that all of GCR's hostnames return GCR's access token.
 	for _, host := range testGCRHosts {
+		mockUserCfg.EXPECT().CheckRegistry("https://" + host).Return(nil)
+		mockStore.EXPECT().GetRegistryKey(host).Return(nil, credentials.NewErrCredentialsNotFound())
 		mockUserCfg.EXPECT().TokenSources().Return(config.DefaultTokenSources[:])
 		username, secret, err := tested.Get("https://" + host)
 		if err != nil {
@@ -101,23 +111,23 @@ func TestGetGCRAccessToken_Env(t *testing.T) {
 	tested := &gcrCredHelper{
 		store:   mockStore,
 		userCfg: mockUserCfg,
-		envToken: func() (string, error) {
-			return expected, nil
+		envToken: func(_ context.Context, _ []string) (*accessToken, error) {
+			return &accessToken{value: expected}, nil
 		},
-		gcloudSDKToken: func(_ cmd.Command) (string, error) {
-			return "", errors.New("no token from gcloud")
+		gcloudSDKToken: func(_ context.Context, _ cmd.Command, _ config.GcloudSelection) (*accessToken, error) {
+			return nil, errors.New("no token from gcloud")
 		},
-		credStoreToken: func(_ store.GCRCredStore) (string, error) {
-			return "", errors.New("no token in the cred store")
+		credStoreToken: func(_ context.Context, _ []string, _ store.GCRCredStore) (*accessToken, error) {
+			return nil, errors.New("no token in the cred store")
 		},
 	}
 
-	token, err := tested.getGCRAccessToken()
+	token, err := tested.getGCRAccessToken("gcr.io")
 
 	if err != nil {
 		t.Fatalf("getGCRAccessToken returned an error: %v", err)
-	} else if token != expected {
-		t.Fatalf("Expected: %s got: %s", expected, token)
+	} else if token.value != expected {
+		t.Fatalf("Expected: %s got: %s", expected, token.value)
 	}
 }
 
@@ -136,24 +146,24 @@ func TestGetGCRAccessToken_PrivateStore(t *testing.T) {
 	tested := &gcrCredHelper{
 		store:   mockStore,
 		userCfg: mockUserCfg,
-		envToken: func() (string, error) {
-			return "creds from `env`", nil
+		envToken: func(_ context.Context, _ []string) (*accessToken, error) {
+			return &accessToken{value: "creds from `env`"}, nil
 		},
-		gcloudSDKToken: func(_ cmd.Command) (string, error) {
-			return "creds from `gcloud`", nil
+		gcloudSDKToken: func(_ context.Context, _ cmd.Command, _ config.GcloudSelection) (*accessToken, error) {
+			return &accessToken{value: "creds from `gcloud`"}, nil
 
 		},
-		credStoreToken: func(_ store.GCRCredStore) (string, error) {
-			return expected, nil
+		credStoreToken: func(_ context.Context, _ []string, _ store.GCRCredStore) (*accessToken, error) {
+			return &accessToken{value: expected}, nil
 		},
 	}
 
-	token, err := tested.getGCRAccessToken()
+	token, err := tested.getGCRAccessToken("gcr.io")
 
 	if err != nil {
 		t.Fatalf("getGCRAccessToken returned an error: %v", err)
-	} else if token != expected {
-		t.Fatalf("Expected: %s got: %s", expected, token)
+	} else if token.value != expected {
+		t.Fatalf("Expected: %s got: %s", expected, token.value)
 	}
 }
 
@@ -171,21 +181,21 @@ func TestGetGCRAccessToken_NoneExist(t *testing.T) {
 	tested := &gcrCredHelper{
 		store:   mockStore,
 		userCfg: mockUserCfg,
-		envToken: func() (string, error) {
-			return "", errors.New("no token here")
+		envToken: func(_ context.Context, _ []string) (*accessToken, error) {
+			return nil, errors.New("no token here")
 		},
-		gcloudSDKToken: func(_ cmd.Command) (string, error) {
-			return "", errors.New("still no token here")
+		gcloudSDKToken: func(_ context.Context, _ cmd.Command, _ config.GcloudSelection) (*accessToken, error) {
+			return nil, errors.New("still no token here")
 		},
-		credStoreToken: func(_ store.GCRCredStore) (string, error) {
-			return "", errors.New("sad panda")
+		credStoreToken: func(_ context.Context, _ []string, _ store.GCRCredStore) (*accessToken, error) {
+			return nil, errors.New("sad panda")
 		},
 	}
 
-	token, err := tested.getGCRAccessToken()
+	token, err := tested.getGCRAccessToken("gcr.io")
 
 	if err == nil {
-		t.Fatalf("Expected an error, got token: %s", token)
+		t.Fatalf("Expected an error, got token: %s", token.value)
 	}
 }
 
@@ -209,23 +219,23 @@ func TestGetGCRAccessToken_CustomTokenSources(t *testing.T) {
 	tested := &gcrCredHelper{
 		store:   mockStore,
 		userCfg: mockUserCfg,
-		envToken: func() (string, error) {
-			return envCreds, nil
+		envToken: func(_ context.Context, _ []string) (*accessToken, error) {
+			return &accessToken{value: envCreds}, nil
 		},
-		gcloudSDKToken: func(_ cmd.Command) (string, error) {
-			return gcloudCreds, nil
+		gcloudSDKToken: func(_ context.Context, _ cmd.Command, _ config.GcloudSelection) (*accessToken, error) {
+			return &accessToken{value: gcloudCreds}, nil
 		},
-		credStoreToken: func(_ store.GCRCredStore) (string, error) {
-			return storeCreds, nil
+		credStoreToken: func(_ context.Context, _ []string, _ store.GCRCredStore) (*accessToken, error) {
+			return &accessToken{value: storeCreds}, nil
 		},
 	}
 
-	token, err := tested.getGCRAccessToken()
+	token, err := tested.getGCRAccessToken("gcr.io")
 
 	if err != nil {
 		t.Fatalf("getGCRAccessToken returned an error: %v", err)
-	} else if token != storeCreds {
-		t.Fatalf("Expected: %s got: %s", storeCreds, token)
+	} else if token.value != storeCreds {
+		t.Fatalf("Expected: %s got: %s", storeCreds, token.value)
 	}
 }
 
@@ -239,6 +249,7 @@ func TestGetGCRAccessToken_CustomTokenSources_ValidSourcesDisabled(t *testing.T)
 	// Mock a user config, disabling some token sources.
 	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
 	mockUserCfg.EXPECT().TokenSources().Return([]string{"gcloud"}) // gcloud only configured source
+	mockUserCfg.EXPECT().GcloudSelection("gcr.io").Return(config.GcloudSelection{})
 
 	const (
 		storeCreds = "private creds!"
@@ -248,118 +259,549 @@ func TestGetGCRAccessToken_CustomTokenSources_ValidSourcesDisabled(t *testing.T)
 	tested := &gcrCredHelper{
 		store:   mockStore,
 		userCfg: mockUserCfg,
-		envToken: func() (string, error) {
-			return envCreds, nil
+		envToken: func(_ context.Context, _ []string) (*accessToken, error) {
+			return &accessToken{value: envCreds}, nil
 		},
-		gcloudSDKToken: func(_ cmd.Command) (string, error) {
-			return "", errors.New("no token here")
+		gcloudSDKToken: func(_ context.Context, _ cmd.Command, _ config.GcloudSelection) (*accessToken, error) {
+			return nil, errors.New("no token here")
 		},
-		credStoreToken: func(_ store.GCRCredStore) (string, error) {
-			return storeCreds, nil
+		credStoreToken: func(_ context.Context, _ []string, _ store.GCRCredStore) (*accessToken, error) {
+			return &accessToken{value: storeCreds}, nil
 		},
 	}
 
-	token, err := tested.getGCRAccessToken()
+	token, err := tested.getGCRAccessToken("gcr.io")
 
 	if err == nil {
-		t.Fatalf("Expected an error, got token: %s", token)
+		t.Fatalf("Expected an error, got token: %s", token.value)
 	}
 }
 
-func TestGetGCRAccessToken_OldGcloudSdkTokenSourceString(t *testing.T) {
+func TestGetGCRAccessToken_CustomTokenSources_InvalidSource(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
 	// create a mock store to use
 	mockStore := mock_store.NewMockGCRCredStore(mockCtrl)
 
-	// Mock a user config, disabling some token sources.
 	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
-	mockUserCfg.EXPECT().TokenSources().Return([]string{"gcloud_sdk"}) // the string that was initially used for specify gcloud
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"invalid"})
 
 	const (
-		envCreds    = "environment creds!"
 		gcloudCreds = "gcloud sdk creds!"
 		storeCreds  = "private creds!"
+		envCreds    = "environment creds!"
 	)
 	// mock the helper methods used by getGCRAccessToken
 	tested := &gcrCredHelper{
 		store:   mockStore,
 		userCfg: mockUserCfg,
-		envToken: func() (string, error) {
-			return envCreds, nil
+		envToken: func(_ context.Context, _ []string) (*accessToken, error) {
+			return &accessToken{value: envCreds}, nil
 		},
-		gcloudSDKToken: func(_ cmd.Command) (string, error) {
-			return gcloudCreds, nil
+		gcloudSDKToken: func(_ context.Context, _ cmd.Command, _ config.GcloudSelection) (*accessToken, error) {
+			return &accessToken{value: gcloudCreds}, nil
 		},
-		credStoreToken: func(_ store.GCRCredStore) (string, error) {
-			return storeCreds, nil
+		credStoreToken: func(_ context.Context, _ []string, _ store.GCRCredStore) (*accessToken, error) {
+			return &accessToken{value: storeCreds}, nil
 		},
 	}
 
-	token, err := tested.getGCRAccessToken()
+	token, err := tested.getGCRAccessToken("gcr.io")
+
+	if err == nil {
+		t.Fatalf("Expected an error, got token: %s", token.value)
+	}
+}
+
+func TestTokenFromGcloudSDK(t *testing.T) {
+	t.Parallel()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	const gcloudCreds = "gcloud sdk creds!"
+
+	// This test is more-or-less tautological, but it's important to verify
+	// that gcloud is being queried in a supported way.
+	mockCmd := mock_cmd.NewMockCommand(mockCtrl)
+	mockCmd.EXPECT().Exec(gomock.Any(), "config", "config-helper", "--force-auth-refresh", "--format=json").Return([]uint8(`{
+		"configuration": {"active_configuration": "default", "properties": {"core": {"account": "me@example.com"}}},
+		"credential": {"access_token": "`+gcloudCreds+`", "token_expiry": "2036-01-02T15:04:05Z"}
+	}`), nil)
+
+	token, err := tokenFromGcloudSDK(context.Background(), mockCmd, config.GcloudSelection{})
 
 	if err != nil {
 		t.Fatalf("tokenFromGcloudSDK returned an error: %v", err)
-	} else if token != gcloudCreds {
-		t.Fatalf("Expected: '%s' got: '%s'", gcloudCreds, token)
+	} else if token.value != gcloudCreds {
+		t.Fatalf("Expected: '%s' got: '%s'", gcloudCreds, token.value)
+	}
+	if token.principal != "me@example.com" {
+		t.Errorf("Expected principal: me@example.com, got: %s", token.principal)
+	}
+	if expected := time.Date(2036, 1, 2, 15, 4, 5, 0, time.UTC); !token.expiry.Equal(expected) {
+		t.Errorf("Expected expiry: %v, got: %v", expected, token.expiry)
 	}
 }
 
-func TestGetGCRAccessToken_CustomTokenSources_InvalidSource(t *testing.T) {
+func TestTokenFromGcloudSDK_Selection(t *testing.T) {
+	t.Parallel()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockCmd := mock_cmd.NewMockCommand(mockCtrl)
+	mockCmd.EXPECT().Exec(gomock.Any(), "config", "config-helper", "--force-auth-refresh", "--format=json", "--configuration=ci", "--account=ci@example.com").Return([]uint8(`{
+		"configuration": {"active_configuration": "ci", "properties": {"core": {"account": "ci@example.com"}}},
+		"credential": {"access_token": "ci creds", "token_expiry": "2036-01-02T15:04:05Z"}
+	}`), nil)
+
+	token, err := tokenFromGcloudSDK(context.Background(), mockCmd, config.GcloudSelection{Configuration: "ci", Account: "ci@example.com"})
+
+	if err != nil {
+		t.Fatalf("tokenFromGcloudSDK returned an error: %v", err)
+	}
+	if token.value != "ci creds" || token.principal != "ci@example.com" {
+		t.Errorf("Expected the token for ci@example.com, got: %+v", token)
+	}
+}
+
+func TestTokenFromGcloudNative(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	t.Setenv("CLOUDSDK_CONFIG", "../gcloud/testdata/valid")
+	t.Setenv("CLOUDSDK_ACTIVE_CONFIG_NAME", "")
+	t.Setenv("CLOUDSDK_CORE_ACCOUNT", "")
+
+	// gcloud isn't executed.
+	mockCmd := mock_cmd.NewMockCommand(mockCtrl)
+
+	token, err := tokenFromGcloudNative(context.Background(), nil, mockCmd, config.GcloudSelection{})
+
+	if err != nil {
+		t.Fatalf("tokenFromGcloudNative returned an error: %v", err)
+	}
+	if token.value != "cached-access-token" || token.principal != "user@example.com" {
+		t.Errorf("Expected gcloud's cached token for user@example.com, got: %+v", token)
+	}
+}
+
+func TestTokenFromGcloudNative_Account(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	t.Setenv("CLOUDSDK_CONFIG", "../gcloud/testdata/valid")
+	t.Setenv("CLOUDSDK_ACTIVE_CONFIG_NAME", "")
+	t.Setenv("CLOUDSDK_CORE_ACCOUNT", "")
+
+	// The user account's cached token is only returned for that account.
+	mockCmd := mock_cmd.NewMockCommand(mockCtrl)
+
+	token, err := tokenFromGcloudNative(context.Background(), nil, mockCmd, config.GcloudSelection{Account: "user@example.com", Configuration: "default"})
+
+	if err != nil {
+		t.Fatalf("tokenFromGcloudNative returned an error: %v", err)
+	}
+	if token.principal != "user@example.com" {
+		t.Errorf("Expected a token for user@example.com, got: %+v", token)
+	}
+}
+
+func TestTokenFromGcloudNative_Fallback(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	t.Setenv("CLOUDSDK_CONFIG", "../gcloud/testdata/legacy")
+	t.Setenv("CLOUDSDK_ACTIVE_CONFIG_NAME", "")
+	t.Setenv("CLOUDSDK_CORE_ACCOUNT", "")
+
+	mockCmd := mock_cmd.NewMockCommand(mockCtrl)
+	mockCmd.EXPECT().Exec(gomock.Any(), "config", "config-helper", "--force-auth-refresh", "--format=json", "--account=user@example.com").Return([]uint8(`{
+		"configuration": {"properties": {"core": {"account": "user@example.com"}}},
+		"credential": {"access_token": "exec token", "token_expiry": "2036-01-02T15:04:05Z"}
+	}`), nil)
+
+	token, err := tokenFromGcloudNative(context.Background(), nil, mockCmd, config.GcloudSelection{Account: "user@example.com"})
+
+	if err != nil {
+		t.Fatalf("tokenFromGcloudNative returned an error: %v", err)
+	}
+	if token.value != "exec token" {
+		t.Errorf("Expected the token from executing gcloud, got: %+v", token)
+	}
+}
+
+func TestGetGCRAccessToken_GcloudNative(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"gcloud-native"})
+	mockUserCfg.EXPECT().GcloudSelection("gcr.io").Return(config.GcloudSelection{Account: "ci@example.com"})
+	const expected = "native gcloud creds!"
+
+	tested := &gcrCredHelper{
+		userCfg: mockUserCfg,
+		scopes:  []string{"scope"},
+		gcloudNativeToken: func(_ context.Context, scopes []string, _ cmd.Command, sel config.GcloudSelection) (*accessToken, error) {
+			if len(scopes) != 1 || scopes[0] != "scope" {
+				t.Errorf("Expected the configured scopes, got: %v", scopes)
+			}
+			if sel.Account != "ci@example.com" {
+				t.Errorf("Expected the registry's gcloud selection, got: %+v", sel)
+			}
+			return &accessToken{value: expected}, nil
+		},
+	}
+
+	token, err := tested.getGCRAccessToken("gcr.io")
+
+	if err != nil {
+		t.Fatalf("getGCRAccessToken returned an error: %v", err)
+	}
+	if token.value != expected || token.source != "gcloud-native" {
+		t.Errorf("Expected the gcloud-native token, got: %+v", token)
+	}
+}
+
+// hangingToken is a token source which never returns until its context is
+// done, e.g. a hung metadata server.
+func hangingToken(ctx context.Context, _ []string) (*accessToken, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestGetGCRAccessToken_SourceTimeout(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"env", "store"})
+	const storeCreds = "private creds!"
+
+	tested := &gcrCredHelper{
+		userCfg:       mockUserCfg,
+		timeout:       time.Minute,
+		sourceTimeout: 10 * time.Millisecond,
+		envToken:      hangingToken,
+		credStoreToken: func(ctx context.Context, _ []string, _ store.GCRCredStore) (*accessToken, error) {
+			if err := ctx.Err(); err != nil {
+				t.Errorf("Expected a live context for the next source, got: %v", err)
+			}
+			return &accessToken{value: storeCreds}, nil
+		},
+	}
+
+	token, err := tested.getGCRAccessToken("gcr.io")
+
+	if err != nil {
+		t.Fatalf("getGCRAccessToken returned an error: %v", err)
+	}
+	if token.value != storeCreds {
+		t.Errorf("Expected the next source's token after a timeout, got: %+v", token)
+	}
+}
+
+func TestGetGCRAccessToken_Timeouts(t *testing.T) {
+	for _, tc := range []struct {
+		name                   string
+		timeout, sourceTimeout time.Duration
+		expected               string
+	}{
+		{"source", 0, 10 * time.Millisecond, `token source "env" timed out after 10ms`},
+		{"overall", 10 * time.Millisecond, time.Minute, "timed out after 10ms retrieving a token"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+			mockUserCfg.EXPECT().TokenSources().Return([]string{"env"})
+
+			tested := &gcrCredHelper{
+				userCfg:       mockUserCfg,
+				timeout:       tc.timeout,
+				sourceTimeout: tc.sourceTimeout,
+				envToken:      hangingToken,
+			}
+
+			_, err := tested.getGCRAccessToken("gcr.io")
+
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected an error containing %q, got: %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestGetGCRAccessToken_Canceled(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"env", "store"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tested := &gcrCredHelper{
+		ctx:     ctx,
+		userCfg: mockUserCfg,
+		envToken: func(ctx context.Context, scopes []string) (*accessToken, error) {
+			cancel()
+			return hangingToken(ctx, scopes)
+		},
+		credStoreToken: func(context.Context, []string, store.GCRCredStore) (*accessToken, error) {
+			t.Error("No further sources should be tried once canceled")
+			return nil, errors.New("unreachable")
+		},
+	}
+
+	_, err := tested.getGCRAccessToken("gcr.io")
+
+	if err == nil || !strings.Contains(err.Error(), "abandoned") {
+		t.Errorf("Expected the request to be abandoned, got: %v", err)
+	}
+}
+
+func TestGet_RegistryForbidden(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
-	// create a mock store to use
 	mockStore := mock_store.NewMockGCRCredStore(mockCtrl)
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().CheckRegistry("https://gcr.io").Return(errors.New("forbidden"))
+
+	tested := &gcrCredHelper{
+		store:   mockStore,
+		userCfg: mockUserCfg,
+		envToken: func(_ context.Context, _ []string) (*accessToken, error) {
+			t.Error("No token should be requested for a forbidden registry")
+			return nil, errors.New("unreachable")
+		},
+	}
 
+	if _, _, err := tested.Get("https://gcr.io"); err == nil {
+		t.Fatal("Expected an error for a forbidden registry")
+	}
+}
+
+func TestGetGCRAccessToken_NoTokenSources(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStore := mock_store.NewMockGCRCredStore(mockCtrl)
+
+	// e.g. every configured source is forbidden by policy
 	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
-	mockUserCfg.EXPECT().TokenSources().Return([]string{"invalid"})
+	mockUserCfg.EXPECT().TokenSources().Return(nil)
 
-	const (
-		gcloudCreds = "gcloud sdk creds!"
-		storeCreds  = "private creds!"
-		envCreds    = "environment creds!"
-	)
-	// mock the helper methods used by getGCRAccessToken
 	tested := &gcrCredHelper{
 		store:   mockStore,
 		userCfg: mockUserCfg,
-		envToken: func() (string, error) {
-			return envCreds, nil
+	}
+
+	token, err := tested.getGCRAccessToken("gcr.io")
+
+	if err == nil {
+		t.Fatalf("Expected an error, got token: %s", token.value)
+	}
+}
+
+func TestGet_Audit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStore := mock_store.NewMockGCRCredStore(mockCtrl)
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().CheckRegistry(gomock.Any()).Return(nil).Times(2)
+	mockStore.EXPECT().GetRegistryKey(gomock.Any()).Return(nil, credentials.NewErrCredentialsNotFound()).Times(2)
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"env"}).Times(2)
+
+	const secret = "audit me not"
+	expiry := time.Now().Add(time.Hour).UTC()
+	fail := false
+	auditLog := &audit.Log{Path: filepath.Join(t.TempDir(), "audit.log")}
+	tested := &gcrCredHelper{
+		store:    mockStore,
+		userCfg:  mockUserCfg,
+		auditLog: auditLog,
+		envToken: func(_ context.Context, _ []string) (*accessToken, error) {
+			if fail {
+				return nil, errors.New("no token here")
+			}
+			return &accessToken{value: secret, expiry: expiry, principal: "sa@example.com"}, nil
 		},
-		gcloudSDKToken: func(_ cmd.Command) (string, error) {
-			return gcloudCreds, nil
+	}
+
+	if _, _, err := tested.Get("https://gcr.io"); err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+	fail = true
+	if _, _, err := tested.Get("https://us.gcr.io"); err == nil {
+		t.Fatal("Expected Get to fail")
+	}
+
+	lines, err := auditLog.Tail(10)
+	if err != nil {
+		t.Fatalf("Unable to read the audit log: %v", err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 audit records, got: %v", lines)
+	}
+	for _, line := range lines {
+		if strings.Contains(line, secret) {
+			t.Fatalf("Audit record contains the token: %s", line)
+		}
+	}
+
+	var success, failure audit.Record
+	if err := json.Unmarshal([]byte(lines[0]), &success); err != nil {
+		t.Fatalf("Unable to decode audit record: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &failure); err != nil {
+		t.Fatalf("Unable to decode audit record: %v", err)
+	}
+	if success.Outcome != audit.OutcomeSuccess || success.ServerURL != "https://gcr.io" || success.TokenSource != "env" ||
+		success.Principal != "sa@example.com" || success.TokenExpiry == nil || !success.TokenExpiry.Equal(expiry) || success.ParentPID == 0 {
+		t.Errorf("Unexpected success record: %s", lines[0])
+	}
+	if failure.Outcome != audit.OutcomeFailure || failure.ServerURL != "https://us.gcr.io" || failure.Error == "" {
+		t.Errorf("Unexpected failure record: %s", lines[1])
+	}
+}
+
+func TestGet_AuditsDenials(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().CheckRegistry("https://evil.example.com").Return(errors.New("forbidden"))
+	auditLog := &audit.Log{Path: filepath.Join(t.TempDir(), "audit.log")}
+	tested := &gcrCredHelper{
+		store:    mock_store.NewMockGCRCredStore(mockCtrl),
+		userCfg:  mockUserCfg,
+		auditLog: auditLog,
+	}
+
+	if _, _, err := tested.Get("https://evil.example.com"); err == nil {
+		t.Fatal("Expected an error for a forbidden registry")
+	}
+
+	lines, err := auditLog.Tail(10)
+	if err != nil || len(lines) != 1 {
+		t.Fatalf("Expected 1 audit record, got: %v, %v", lines, err)
+	}
+	var denied audit.Record
+	if err := json.Unmarshal([]byte(lines[0]), &denied); err != nil {
+		t.Fatalf("Unable to decode audit record: %v", err)
+	}
+	if denied.Outcome != audit.OutcomeDenied || denied.ServerURL != "https://evil.example.com" || !strings.Contains(denied.Error, "forbidden") {
+		t.Errorf("Unexpected denial record: %s", lines[0])
+	}
+}
+
+// reauthRequired is the error returned by the token endpoint when the user must
+// reauthenticate.
+var reauthRequired = &oauth2.RetrieveError{Body: []byte(`{"error":"invalid_grant","error_subtype":"invalid_rapt"}`)}
+
+func TestGcrCreds_ReauthNonInteractive(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"store"})
+
+	tested := &gcrCredHelper{
+		userCfg: mockUserCfg,
+		credStoreToken: func(context.Context, []string, store.GCRCredStore) (*accessToken, error) {
+			return nil, reauthRequired
 		},
-		credStoreToken: func(_ store.GCRCredStore) (string, error) {
-			return storeCreds, nil
+		login: func(context.Context, *auth.GCRLoginAgent) (*oauth2.Token, error) {
+			t.Error("A browser shouldn't be opened when not interactive")
+			return nil, errors.New("unreachable")
 		},
 	}
 
-	token, err := tested.getGCRAccessToken()
+	_, err := tested.gcrCreds("gcr.io")
 
-	if err == nil {
-		t.Fatalf("Expected an error, got token: %s", token)
+	if err == nil || !strings.Contains(err.Error(), "gcr-login") {
+		t.Errorf("Expected an error directing the user to gcr-login, got: %v", err)
 	}
 }
 
-func TestTokenFromGcloudSDK(t *testing.T) {
-	t.Parallel()
+func TestGcrCreds_NoReauthForOtherSources(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"gcloud-native"})
+	mockUserCfg.EXPECT().GcloudSelection("gcr.io").Return(config.GcloudSelection{})
 
-	const gcloudCreds = "gcloud sdk creds!"
+	tested := &gcrCredHelper{
+		userCfg:     mockUserCfg,
+		interactive: true,
+		gcloudNativeToken: func(context.Context, []string, cmd.Command, config.GcloudSelection) (*accessToken, error) {
+			return nil, helperErr("unable to read gcloud credentials", reauthRequired)
+		},
+		login: func(context.Context, *auth.GCRLoginAgent) (*oauth2.Token, error) {
+			t.Error("gcloud's credentials can't be renewed with gcr-login")
+			return nil, errors.New("unreachable")
+		},
+	}
 
-	// This test is more-or-less tautological, but it's important to verify
-	// that gcloud is being queried in a supported way.
-	mockCmd := mock_cmd.NewMockCommand(mockCtrl)
-	mockCmd.EXPECT().Exec("config", "config-helper", "--force-auth-refresh", "--format=value(credential.access_token)").Return([]uint8(gcloudCreds), nil)
+	if _, err := tested.gcrCreds("gcr.io"); err == nil || !strings.Contains(err.Error(), "could not retrieve") {
+		t.Errorf("Expected the gcloud failure, got: %v", err)
+	}
+}
 
-	token, err := tokenFromGcloudSDK(mockCmd)
+func TestGcrCreds_ReauthInteractive(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"store"})
+	client := config.OAuthClient{ID: "my-client.apps.googleusercontent.com", Secret: "shh"}
+	mockStore := mock_store.NewMockGCRCredStore(mockCtrl)
+	mockStore.EXPECT().GetGCRAuth().Return(&store.GCRAuth{Account: "user@example.com", Client: client}, nil)
 
-	if err != nil {
-		t.Fatalf("tokenFromGcloudSDK returned an error: %v", err)
-	} else if token != gcloudCreds {
-		t.Fatalf("Expected: '%s' got: '%s'", gcloudCreds, token)
+	var agent *auth.GCRLoginAgent
+	tested := &gcrCredHelper{
+		store:       mockStore,
+		userCfg:     mockUserCfg,
+		interactive: true,
+		credStoreToken: func(context.Context, []string, store.GCRCredStore) (*accessToken, error) {
+			return nil, reauthRequired
+		},
+		login: func(_ context.Context, a *auth.GCRLoginAgent) (*oauth2.Token, error) {
+			agent = a
+			return nil, errors.New("the user closed the browser")
+		},
+	}
+
+	_, err := tested.gcrCreds("gcr.io")
+
+	if agent == nil || err == nil || !strings.Contains(err.Error(), "unable to authenticate user") {
+		t.Fatalf("Expected the user to be sent to reauthenticate, got: %v", err)
+	}
+	if agent.Client != client || agent.LoginHint != "user@example.com" {
+		t.Errorf("Expected to reauthenticate with the stored client and account, got: %+v", agent)
+	}
+}
+
+func TestIsInteractive(t *testing.T) {
+	origHasTerminal := hasTerminal
+	defer func() { hasTerminal = origHasTerminal }()
+	hasTerminal = func() bool { return true }
+
+	for _, tc := range []struct {
+		mode, ci string
+		expected bool
+	}{
+		{config.InteractiveAuto, "", true},
+		{config.InteractiveAuto, "true", false},
+		{config.InteractiveAlways, "true", true},
+		{config.InteractiveNever, "", false},
+	} {
+		t.Setenv("CI", tc.ci)
+		if got := isInteractive(tc.mode); got != tc.expected {
+			t.Errorf("isInteractive(%q) with CI=%q: expected %v, got: %v", tc.mode, tc.ci, tc.expected, got)
+		}
+	}
+
+	hasTerminal = func() bool { return false }
+	t.Setenv("CI", "")
+	if isInteractive(config.InteractiveAuto) {
+		t.Error("Expected the helper not to be interactive without a terminal")
 	}
 }
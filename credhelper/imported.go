@@ -0,0 +1,102 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	cloudcreds "cloud.google.com/go/auth/credentials"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+)
+
+// externalAccountConfig is the subset of an external account credential config
+// validated before it's imported.
+type externalAccountConfig struct {
+	Type                           string          `json:"type"`
+	Audience                       string          `json:"audience"`
+	SubjectTokenType               string          `json:"subject_token_type"`
+	CredentialSource               json.RawMessage `json:"credential_source"`
+	ServiceAccountImpersonationURL string          `json:"service_account_impersonation_url"`
+}
+
+// ParseCredential validates a credential of the given type, either
+// store.ServiceAccountCredential or store.ExternalAccountCredential, to be
+// imported into the store.
+func ParseCredential(data []byte, credType string) (*store.ImportedCredential, error) {
+	cred := &store.ImportedCredential{Type: credType, ImportedAt: time.Now().UTC(), JSON: data}
+	switch credType {
+	case store.ServiceAccountCredential:
+		var sa serviceAccountKey
+		if err := json.Unmarshal(data, &sa); err != nil {
+			return nil, helperErr("failed to decode the service account key", err)
+		}
+		if sa.Type != credType || sa.ClientEmail == "" || sa.PrivateKey == "" {
+			return nil, helperErr(fmt.Sprintf("not a service account key: expected type %q, client_email and private_key", credType), nil)
+		}
+		cred.Principal = sa.ClientEmail
+	case store.ExternalAccountCredential:
+		var ext externalAccountConfig
+		if err := json.Unmarshal(data, &ext); err != nil {
+			return nil, helperErr("failed to decode the credential config", err)
+		}
+		if ext.Type != credType || ext.Audience == "" || ext.SubjectTokenType == "" || len(ext.CredentialSource) == 0 {
+			return nil, helperErr(fmt.Sprintf("not an external account credential config: expected type %q, audience, subject_token_type and credential_source", credType), nil)
+		}
+		cred.Principal = impersonatedAccount(ext.ServiceAccountImpersonationURL)
+	default:
+		return nil, helperErr("unsupported credential type: "+credType, nil)
+	}
+	// Catch anything else the auth library would reject when minting tokens.
+	if _, err := cloudcreds.DetectDefault(&cloudcreds.DetectOptions{CredentialsJSON: data, Scopes: config.GCRScopes}); err != nil {
+		return nil, helperErr("invalid credential", err)
+	}
+	return cred, nil
+}
+
+// impersonatedAccount returns the service account impersonated by an external
+// account, given its service_account_impersonation_url, or "" if there's none.
+func impersonatedAccount(impersonationURL string) string {
+	const prefix, suffix = "/serviceAccounts/", ":generateAccessToken"
+	i := strings.LastIndex(impersonationURL, prefix)
+	if i < 0 || !strings.HasSuffix(impersonationURL, suffix) {
+		return ""
+	}
+	return strings.TrimSuffix(impersonationURL[i+len(prefix):], suffix)
+}
+
+// tokenFromImportedCredential mints an access token from an imported
+// credential.
+func tokenFromImportedCredential(ctx context.Context, scopes []string, imported *store.ImportedCredential) (*accessToken, error) {
+	creds, err := cloudcreds.DetectDefault(&cloudcreds.DetectOptions{
+		CredentialsJSON:  imported.JSON,
+		Scopes:           scopes,
+		UseSelfSignedJWT: true,
+	})
+	if err != nil {
+		return nil, helperErr("failed to load the imported credential", err)
+	}
+	tok, err := credentialsToken(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+	tok.principal = imported.Principal
+	tok.credentialType = imported.Type
+	return tok, nil
+}
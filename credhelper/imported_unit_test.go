@@ -0,0 +1,102 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+)
+
+const testExternalAccount = `{
+  "type": "external_account",
+  "audience": "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/ci/providers/github",
+  "subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+  "token_url": "https://sts.googleapis.com/v1/token",
+  "service_account_impersonation_url": "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/ci@my-project.iam.gserviceaccount.com:generateAccessToken",
+  "credential_source": {"file": "/var/run/secrets/token"}
+}`
+
+func TestParseCredential(t *testing.T) {
+	key := testJSONKey(t)
+	tests := []struct {
+		name              string
+		data              string
+		credType          string
+		expectedPrincipal string
+		wantErr           bool
+	}{
+		{"service account", key, store.ServiceAccountCredential, testServiceAccount, false},
+		{"external account", testExternalAccount, store.ExternalAccountCredential, testServiceAccount, false},
+		{"external account as key", testExternalAccount, store.ServiceAccountCredential, "", true},
+		{"key as external account", key, store.ExternalAccountCredential, "", true},
+		{"no credential source", `{"type":"external_account","audience":"a","subject_token_type":"t"}`, store.ExternalAccountCredential, "", true},
+		{"user", `{"type":"authorized_user","client_id":"id","refresh_token":"r"}`, store.UserCredential, "", true},
+		{"not json", "hunter2", store.ServiceAccountCredential, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cred, err := ParseCredential([]byte(test.data), test.credType)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got: %+v", cred)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCredential returned an error: %v", err)
+			}
+			if cred.Type != test.credType || cred.Principal != test.expectedPrincipal || string(cred.JSON) != test.data {
+				t.Errorf("Expected a %s credential for %s, got: %s for %s", test.credType, test.expectedPrincipal, cred.Type, cred.Principal)
+			}
+		})
+	}
+}
+
+func TestImpersonatedAccount(t *testing.T) {
+	for url, expected := range map[string]string{
+		"https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/sa@p.iam.gserviceaccount.com:generateAccessToken": "sa@p.iam.gserviceaccount.com",
+		"": "",
+		"https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/sa@p.iam.gserviceaccount.com": "",
+	} {
+		if actual := impersonatedAccount(url); actual != expected {
+			t.Errorf("impersonatedAccount(%q): expected %q, got %q", url, expected, actual)
+		}
+	}
+}
+
+func TestTokenFromPrivateStore_ImportedServiceAccount(t *testing.T) {
+	s := store.NewGCRCredStore(filepath.Join(t.TempDir(), "docker_credentials.json"))
+	cred, err := ParseCredential([]byte(testJSONKey(t)), store.ServiceAccountCredential)
+	if err != nil {
+		t.Fatalf("ParseCredential returned an error: %v", err)
+	}
+	if err := s.SetImportedCredential(cred); err != nil {
+		t.Fatalf("SetImportedCredential returned an error: %v", err)
+	}
+
+	// Scoped tokens are self-signed JWTs, minted without a request.
+	tok, err := tokenFromPrivateStore(context.Background(), config.GCRScopes, s)
+
+	if err != nil {
+		t.Fatalf("tokenFromPrivateStore returned an error: %v", err)
+	}
+	if tok.value == "" || tok.principal != testServiceAccount || tok.credentialType != store.ServiceAccountCredential {
+		t.Errorf("Expected a token for %s from the imported key, got: %+v", testServiceAccount, tok)
+	}
+}
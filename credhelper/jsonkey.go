@@ -0,0 +1,174 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	cloudcreds "cloud.google.com/go/auth/credentials"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/docker/docker-credential-helpers/credentials"
+)
+
+const (
+	// jsonKeyUsername is the username with which GCR and Artifact Registry
+	// accept a service account key as the password.
+	jsonKeyUsername = "_json_key"
+	// jsonKeyBase64Username is like jsonKeyUsername, for a base64 encoded key.
+	jsonKeyBase64Username = "_json_key_base64"
+)
+
+// serviceAccountKey is the subset of a service account key file validated
+// before the key is stored.
+type serviceAccountKey struct {
+	Type        string `json:"type"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+}
+
+// parseJSONKey returns the service account key passed as a Docker login's
+// password, and the key's service account.
+func parseJSONKey(username, secret string) ([]byte, string, error) {
+	key := []byte(secret)
+	switch username {
+	case jsonKeyUsername:
+	case jsonKeyBase64Username:
+		var err error
+		if key, err = base64.StdEncoding.DecodeString(strings.TrimSpace(secret)); err != nil {
+			return nil, "", helperErr("the password isn't a base64 encoded service account key", err)
+		}
+	default:
+		return nil, "", helperErr(fmt.Sprintf("unsupported username %q: only %s and %s service account keys can be stored", username, jsonKeyUsername, jsonKeyBase64Username), nil)
+	}
+	var sa serviceAccountKey
+	if err := json.Unmarshal(key, &sa); err != nil {
+		return nil, "", helperErr("the password isn't a service account key", err)
+	}
+	if sa.Type != "service_account" || sa.ClientEmail == "" || sa.PrivateKey == "" {
+		return nil, "", helperErr("the password isn't a service account key: expected type \"service_account\", client_email and private_key", nil)
+	}
+	return key, sa.ClientEmail, nil
+}
+
+// registryKeyHost returns the host under which a registry's service account
+// key is stored.
+func registryKeyHost(serverURL string) string {
+	return strings.ToLower(config.RegistryHost(serverURL))
+}
+
+// List lists the registries with a stored service account key, and the
+// username they were added with.
+func (ch *gcrCredHelper) List() (map[string]string, error) {
+	registries, err := ch.store.RegistryKeys()
+	if err != nil {
+		return nil, helperErr("unable to list the stored service account keys", err)
+	}
+	creds := make(map[string]string, len(registries))
+	for _, registry := range registries {
+		creds[registry] = jsonKeyUsername
+	}
+	return creds, nil
+}
+
+// Add stores a service account key for one of Google's registries, as passed
+// by `docker login -u _json_key` or `-u _json_key_base64`. Get then mints
+// short-lived access tokens from the key for that registry.
+func (ch *gcrCredHelper) Add(creds *credentials.Credentials) error {
+	if !config.IsGoogleRegistry(creds.ServerURL) {
+		return helperErr(fmt.Sprintf("%s isn't a Google registry", creds.ServerURL), nil)
+	}
+	if err := ch.userCfg.CheckRegistry(creds.ServerURL); err != nil {
+		return helperErr("refusing to store credentials", err)
+	}
+	if !ch.userCfg.AllowsTokenSource(config.JSONKeyTokenSource) {
+		return helperErr(fmt.Sprintf("refusing to store credentials: the %s token source is forbidden by policy", config.JSONKeyTokenSource), nil)
+	}
+	key, account, err := parseJSONKey(creds.Username, creds.Secret)
+	if err != nil {
+		return err
+	}
+	host := registryKeyHost(creds.ServerURL)
+	if err := ch.store.SetRegistryKey(host, key); err != nil {
+		return helperErr("unable to store the service account key", err)
+	}
+	slog.Info("add: stored service account key", "registry", host, "principal", account)
+	return nil
+}
+
+// Delete removes the service account key stored for a registry, if any.
+func (ch *gcrCredHelper) Delete(serverURL string) error {
+	host := registryKeyHost(serverURL)
+	if err := ch.store.DeleteRegistryKey(host); err != nil {
+		return helperErr("unable to delete the service account key", err)
+	}
+	slog.Info("delete: removed service account key", "registry", host)
+	return nil
+}
+
+// registryCreds retrieves an access token for the given registry: minted from
+// the service account key added for it, if any and permitted by policy, and
+// otherwise from the configured token sources.
+func (ch *gcrCredHelper) registryCreds(serverURL string) (*accessToken, error) {
+	if tok, ok, err := ch.registryKeyToken(serverURL); ok {
+		return tok, err
+	}
+	return ch.gcrCreds(serverURL)
+}
+
+// registryKeyToken mints an access token from the service account key added
+// for the given registry. It reports false if there's no such key, or policy
+// forbids its use.
+func (ch *gcrCredHelper) registryKeyToken(serverURL string) (*accessToken, bool, error) {
+	host := registryKeyHost(serverURL)
+	key, err := ch.store.GetRegistryKey(host)
+	if credentials.IsErrCredentialsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, helperErr("unable to read the stored service account key", err)
+	}
+	if !ch.userCfg.AllowsTokenSource(config.JSONKeyTokenSource) {
+		slog.Warn("ignoring the stored service account key, its token source is forbidden by policy", "registry", host, "token_source", config.JSONKeyTokenSource)
+		return nil, false, nil
+	}
+	ctx, cancel := withTimeout(ch.context(), ch.timeout)
+	defer cancel()
+	tok, err := ch.retry.do(ctx, config.JSONKeyTokenSource, func() (*accessToken, error) {
+		return ch.jsonKeyToken(ctx, ch.scopes, key)
+	})
+	if err != nil {
+		return nil, true, helperErr("could not mint an access token from the stored service account key", err)
+	}
+	tok.source = config.JSONKeyTokenSource
+	return tok, true, nil
+}
+
+// tokenFromJSONKey mints an access token from a service account key.
+func tokenFromJSONKey(ctx context.Context, scopes []string, key []byte) (*accessToken, error) {
+	creds, err := cloudcreds.DetectDefault(&cloudcreds.DetectOptions{
+		CredentialsJSON:  key,
+		Scopes:           scopes,
+		UseSelfSignedJWT: true,
+	})
+	if err != nil {
+		return nil, helperErr("failed to load the service account key", err)
+	}
+	return credentialsToken(ctx, creds)
+}
@@ -0,0 +1,216 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/mock/mock_config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/golang/mock/gomock"
+)
+
+const testServiceAccount = "ci@my-project.iam.gserviceaccount.com"
+
+// testJSONKey returns a service account key with a freshly generated private
+// key.
+func testJSONKey(t *testing.T) string {
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unable to generate a private key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(pk)
+	if err != nil {
+		t.Fatalf("Unable to marshal the private key: %v", err)
+	}
+	key, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "my-project",
+		"private_key_id": "k3y",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   testServiceAccount,
+		"client_id":      "1234",
+		"token_uri":      "https://oauth2.googleapis.com/token",
+	})
+	if err != nil {
+		t.Fatalf("Unable to marshal the service account key: %v", err)
+	}
+	return string(key)
+}
+
+func TestParseJSONKey(t *testing.T) {
+	key := testJSONKey(t)
+	tests := []struct {
+		name     string
+		username string
+		secret   string
+		wantErr  bool
+	}{
+		{"json", jsonKeyUsername, key, false},
+		{"base64", jsonKeyBase64Username, base64.StdEncoding.EncodeToString([]byte(key)) + "\n", false},
+		{"base64 not encoded", jsonKeyBase64Username, key, true},
+		{"access token", config.GcrOAuth2Username, "ya29.token", true},
+		{"not json", jsonKeyUsername, "hunter2", true},
+		{"user credentials", jsonKeyUsername, `{"type":"authorized_user","client_id":"id","refresh_token":"r"}`, true},
+		{"no private key", jsonKeyUsername, `{"type":"service_account","client_email":"` + testServiceAccount + `"}`, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed, account, err := parseJSONKey(test.username, test.secret)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got key for: %s", account)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseJSONKey returned an error: %v", err)
+			}
+			if string(parsed) != key || account != testServiceAccount {
+				t.Errorf("Expected the key for %s, got the key for: %s", testServiceAccount, account)
+			}
+		})
+	}
+}
+
+func TestAddGetDelete_JSONKey(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().CheckRegistry(gomock.Any()).Return(nil).AnyTimes()
+	mockUserCfg.EXPECT().AllowsTokenSource(config.JSONKeyTokenSource).Return(true).AnyTimes()
+
+	key := testJSONKey(t)
+	var minted []string
+	tested := &gcrCredHelper{
+		store:   store.NewGCRCredStore(filepath.Join(t.TempDir(), "docker_credentials.json")),
+		userCfg: mockUserCfg,
+		jsonKeyToken: func(_ context.Context, _ []string, k []byte) (*accessToken, error) {
+			minted = append(minted, string(k))
+			return &accessToken{value: "short-lived", principal: testServiceAccount}, nil
+		},
+	}
+
+	if err := tested.Add(&credentials.Credentials{ServerURL: "https://EU.gcr.io", Username: jsonKeyUsername, Secret: key}); err != nil {
+		t.Fatalf("Add returned an error: %v", err)
+	}
+
+	_, secret, err := tested.Get("eu.gcr.io")
+	if err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+	if secret != "short-lived" || len(minted) != 1 || minted[0] != key {
+		t.Errorf("Expected a token minted from the stored key, got: %s", secret)
+	}
+	if creds, err := tested.List(); err != nil || len(creds) != 1 || creds["eu.gcr.io"] != jsonKeyUsername {
+		t.Errorf("Expected the stored key to be listed, got: %v, %v", creds, err)
+	}
+
+	if err := tested.Delete("https://eu.gcr.io"); err != nil {
+		t.Fatalf("Delete returned an error: %v", err)
+	}
+	if creds, err := tested.List(); err != nil || len(creds) != 0 {
+		t.Errorf("Expected no stored keys, got: %v, %v", creds, err)
+	}
+}
+
+func TestAdd_NotGoogleRegistry(t *testing.T) {
+	tested := &gcrCredHelper{}
+
+	err := tested.Add(&credentials.Credentials{ServerURL: "https://index.docker.io/v1/", Username: jsonKeyUsername, Secret: testJSONKey(t)})
+
+	if err == nil {
+		t.Error("Expected keys for other registries to be refused")
+	}
+}
+
+func TestAdd_JSONKeyForbiddenByPolicy(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// As under a policy whose pinned TokenSources don't include json_key.
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().CheckRegistry(gomock.Any()).Return(nil)
+	mockUserCfg.EXPECT().AllowsTokenSource(config.JSONKeyTokenSource).Return(false)
+	tested := &gcrCredHelper{
+		store:   store.NewGCRCredStore(filepath.Join(t.TempDir(), "docker_credentials.json")),
+		userCfg: mockUserCfg,
+	}
+
+	if err := tested.Add(&credentials.Credentials{ServerURL: "https://gcr.io", Username: jsonKeyUsername, Secret: testJSONKey(t)}); err == nil {
+		t.Error("Expected the key to be refused")
+	}
+	if creds, err := tested.List(); err != nil || len(creds) != 0 {
+		t.Errorf("Expected no stored keys, got: %v, %v", creds, err)
+	}
+}
+
+func TestGet_IgnoresJSONKeyForbiddenByPolicy(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// As under a policy pinning TokenSources to env, installed after the key
+	// was added.
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().CheckRegistry(gomock.Any()).Return(nil).AnyTimes()
+	mockUserCfg.EXPECT().AllowsTokenSource(config.JSONKeyTokenSource).Return(false)
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"env"})
+	mockUserCfg.EXPECT().GcloudSelection(gomock.Any()).Return(config.GcloudSelection{}).AnyTimes()
+	tested := &gcrCredHelper{
+		store:   store.NewGCRCredStore(filepath.Join(t.TempDir(), "docker_credentials.json")),
+		userCfg: mockUserCfg,
+		envToken: func(context.Context, []string) (*accessToken, error) {
+			return &accessToken{value: "from-env"}, nil
+		},
+		jsonKeyToken: func(context.Context, []string, []byte) (*accessToken, error) {
+			t.Error("Expected no token to be minted from the forbidden key")
+			return nil, nil
+		},
+	}
+	if err := tested.store.SetRegistryKey("gcr.io", []byte(testJSONKey(t))); err != nil {
+		t.Fatalf("SetRegistryKey returned an error: %v", err)
+	}
+
+	_, secret, err := tested.Get("gcr.io")
+
+	if err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+	if secret != "from-env" {
+		t.Errorf("Expected a token from the pinned token source, got: %s", secret)
+	}
+}
+
+func TestTokenFromJSONKey(t *testing.T) {
+	// Scoped tokens are self-signed JWTs, minted without a request.
+	tok, err := tokenFromJSONKey(context.Background(), config.GCRScopes, []byte(testJSONKey(t)))
+	if err != nil {
+		t.Fatalf("tokenFromJSONKey returned an error: %v", err)
+	}
+	if tok.value == "" || tok.expiry.IsZero() || tok.principal != testServiceAccount {
+		t.Errorf("Expected a token for %s, got: %+v", testServiceAccount, tok)
+	}
+}
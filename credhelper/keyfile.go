@@ -0,0 +1,76 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	cloudcreds "cloud.google.com/go/auth/credentials"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+)
+
+// keyFile configures the "keyfile" token source.
+type keyFile struct {
+	// path is the service account key's path.
+	path string
+	// selfSignedJWT is whether tokens are self-signed JWTs, rather than access
+	// tokens obtained by exchanging a signed assertion at the key's token_uri.
+	selfSignedJWT bool
+	// rotationAge is the age after which the key should be rotated, 0 if
+	// there's none.
+	rotationAge time.Duration
+}
+
+// tokenFromKeyFile mints an access token from the configured service account
+// key. Keys carry no creation time, so the key file's modification time stands
+// in for the key's age.
+func tokenFromKeyFile(ctx context.Context, scopes []string, key keyFile) (*accessToken, error) {
+	if key.path == "" {
+		return nil, helperErr("no KeyFile is configured", nil)
+	}
+	fi, err := os.Stat(key.path)
+	if err != nil {
+		return nil, helperErr("unable to read the service account key", err)
+	}
+	data, err := os.ReadFile(key.path)
+	if err != nil {
+		return nil, helperErr("unable to read the service account key", err)
+	}
+	sa, err := ParseCredential(data, store.ServiceAccountCredential)
+	if err != nil {
+		return nil, helperErr("invalid KeyFile "+key.path, err)
+	}
+	creds, err := cloudcreds.DetectDefault(&cloudcreds.DetectOptions{
+		CredentialsJSON:  data,
+		Scopes:           scopes,
+		UseSelfSignedJWT: key.selfSignedJWT,
+	})
+	if err != nil {
+		return nil, helperErr("failed to load the service account key", err)
+	}
+	tok, err := credentialsToken(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+	tok.principal = sa.Principal
+	tok.credentialType = store.ServiceAccountCredential
+	if age := time.Since(fi.ModTime()); key.rotationAge > 0 && age > key.rotationAge {
+		tok.warning = fmt.Sprintf("the service account key %s is %d days old, past its KeyRotationAge of %v; rotate it", key.path, int(age.Hours()/24), key.rotationAge)
+	}
+	return tok, nil
+}
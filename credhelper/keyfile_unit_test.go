@@ -0,0 +1,122 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+)
+
+// writeTestKeyFile writes a service account key, whose token endpoint is
+// tokenURI if it's set, and returns its path.
+func writeTestKeyFile(t *testing.T, tokenURI string) string {
+	var key map[string]string
+	if err := json.Unmarshal([]byte(testJSONKey(t)), &key); err != nil {
+		t.Fatalf("Unable to decode the service account key: %v", err)
+	}
+	if tokenURI != "" {
+		key["token_uri"] = tokenURI
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		t.Fatalf("Unable to marshal the service account key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "sa.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Unable to write the service account key: %v", err)
+	}
+	return path
+}
+
+func TestTokenFromKeyFile_SelfSignedJWT(t *testing.T) {
+	path := writeTestKeyFile(t, "")
+
+	tok, err := tokenFromKeyFile(context.Background(), config.GCRScopes, keyFile{path: path, selfSignedJWT: true})
+
+	if err != nil {
+		t.Fatalf("tokenFromKeyFile returned an error: %v", err)
+	}
+	if strings.Count(tok.value, ".") != 2 || tok.principal != testServiceAccount || tok.warning != "" {
+		t.Errorf("Expected a self-signed JWT for %s, got: %+v", testServiceAccount, tok)
+	}
+}
+
+func TestTokenFromKeyFile_TokenEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || r.Form.Get("assertion") == "" {
+			http.Error(w, "expected a JWT bearer grant", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"ya29.exchanged","expires_in":3600,"token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+	path := writeTestKeyFile(t, server.URL)
+
+	tok, err := tokenFromKeyFile(context.Background(), config.GCRScopes, keyFile{path: path, selfSignedJWT: false})
+
+	if err != nil {
+		t.Fatalf("tokenFromKeyFile returned an error: %v", err)
+	}
+	if tok.value != "ya29.exchanged" || tok.principal != testServiceAccount {
+		t.Errorf("Expected the token issued by the key's token endpoint, got: %+v", tok)
+	}
+}
+
+func TestTokenFromKeyFile_RotationAge(t *testing.T) {
+	path := writeTestKeyFile(t, "")
+	created := time.Now().Add(-100 * 24 * time.Hour)
+	if err := os.Chtimes(path, created, created); err != nil {
+		t.Fatalf("Unable to age the service account key: %v", err)
+	}
+
+	for rotationAge, expectWarning := range map[time.Duration]bool{
+		0:                    false,
+		90 * 24 * time.Hour:  true,
+		365 * 24 * time.Hour: false,
+	} {
+		tok, err := tokenFromKeyFile(context.Background(), config.GCRScopes, keyFile{path: path, selfSignedJWT: true, rotationAge: rotationAge})
+		if err != nil {
+			t.Fatalf("tokenFromKeyFile returned an error: %v", err)
+		}
+		if (tok.warning != "") != expectWarning {
+			t.Errorf("Rotation age %v: expected a warning: %v, got: %q", rotationAge, expectWarning, tok.warning)
+		}
+		if expectWarning && !strings.Contains(tok.warning, "100 days old") {
+			t.Errorf("Expected the warning to give the key's age, got: %s", tok.warning)
+		}
+	}
+}
+
+func TestTokenFromKeyFile_Invalid(t *testing.T) {
+	notAKey := filepath.Join(t.TempDir(), "creds.json")
+	if err := os.WriteFile(notAKey, []byte(`{"type":"authorized_user","client_id":"id","refresh_token":"r"}`), 0600); err != nil {
+		t.Fatalf("Unable to write the credentials: %v", err)
+	}
+	for _, path := range []string{"", filepath.Join(t.TempDir(), "missing.json"), notAKey} {
+		if _, err := tokenFromKeyFile(context.Background(), config.GCRScopes, keyFile{path: path, selfSignedJWT: true}); err == nil {
+			t.Errorf("Expected an error for KeyFile %q", path)
+		}
+	}
+}
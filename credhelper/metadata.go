@@ -0,0 +1,230 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	gauth "cloud.google.com/go/auth"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+)
+
+const (
+	// defaultMetadataHost is the metadata server's address. It's used rather
+	// than metadata.google.internal so that off GCE, there's no DNS lookup.
+	defaultMetadataHost = "169.254.169.254"
+	// metadataHostEnvVar overrides the metadata server's address, as for the
+	// Cloud client libraries.
+	metadataHostEnvVar = "GCE_METADATA_HOST"
+	// metadataDialTimeout bounds connecting to the metadata server. Off GCE,
+	// connecting may otherwise hang until the token source's deadline.
+	metadataDialTimeout = time.Second
+)
+
+// metadataClient makes requests to the metadata server.
+var metadataClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:       nil, // the metadata server is never reached via a proxy
+		DialContext: (&net.Dialer{Timeout: metadataDialTimeout}).DialContext,
+	},
+}
+
+// metadataSource configures the "metadata" token source.
+type metadataSource struct {
+	// host is the metadata server's host, "" for the default.
+	host string
+	// account is the alias, e.g. "default", or email of the instance's service
+	// account.
+	account string
+	// negativeCacheTTL is how long the metadata server is remembered to be
+	// unreachable, 0 if it isn't.
+	negativeCacheTTL time.Duration
+}
+
+// metadataToken is the metadata server's access token response.
+type metadataToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+// errMetadataUnreachable is returned when the metadata server can't be reached,
+// i.e. the helper isn't running on Google Cloud.
+var errMetadataUnreachable = errors.New("the metadata server is unreachable")
+
+// errMetadataNotFound is returned when the metadata server has no such entry.
+var errMetadataNotFound = errors.New("not found")
+
+// metadataStatusError is returned when the metadata server responds with an
+// unexpected status, e.g. when it's throttling requests.
+type metadataStatusError struct {
+	statusCode int
+	status     string
+	body       string
+}
+
+func (e *metadataStatusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.status, e.body)
+}
+
+// metadataHost returns the metadata server's host, given the configured host.
+func metadataHost(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if host := os.Getenv(metadataHostEnvVar); host != "" {
+		return host
+	}
+	return defaultMetadataHost
+}
+
+// tokenFromMetadata obtains an access token for one of the instance's service
+// accounts from the metadata server. Once the metadata server is found to be
+// unreachable, that's recorded in s so that it isn't probed again until the
+// source's negativeCacheTTL has passed.
+func tokenFromMetadata(ctx context.Context, scopes []string, src metadataSource, s store.GCRCredStore) (*accessToken, error) {
+	host := metadataHost(src.host)
+	if err := checkMetadataUnavailable(s, host); err != nil {
+		return nil, err
+	}
+	tok, err := requestMetadataToken(ctx, host, src.account, scopes)
+	recordMetadataAvailability(ctx, s, host, src.negativeCacheTTL, err)
+	if err != nil {
+		return nil, helperErr("failed to obtain a token from the metadata server", err)
+	}
+	return tok, nil
+}
+
+// checkMetadataUnavailable returns an error if the metadata server at host is
+// recorded in s as unreachable, and isn't to be probed again yet.
+func checkMetadataUnavailable(s store.GCRCredStore, host string) error {
+	cached, err := s.GetMetadataUnavailable()
+	if err != nil {
+		slog.Debug("metadata: unable to read the negative cache", "error", err)
+		return nil
+	}
+	if cached != nil && cached.Host == host && time.Now().Before(cached.Until) {
+		return helperErr(fmt.Sprintf("the metadata server %s was unreachable, not probing it again until %s", host, cached.Until.Format(time.RFC3339)), nil)
+	}
+	return nil
+}
+
+// recordMetadataAvailability records in s, for ttl, that the metadata server
+// at host is unreachable if err says so, or otherwise removes any such record.
+func recordMetadataAvailability(ctx context.Context, s store.GCRCredStore, host string, ttl time.Duration, err error) {
+	var m *store.MetadataUnavailable
+	if errors.Is(err, errMetadataUnreachable) {
+		// A deadline which passed mid-request says nothing of the server.
+		if ttl <= 0 || ctx.Err() != nil {
+			return
+		}
+		m = &store.MetadataUnavailable{Host: host, Until: time.Now().Add(ttl)}
+	}
+	if err := s.SetMetadataUnavailable(m); err != nil {
+		slog.Debug("metadata: unable to write the negative cache", "error", err)
+	}
+}
+
+// requestMetadataToken requests an access token for the given service account
+// from the metadata server at host.
+func requestMetadataToken(ctx context.Context, host, account string, scopes []string) (*accessToken, error) {
+	var query url.Values
+	if len(scopes) > 0 {
+		query = url.Values{"scopes": {strings.Join(scopes, ",")}}
+	}
+	body, err := getMetadata(ctx, host, "instance/service-accounts/"+url.PathEscape(account)+"/token", query)
+	if errors.Is(err, errMetadataNotFound) {
+		return nil, fmt.Errorf("the instance has no service account %q", account)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var out metadataToken
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("unable to parse the token response: %v", err)
+	}
+	token := &gauth.Token{Value: out.AccessToken, Expiry: time.Now().Add(time.Duration(out.ExpiresIn) * time.Second)}
+	if !isValidToken(token) {
+		return nil, errors.New("token was invalid")
+	}
+	if out.TokenType != "Bearer" {
+		return nil, fmt.Errorf("expected token type \"Bearer\" but got \"%s\"", out.TokenType)
+	}
+	tok := &accessToken{value: token.Value, expiry: token.Expiry}
+	if strings.Contains(account, "@") {
+		tok.principal = account
+	}
+	return tok, nil
+}
+
+// requestMetadataEmail requests the email of the given service account from
+// the metadata server at host.
+func requestMetadataEmail(ctx context.Context, host, account string) (string, error) {
+	body, err := getMetadata(ctx, host, "instance/service-accounts/"+url.PathEscape(account)+"/email", nil)
+	if errors.Is(err, errMetadataNotFound) {
+		return "", fmt.Errorf("the instance has no service account %q", account)
+	}
+	return strings.TrimSpace(string(body)), err
+}
+
+// getMetadata returns the metadata at the given path, relative to
+// /computeMetadata/v1/, from the metadata server at host.
+func getMetadata(ctx context.Context, host, path string, query url.Values) ([]byte, error) {
+	u := fmt.Sprintf("http://%s/computeMetadata/v1/%s", host, path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		var opErr *net.OpError
+		var dnsErr *net.DNSError
+		if (errors.As(err, &opErr) && opErr.Op == "dial") || errors.As(err, &dnsErr) {
+			return nil, fmt.Errorf("%w: %v", errMetadataUnreachable, err)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// Anything else listening at the address isn't a metadata server.
+	if resp.Header.Get("Metadata-Flavor") != "Google" {
+		return nil, fmt.Errorf("%w: %s didn't respond as a metadata server", errMetadataUnreachable, host)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errMetadataNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &metadataStatusError{statusCode: resp.StatusCode, status: resp.Status, body: strings.TrimSpace(string(body))}
+	}
+	return body, nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+)
+
+// newMetadataServer returns a fake metadata server which issues tokens for
+// testServiceAccount, the instance's default service account, and its host.
+func newMetadataServer(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Metadata-Flavor", "Google")
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing Metadata-Flavor", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/computeMetadata/v1/instance/service-accounts/default/email" {
+			w.Write([]byte(testServiceAccount))
+			return
+		}
+		if r.URL.Path != "/computeMetadata/v1/instance/service-accounts/"+testServiceAccount+"/token" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("scopes") != strings.Join(config.GCRScopes, ",") {
+			http.Error(w, "unexpected scopes", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"ya29.metadata","expires_in":3599,"token_type":"Bearer"}`))
+	}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+// unreachableHost returns the address of a closed port.
+func unreachableHost(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	host := l.Addr().String()
+	l.Close()
+	return host
+}
+
+func TestTokenFromMetadata(t *testing.T) {
+	s := store.NewGCRCredStore(filepath.Join(t.TempDir(), "docker_credentials.json"))
+	src := metadataSource{host: newMetadataServer(t), account: testServiceAccount, negativeCacheTTL: time.Hour}
+
+	tok, err := tokenFromMetadata(context.Background(), config.GCRScopes, src, s)
+
+	if err != nil {
+		t.Fatalf("tokenFromMetadata returned an error: %v", err)
+	}
+	if tok.value != "ya29.metadata" || tok.principal != testServiceAccount || tok.expiry.IsZero() {
+		t.Errorf("Expected a token for %s, got: %+v", testServiceAccount, tok)
+	}
+}
+
+func TestTokenFromMetadata_UnknownServiceAccount(t *testing.T) {
+	s := store.NewGCRCredStore(filepath.Join(t.TempDir(), "docker_credentials.json"))
+	src := metadataSource{host: newMetadataServer(t), account: "other", negativeCacheTTL: time.Hour}
+
+	if _, err := tokenFromMetadata(context.Background(), config.GCRScopes, src, s); err == nil || !strings.Contains(err.Error(), `no service account "other"`) {
+		t.Errorf("Expected an error naming the service account, got: %v", err)
+	}
+	if cached, err := s.GetMetadataUnavailable(); err != nil || cached != nil {
+		t.Errorf("Expected a reachable metadata server not to be cached as unavailable, got: %+v, %v", cached, err)
+	}
+}
+
+func TestTokenFromMetadata_NegativeCache(t *testing.T) {
+	s := store.NewGCRCredStore(filepath.Join(t.TempDir(), "docker_credentials.json"))
+	host := unreachableHost(t)
+	src := metadataSource{host: host, account: "default", negativeCacheTTL: time.Hour}
+
+	if _, err := tokenFromMetadata(context.Background(), config.GCRScopes, src, s); err == nil {
+		t.Fatal("Expected an error for an unreachable metadata server")
+	}
+	cached, err := s.GetMetadataUnavailable()
+	if err != nil || cached == nil || cached.Host != host || time.Until(cached.Until) < 59*time.Minute {
+		t.Fatalf("Expected %s to be cached as unavailable for an hour, got: %+v, %v", host, cached, err)
+	}
+
+	// Later requests fail without probing.
+	if _, err := tokenFromMetadata(context.Background(), config.GCRScopes, src, s); err == nil || !strings.Contains(err.Error(), "not probing it again") {
+		t.Errorf("Expected the cached failure, got: %v", err)
+	}
+
+	// Another metadata server is probed, and clears the record once reached.
+	src.host = newMetadataServer(t)
+	src.account = testServiceAccount
+	if _, err := tokenFromMetadata(context.Background(), config.GCRScopes, src, s); err != nil {
+		t.Fatalf("tokenFromMetadata returned an error: %v", err)
+	}
+	if cached, err := s.GetMetadataUnavailable(); err != nil || cached != nil {
+		t.Errorf("Expected the record to be cleared, got: %+v, %v", cached, err)
+	}
+}
+
+func TestTokenFromMetadata_NotAMetadataServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"ya29.impostor","expires_in":3599,"token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+	s := store.NewGCRCredStore(filepath.Join(t.TempDir(), "docker_credentials.json"))
+	src := metadataSource{host: strings.TrimPrefix(server.URL, "http://"), account: "default", negativeCacheTTL: time.Hour}
+
+	if tok, err := tokenFromMetadata(context.Background(), config.GCRScopes, src, s); err == nil {
+		t.Fatalf("Expected an error, got token: %s", tok.value)
+	}
+	if cached, err := s.GetMetadataUnavailable(); err != nil || cached == nil {
+		t.Errorf("Expected the server to be cached as unavailable, got: %+v, %v", cached, err)
+	}
+}
+
+func TestTokenFromMetadata_NegativeCacheDisabled(t *testing.T) {
+	s := store.NewGCRCredStore(filepath.Join(t.TempDir(), "docker_credentials.json"))
+	src := metadataSource{host: unreachableHost(t), account: "default"}
+
+	if _, err := tokenFromMetadata(context.Background(), config.GCRScopes, src, s); err == nil {
+		t.Fatal("Expected an error for an unreachable metadata server")
+	}
+	if cached, err := s.GetMetadataUnavailable(); err != nil || cached != nil {
+		t.Errorf("Expected nothing to be cached, got: %+v, %v", cached, err)
+	}
+}
+
+func TestMetadataHost(t *testing.T) {
+	t.Setenv(metadataHostEnvVar, "")
+	if host := metadataHost(""); host != defaultMetadataHost {
+		t.Errorf("Expected the default metadata host, got: %s", host)
+	}
+	t.Setenv(metadataHostEnvVar, "metadata.example.com:8080")
+	if host := metadataHost(""); host != "metadata.example.com:8080" {
+		t.Errorf("Expected the metadata host in %s, got: %s", metadataHostEnvVar, host)
+	}
+	if host := metadataHost("configured:80"); host != "configured:80" {
+		t.Errorf("Expected the configured metadata host, got: %s", host)
+	}
+}
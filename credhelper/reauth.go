@@ -0,0 +1,118 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/util/cmd"
+	"golang.org/x/oauth2"
+)
+
+// notifyTimeout bounds the reauthentication notification command.
+const notifyTimeout = 10 * time.Second
+
+// reauthReason classifies a failure to refresh the stored credentials which
+// signing in again would fix.
+type reauthReason int
+
+const (
+	// reauthNone is any other failure.
+	reauthNone reauthReason = iota
+	// reauthRAPT is a reauthentication challenge, e.g. under a session
+	// control policy.
+	reauthRAPT
+	// reauthExpired is an expired refresh token, e.g. once a session length
+	// limit is reached.
+	reauthExpired
+	// reauthRevoked is a revoked refresh token, e.g. after a password change.
+	reauthRevoked
+)
+
+func (r reauthReason) String() string {
+	switch r {
+	case reauthRAPT:
+		return "reauthentication required"
+	case reauthExpired:
+		return "the stored credentials have expired"
+	case reauthRevoked:
+		return "the stored credentials have been revoked"
+	}
+	return "none"
+}
+
+// classifyRefreshError classifies the given token source failure.
+func classifyRefreshError(err error) reauthReason {
+	var rerr *oauth2.RetrieveError
+	if !errors.As(err, &rerr) {
+		return reauthNone
+	}
+	var resp struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+		ErrorSubtype     string `json:"error_subtype"`
+	}
+	if err := json.Unmarshal(rerr.Body, &resp); err != nil || resp.Error != "invalid_grant" {
+		return reauthNone
+	}
+	switch {
+	case resp.ErrorSubtype == "invalid_rapt" || resp.ErrorSubtype == "rapt_required":
+		return reauthRAPT
+	case strings.Contains(strings.ToLower(resp.ErrorDescription), "expired"):
+		return reauthExpired
+	}
+	return reauthRevoked
+}
+
+// allowsLogin reports whether the given reauthentication policy sends the user
+// to sign in again for the given reason.
+func allowsLogin(policy string, reason reauthReason) bool {
+	switch policy {
+	case config.ReauthPolicyAny:
+		return reason != reauthNone
+	case config.ReauthPolicyNever:
+		return false
+	}
+	return reason == reauthRAPT
+}
+
+// notifyReauth runs the configured notification command, if any, with a
+// message describing the reason as its final argument. Failures are logged.
+func (ch *gcrCredHelper) notifyReauth(reason reauthReason) {
+	if ch.notifyCmd == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ch.context(), notifyTimeout)
+	defer cancel()
+	msg := "docker-credential-gcr: " + reason.String() + "; run `docker-credential-gcr gcr-login` to sign in again"
+	if _, err := ch.notifyCmd.Exec(ctx, append(ch.notifyArgs, msg)...); err != nil {
+		slog.Error("reauth notification failed", "error", err)
+	}
+}
+
+// newNotifyCommand returns the given notification command and its arguments,
+// or nil if there's none.
+func newNotifyCommand(argv []string) (cmd.Command, []string) {
+	if len(argv) == 0 {
+		return nil, nil
+	}
+	return &cmd.RealImpl{Command: argv[0]}, argv[1:]
+}
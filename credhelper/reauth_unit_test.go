@@ -0,0 +1,155 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/auth"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/mock/mock_cmd"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/mock/mock_config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/mock/mock_store"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+	"github.com/golang/mock/gomock"
+	"golang.org/x/oauth2"
+)
+
+// refreshErr returns the error returned by the token endpoint with the given
+// response body.
+func refreshErr(body string) error {
+	return &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusBadRequest}, Body: []byte(body)}
+}
+
+func TestClassifyRefreshError(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		err      error
+		expected reauthReason
+	}{
+		{"rapt", refreshErr(`{"error":"invalid_grant","error_description":"reauth related error (invalid_rapt)","error_subtype":"invalid_rapt"}`), reauthRAPT},
+		{"rapt required", refreshErr(`{"error":"invalid_grant","error_subtype":"rapt_required"}`), reauthRAPT},
+		{"session expired", refreshErr(`{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`), reauthExpired},
+		{"password changed", refreshErr(`{"error":"invalid_grant","error_description":"Bad Request"}`), reauthRevoked},
+		{"wrapped", fmt.Errorf("refresh: %w", refreshErr(`{"error":"invalid_grant"}`)), reauthRevoked},
+		{"invalid client", refreshErr(`{"error":"invalid_client","error_description":"The OAuth client was deleted."}`), reauthNone},
+		{"not json", refreshErr(`<html>Bad Gateway</html>`), reauthNone},
+		{"other error", errors.New("connection refused"), reauthNone},
+	} {
+		if got := classifyRefreshError(tc.err); got != tc.expected {
+			t.Errorf("%s: expected %v, got: %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestGcrCreds_ReauthPolicy(t *testing.T) {
+	const (
+		rapt    = `{"error":"invalid_grant","error_subtype":"invalid_rapt"}`
+		expired = `{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`
+	)
+	for _, tc := range []struct {
+		name        string
+		policy      string
+		body        string
+		expectLogin bool
+	}{
+		{"rapt policy, rapt", config.ReauthPolicyRAPT, rapt, true},
+		{"rapt policy, expired", config.ReauthPolicyRAPT, expired, false},
+		{"any policy, expired", config.ReauthPolicyAny, expired, true},
+		{"never policy, rapt", config.ReauthPolicyNever, rapt, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+			mockUserCfg.EXPECT().TokenSources().Return([]string{"store"})
+			mockStore := mock_store.NewMockGCRCredStore(mockCtrl)
+			if tc.expectLogin {
+				mockStore.EXPECT().GetGCRAuth().Return(&store.GCRAuth{}, nil)
+			}
+
+			loggedIn := false
+			tested := &gcrCredHelper{
+				store:        mockStore,
+				userCfg:      mockUserCfg,
+				interactive:  true,
+				reauthPolicy: tc.policy,
+				credStoreToken: func(context.Context, []string, store.GCRCredStore) (*accessToken, error) {
+					return nil, refreshErr(tc.body)
+				},
+				login: func(context.Context, *auth.GCRLoginAgent) (*oauth2.Token, error) {
+					loggedIn = true
+					return nil, errors.New("the user closed the browser")
+				},
+			}
+
+			_, err := tested.gcrCreds("gcr.io")
+
+			if loggedIn != tc.expectLogin {
+				t.Errorf("Expected login: %v, got: %v", tc.expectLogin, loggedIn)
+			}
+			if !tc.expectLogin && (err == nil || !strings.Contains(err.Error(), "gcr-login")) {
+				t.Errorf("Expected an error directing the user to gcr-login, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestGcrCreds_ReauthNotification(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"store"})
+	mockCmd := mock_cmd.NewMockCommand(mockCtrl)
+	mockCmd.EXPECT().Exec(gomock.Any(), "--urgency=critical", "docker-credential-gcr: the stored credentials have been revoked; run `docker-credential-gcr gcr-login` to sign in again").Return(nil, nil)
+
+	tested := &gcrCredHelper{
+		userCfg:    mockUserCfg,
+		notifyCmd:  mockCmd,
+		notifyArgs: []string{"--urgency=critical"},
+		credStoreToken: func(context.Context, []string, store.GCRCredStore) (*accessToken, error) {
+			return nil, refreshErr(`{"error":"invalid_grant","error_description":"Bad Request"}`)
+		},
+	}
+
+	if _, err := tested.gcrCreds("gcr.io"); err == nil {
+		t.Fatal("Expected an error")
+	}
+}
+
+func TestGcrCreds_NoNotificationForOtherFailures(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"store"})
+
+	// Any call to the notification command fails the test.
+	tested := &gcrCredHelper{
+		userCfg:   mockUserCfg,
+		notifyCmd: mock_cmd.NewMockCommand(mockCtrl),
+		credStoreToken: func(context.Context, []string, store.GCRCredStore) (*accessToken, error) {
+			return nil, errors.New("no credentials stored")
+		},
+	}
+
+	if _, err := tested.gcrCreds("gcr.io"); err == nil {
+		t.Fatal("Expected an error")
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	gauth "cloud.google.com/go/auth"
+	"golang.org/x/oauth2"
+)
+
+// maxRetryBackoff caps the delay between retries of a token source.
+const maxRetryBackoff = 10 * time.Second
+
+// retryPolicy describes how a token source which failed with a transient
+// error is retried.
+type retryPolicy struct {
+	// retries is the number of retries after the first attempt.
+	retries int
+	// backoff is the delay before the first retry, doubled for each
+	// subsequent retry, up to maxRetryBackoff.
+	backoff time.Duration
+}
+
+// do calls get until it succeeds, fails with an error which isn't transient,
+// the retries are exhausted, or ctx is done.
+func (p retryPolicy) do(ctx context.Context, source string, get func() (*accessToken, error)) (*accessToken, error) {
+	for attempt := 0; ; attempt++ {
+		token, err := get()
+		if err == nil || attempt >= p.retries || !isTransient(err) {
+			return token, err
+		}
+		delay := p.delay(attempt)
+		slog.Debug("token source failed, retrying", "token_source", source, "attempt", attempt+1, "delay", delay, "error", err)
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, err
+		case <-t.C:
+		}
+	}
+}
+
+// delay returns the delay before the given retry: the exponential backoff,
+// with "equal jitter" so that concurrent helpers don't retry in lockstep.
+func (p retryPolicy) delay(attempt int) time.Duration {
+	d := p.backoff
+	for i := 0; i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, maxRetryBackoff)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// isTransient reports whether err may succeed if retried: a server error or
+// throttling by the token endpoint or metadata server, or a dropped
+// connection. Rejected credentials, e.g. invalid_grant, aren't transient.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var rerr *oauth2.RetrieveError
+	if errors.As(err, &rerr) {
+		return rerr.Response != nil && isTransientStatus(rerr.Response.StatusCode)
+	}
+	var aerr *gauth.Error
+	if errors.As(err, &aerr) {
+		if aerr.Response != nil {
+			return isTransientStatus(aerr.Response.StatusCode)
+		}
+		if aerr.Err != nil {
+			return isTransient(aerr.Err)
+		}
+		return false
+	}
+	var merr *metadataStatusError
+	if errors.As(err, &merr) {
+		return isTransientStatus(merr.statusCode)
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+func isTransientStatus(code int) bool {
+	return code >= http.StatusInternalServerError ||
+		code == http.StatusTooManyRequests ||
+		code == http.StatusRequestTimeout
+}
@@ -0,0 +1,249 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/mock/mock_config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+	"github.com/golang/mock/gomock"
+	"golang.org/x/oauth2"
+)
+
+// newFlakyServer returns a fake token endpoint or metadata server which fails
+// the first failures requests with the given status and body, then issues a
+// token, and a counter of its requests.
+func newFlakyServer(t *testing.T, failures, status int, body string) (*httptest.Server, *int) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Metadata-Flavor", "Google")
+		if requests <= failures {
+			w.WriteHeader(status)
+			io.WriteString(w, body)
+			return
+		}
+		io.WriteString(w, `{"access_token":"fresh token","token_type":"Bearer","expires_in":3600}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+// redirectTransport sends every request to a test server.
+type redirectTransport struct {
+	host string
+}
+
+func (rt redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = "http", rt.host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// retryCases are the token endpoint's failures, and how they are retried with
+// two retries.
+var retryCases = []struct {
+	name             string
+	failures         int
+	status           int
+	body             string
+	expectedRequests int
+	expectSuccess    bool
+}{
+	{"recovers", 2, http.StatusServiceUnavailable, `{"error":"backend_error"}`, 3, true},
+	{"throttled", 1, http.StatusTooManyRequests, `{"error":"rate_limit_exceeded"}`, 2, true},
+	{"exhausted", 5, http.StatusInternalServerError, `{"error":"internal_failure"}`, 3, false},
+	{"invalid grant", 1, http.StatusBadRequest, `{"error":"invalid_grant"}`, 1, false},
+}
+
+// setUpFlakyTokenServer points the OAuth2 token endpoint at a fake server
+// which fails the first failures requests with the given status and body, then
+// issues a token. It returns a store holding an expired access token, so that
+// using it requires a refresh, and a counter of token requests.
+func setUpFlakyTokenServer(t *testing.T, failures, status int, body string) (store.GCRCredStore, *int) {
+	srv, requests := newFlakyServer(t, failures, status, body)
+	orig := config.GCROAuth2Endpoint
+	config.GCROAuth2Endpoint = oauth2.Endpoint{AuthURL: srv.URL, TokenURL: srv.URL, AuthStyle: oauth2.AuthStyleInParams}
+	t.Cleanup(func() { config.GCROAuth2Endpoint = orig })
+
+	s := store.NewGCRCredStore(filepath.Join(t.TempDir(), "docker_credentials.json"))
+	tok := &oauth2.Token{AccessToken: "stale token", RefreshToken: "refreshplz", Expiry: time.Now().Add(-time.Hour)}
+	if err := s.SetGCRAuth(tok, "", config.DefaultOAuthClient); err != nil {
+		t.Fatalf("Unable to store credentials: %v", err)
+	}
+	return s, requests
+}
+
+func TestGetGCRAccessToken_RetriesTransientFailures(t *testing.T) {
+	for _, tc := range retryCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			s, requests := setUpFlakyTokenServer(t, tc.failures, tc.status, tc.body)
+			mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+			mockUserCfg.EXPECT().TokenSources().Return([]string{"store"})
+
+			tested := &gcrCredHelper{
+				store:          s,
+				userCfg:        mockUserCfg,
+				retry:          retryPolicy{retries: 2, backoff: time.Millisecond},
+				credStoreToken: tokenFromPrivateStore,
+			}
+
+			token, err := tested.getGCRAccessToken("gcr.io")
+
+			if tc.expectSuccess && (err != nil || token.value != "fresh token") {
+				t.Errorf("Expected the refreshed token, got: %+v, %v", token, err)
+			}
+			if !tc.expectSuccess && err == nil {
+				t.Errorf("Expected an error, got: %+v", token)
+			}
+			if *requests != tc.expectedRequests {
+				t.Errorf("Expected %d token requests, got: %d", tc.expectedRequests, *requests)
+			}
+		})
+	}
+}
+
+func TestGetGCRAccessToken_RetriesGcloudNative(t *testing.T) {
+	t.Setenv("CLOUDSDK_CONFIG", "../gcloud/testdata/valid")
+	for _, tc := range retryCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			srv, requests := newFlakyServer(t, tc.failures, tc.status, tc.body)
+			mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+			mockUserCfg.EXPECT().TokenSources().Return([]string{"gcloud-native"})
+			// gcloud's cached token for this account has expired.
+			mockUserCfg.EXPECT().GcloudSelection("gcr.io").Return(config.GcloudSelection{Account: "expired@example.com"})
+
+			client := &http.Client{Transport: redirectTransport{host: srv.Listener.Addr().String()}}
+			tested := &gcrCredHelper{
+				ctx:               context.WithValue(context.Background(), oauth2.HTTPClient, client),
+				userCfg:           mockUserCfg,
+				retry:             retryPolicy{retries: 2, backoff: time.Millisecond},
+				gcloudNativeToken: tokenFromGcloudNative,
+			}
+
+			token, err := tested.getGCRAccessToken("gcr.io")
+
+			if tc.expectSuccess && (err != nil || token.value != "fresh token") {
+				t.Errorf("Expected the refreshed token, got: %+v, %v", token, err)
+			}
+			if !tc.expectSuccess && err == nil {
+				t.Errorf("Expected an error, got: %+v", token)
+			}
+			if *requests != tc.expectedRequests {
+				t.Errorf("Expected %d token requests, got: %d", tc.expectedRequests, *requests)
+			}
+		})
+	}
+}
+
+func TestGetGCRAccessToken_RetriesMetadata(t *testing.T) {
+	for _, tc := range retryCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			srv, requests := newFlakyServer(t, tc.failures, tc.status, tc.body)
+			mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+			mockUserCfg.EXPECT().TokenSources().Return([]string{"metadata"})
+			mockUserCfg.EXPECT().MetadataHost().Return(srv.Listener.Addr().String())
+			mockUserCfg.EXPECT().MetadataServiceAccount().Return("default")
+			mockUserCfg.EXPECT().MetadataNegativeCacheTTL().Return(time.Hour)
+
+			tested := &gcrCredHelper{
+				store:   store.NewGCRCredStore(filepath.Join(t.TempDir(), "docker_credentials.json")),
+				userCfg: mockUserCfg,
+				retry:   retryPolicy{retries: 2, backoff: time.Millisecond},
+			}
+
+			token, err := tested.getGCRAccessToken("gcr.io")
+
+			if tc.expectSuccess && (err != nil || token.value != "fresh token") {
+				t.Errorf("Expected the token, got: %+v, %v", token, err)
+			}
+			if !tc.expectSuccess && err == nil {
+				t.Errorf("Expected an error, got: %+v", token)
+			}
+			if *requests != tc.expectedRequests {
+				t.Errorf("Expected %d token requests, got: %d", tc.expectedRequests, *requests)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_AbandonedWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	transient := fmt.Errorf("read: %w", syscall.ECONNRESET)
+
+	_, err := retryPolicy{retries: 5, backoff: time.Hour}.do(ctx, "env", func() (*accessToken, error) {
+		attempts++
+		cancel()
+		return nil, transient
+	})
+
+	if !errors.Is(err, syscall.ECONNRESET) || attempts != 1 {
+		t.Errorf("Expected the first attempt's error without waiting, got: %v after %d attempts", err, attempts)
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := retryPolicy{backoff: 100 * time.Millisecond}
+	for attempt, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		for i := 0; i < 20; i++ {
+			if d := p.delay(attempt); d < max/2 || d > max {
+				t.Errorf("Expected retry %d's delay in [%v, %v], got: %v", attempt, max/2, max, d)
+			}
+		}
+	}
+	if d := p.delay(100); d > maxRetryBackoff {
+		t.Errorf("Expected the delay to be capped at %v, got: %v", maxRetryBackoff, d)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		expected bool
+	}{
+		{&oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusBadGateway}}, true},
+		{helperErr("unable to read gcloud credentials", &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}}), true},
+		{&metadataStatusError{statusCode: http.StatusTooManyRequests, status: "429 Too Many Requests"}, true},
+		{helperErr("failed to obtain a token from the metadata server", &metadataStatusError{statusCode: http.StatusForbidden, status: "403 Forbidden"}), false},
+		{&oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusBadRequest}, ErrorCode: "invalid_grant"}, false},
+		{fmt.Errorf("Post: %w", syscall.ECONNRESET), true},
+		{io.ErrUnexpectedEOF, true},
+		{context.DeadlineExceeded, false},
+		{errors.New("could not find default credentials"), false},
+	} {
+		if got := isTransient(tc.err); got != tc.expected {
+			t.Errorf("isTransient(%v): expected %v, got: %v", tc.err, tc.expected, got)
+		}
+	}
+}
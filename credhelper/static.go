@@ -0,0 +1,90 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	gauth "cloud.google.com/go/auth"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+)
+
+// staticTokenFile is the JSON form of the access token file, as marshaled from
+// an oauth2.Token.
+type staticTokenFile struct {
+	AccessToken string    `json:"access_token"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+// tokenFromFile serves the pre-minted access token in the given file, which
+// holds either the token alone or a JSON object with its "access_token" and
+// optionally its "expiry". The file is read on each call, so that it may be
+// replaced as the token is renewed.
+func tokenFromFile(path string) (*accessToken, error) {
+	if path == "" {
+		return nil, helperErr("no AccessTokenFile is configured", nil)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, helperErr("unable to read the access token file", err)
+	}
+	data = []byte(strings.TrimSpace(string(data)))
+	if len(data) > 0 && data[0] == '{' {
+		var f staticTokenFile
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, helperErr("failed to parse the access token file "+path, err)
+		}
+		return staticToken(f.AccessToken, f.Expiry, path)
+	}
+	return staticToken(string(data), time.Time{}, path)
+}
+
+// tokenFromEnvVar serves the pre-minted access token in
+// config.AccessTokenEnvVar, which expires at config.AccessTokenExpiryEnvVar,
+// if set.
+func tokenFromEnvVar() (*accessToken, error) {
+	value, ok := os.LookupEnv(config.AccessTokenEnvVar)
+	if !ok {
+		return nil, helperErr(config.AccessTokenEnvVar+" is not set", nil)
+	}
+	var expiry time.Time
+	if v := strings.TrimSpace(os.Getenv(config.AccessTokenExpiryEnvVar)); v != "" {
+		var err error
+		if expiry, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, helperErr("invalid value for "+config.AccessTokenExpiryEnvVar, err)
+		}
+	}
+	return staticToken(value, expiry, config.AccessTokenEnvVar)
+}
+
+// staticToken validates a pre-minted access token from the given origin, which
+// expires at expiry, or never expires if expiry is the zero time.
+func staticToken(value string, expiry time.Time, origin string) (*accessToken, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, helperErr(fmt.Sprintf("the access token in %s is empty", origin), nil)
+	}
+	if strings.ContainsAny(value, " \t\r\n") {
+		return nil, helperErr(fmt.Sprintf("%s holds more than an access token", origin), nil)
+	}
+	if !expiry.IsZero() && !isValidToken(&gauth.Token{Value: value, Expiry: expiry}) {
+		return nil, helperErr(fmt.Sprintf("the access token in %s expired at %s", origin, expiry.Format(time.RFC3339)), nil)
+	}
+	return &accessToken{value: value, expiry: expiry}, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/mock/mock_config"
+	"github.com/golang/mock/gomock"
+)
+
+func TestTokenFromFile(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	past := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	tests := []struct {
+		name           string
+		contents       string
+		expectedToken  string
+		expectedExpiry time.Time
+		wantErr        string
+	}{
+		{"raw", "ya29.file\n", "ya29.file", time.Time{}, ""},
+		{"json", `{"access_token":"ya29.file","token_type":"Bearer","expiry":"` + future.Format(time.RFC3339) + `"}`, "ya29.file", future, ""},
+		{"json without expiry", `{"access_token":"ya29.file"}`, "ya29.file", time.Time{}, ""},
+		{"expired", `{"access_token":"ya29.file","expiry":"` + past.Format(time.RFC3339) + `"}`, "", time.Time{}, "expired at " + past.Format(time.RFC3339)},
+		{"empty", "", "", time.Time{}, "empty"},
+		{"not a token", "ya29.file\nya29.other", "", time.Time{}, "more than an access token"},
+		{"invalid json", `{"access_token":`, "", time.Time{}, "parse"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "token")
+			if err := os.WriteFile(path, []byte(test.contents), 0600); err != nil {
+				t.Fatalf("Unable to write the token file: %v", err)
+			}
+
+			token, err := tokenFromFile(path)
+
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("Expected an error containing %q, got: %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("tokenFromFile returned an error: %v", err)
+			}
+			if token.value != test.expectedToken || !token.expiry.Equal(test.expectedExpiry) {
+				t.Errorf("Expected token %s expiring at %v, got: %+v", test.expectedToken, test.expectedExpiry, token)
+			}
+		})
+	}
+}
+
+func TestTokenFromFile_Missing(t *testing.T) {
+	if _, err := tokenFromFile(filepath.Join(t.TempDir(), "token")); err == nil {
+		t.Error("Expected an error for a missing token file")
+	}
+	if _, err := tokenFromFile(""); err == nil {
+		t.Error("Expected an error when no token file is configured")
+	}
+}
+
+func TestTokenFromEnvVar(t *testing.T) {
+	t.Setenv(config.AccessTokenEnvVar, "ya29.env")
+	t.Setenv(config.AccessTokenExpiryEnvVar, "")
+
+	token, err := tokenFromEnvVar()
+	if err != nil {
+		t.Fatalf("tokenFromEnvVar returned an error: %v", err)
+	}
+	if token.value != "ya29.env" || !token.expiry.IsZero() {
+		t.Errorf("Expected the token in %s, got: %+v", config.AccessTokenEnvVar, token)
+	}
+
+	t.Setenv(config.AccessTokenExpiryEnvVar, "2020-01-02T15:04:05Z")
+	if _, err := tokenFromEnvVar(); err == nil || !strings.Contains(err.Error(), "expired at 2020-01-02T15:04:05Z") {
+		t.Errorf("Expected the token to have expired, got: %v", err)
+	}
+
+	t.Setenv(config.AccessTokenExpiryEnvVar, "tomorrow")
+	if _, err := tokenFromEnvVar(); err == nil {
+		t.Error("Expected an error for an invalid expiry")
+	}
+}
+
+func TestGetGCRAccessToken_FileSource(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	path := filepath.Join(t.TempDir(), "token")
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"file"}).Times(2)
+	mockUserCfg.EXPECT().AccessTokenFile().Return(path).Times(2)
+
+	tested := &gcrCredHelper{userCfg: mockUserCfg}
+
+	// The file is re-read on each request.
+	for _, value := range []string{"ya29.first", "ya29.second"} {
+		if err := os.WriteFile(path, []byte(value), 0600); err != nil {
+			t.Fatalf("Unable to write the token file: %v", err)
+		}
+		token, err := tested.getGCRAccessToken("gcr.io")
+		if err != nil {
+			t.Fatalf("getGCRAccessToken returned an error: %v", err)
+		}
+		if token.value != value || token.source != "file" {
+			t.Errorf("Expected %s from the file, got: %+v", value, token)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/auth"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+)
+
+// RegistryStatus describes the identity which would be used to authenticate
+// to a registry.
+type RegistryStatus struct {
+	Registry string `json:"registry"`
+	// TokenSource is the token source which issued the access token, or
+	// config.JSONKeyTokenSource for a service account key stored for the
+	// registry.
+	TokenSource string `json:"token_source,omitempty"`
+	// AutoTokenSources are the token sources chosen by the "auto" token
+	// source, if it's configured, and why.
+	AutoTokenSources []AutoTokenSource `json:"auto_token_sources,omitempty"`
+	// StoredCredential is the type of the stored credential which issued the
+	// access token, e.g. store.UserCredential, for the "store" token source.
+	StoredCredential string `json:"stored_credential,omitempty"`
+	// Principal is the account the access token was issued to, if known.
+	Principal string `json:"principal,omitempty"`
+	// Scopes are the OAuth2 scopes granted to the access token.
+	Scopes []string `json:"scopes,omitempty"`
+	// Expiry is when the access token expires, if known.
+	Expiry *time.Time `json:"expiry,omitempty"`
+	// RefreshError is set if the stored refresh token no longer works. It's
+	// only checked for the "store" token source.
+	RefreshError string `json:"refresh_error,omitempty"`
+	// Warning is set if the access token was issued from a credential which
+	// needs attention, e.g. a key due for rotation.
+	Warning string `json:"warning,omitempty"`
+	// Error is set if no access token could be obtained for the registry.
+	Error string `json:"error,omitempty"`
+}
+
+// Status resolves, for each of the given registries, the stored service account
+// key or token source which would be used by `get` and describes the access
+// token it issues. Unlike `get`, it never prompts the user to reauthenticate.
+func Status(ctx context.Context, store store.GCRCredStore, userCfg config.UserConfig, registries []string) []RegistryStatus {
+	return newGCRCredHelper(ctx, store, userCfg).status(ctx, registries)
+}
+
+// statusToken is the outcome of resolving the token sources for a registry.
+type statusToken struct {
+	tok *accessToken
+	err error
+}
+
+func (ch *gcrCredHelper) status(ctx context.Context, registries []string) []RegistryStatus {
+	// Tokens are usually shared between registries, only resolve and look up
+	// each once. Only the gcloud selection differs between registries.
+	tokens := map[config.GcloudSelection]statusToken{}
+	infos := map[string]*auth.TokenInfo{}
+	var refreshErr *error
+	ret := make([]RegistryStatus, 0, len(registries))
+	for _, registry := range registries {
+		st := RegistryStatus{Registry: registry}
+		if err := ch.userCfg.CheckRegistry(registry); err != nil {
+			st.Error = err.Error()
+			ret = append(ret, st)
+			continue
+		}
+		// As in `get`, a service account key stored for the registry comes
+		// first.
+		tok, ok, err := ch.registryKeyToken(registry)
+		if !ok {
+			sel := ch.userCfg.GcloudSelection(registry)
+			resolved, cached := tokens[sel]
+			if !cached {
+				resolved.tok, resolved.err = ch.getGCRAccessToken(registry)
+				tokens[sel] = resolved
+			}
+			tok, err = resolved.tok, resolved.err
+			st.AutoTokenSources = ch.auto
+		}
+		if err != nil {
+			st.Error = err.Error()
+			ret = append(ret, st)
+			continue
+		}
+		st.TokenSource = tok.source
+		st.Principal = tok.principal
+		st.Warning = tok.warning
+		if !tok.expiry.IsZero() {
+			expiry := tok.expiry
+			st.Expiry = &expiry
+		}
+
+		info, ok := infos[tok.value]
+		if !ok {
+			if info, err = ch.tokenInfo(ctx, tok.value); err != nil {
+				info = nil
+				st.Error = helperErr("unable to look up the access token", err).Error()
+			}
+			infos[tok.value] = info
+		}
+		if info != nil {
+			if info.Email != "" {
+				st.Principal = info.Email
+			}
+			st.Scopes = info.Scopes
+			if !info.Expiry.IsZero() {
+				st.Expiry = &info.Expiry
+			}
+		}
+
+		if tok.source == "store" {
+			st.StoredCredential = tok.credentialType
+		}
+		// Only users' credentials have a refresh token.
+		if tok.source == "store" && (tok.credentialType == "" || tok.credentialType == store.UserCredential) {
+			if refreshErr == nil {
+				err := ch.checkRefreshToken(ctx)
+				refreshErr = &err
+			}
+			if *refreshErr != nil {
+				st.RefreshError = (*refreshErr).Error()
+			}
+		}
+		ret = append(ret, st)
+	}
+	return ret
+}
+
+// checkRefreshToken returns an error if the stored refresh token can't be used
+// to obtain a new access token.
+func (ch *gcrCredHelper) checkRefreshToken(ctx context.Context) error {
+	gcrAuth, err := ch.store.GetGCRAuth()
+	if err != nil {
+		return err
+	}
+	_, err = gcrAuth.Refresh(ctx)
+	return err
+}
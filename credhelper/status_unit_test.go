@@ -0,0 +1,220 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/auth"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/mock/mock_config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/mock/mock_store"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/util/cmd"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/golang/mock/gomock"
+)
+
+func TestStatus(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStore := mock_store.NewMockGCRCredStore(mockCtrl)
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().CheckRegistry("gcr.io").Return(nil)
+	mockUserCfg.EXPECT().CheckRegistry("us.gcr.io").Return(nil)
+	mockUserCfg.EXPECT().CheckRegistry("evil.example.com").Return(errors.New("forbidden"))
+	mockUserCfg.EXPECT().GcloudSelection(gomock.Any()).Return(config.GcloudSelection{}).Times(2)
+	mockStore.EXPECT().GetRegistryKey(gomock.Any()).Return(nil, credentials.NewErrCredentialsNotFound()).Times(2)
+	// The token is only resolved, and the refresh token checked, once.
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"store"})
+	mockStore.EXPECT().GetGCRAuth().Return(nil, errors.New("refresh failed"))
+
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	lookups := 0
+	tested := &gcrCredHelper{
+		store:   mockStore,
+		userCfg: mockUserCfg,
+		credStoreToken: func(context.Context, []string, store.GCRCredStore) (*accessToken, error) {
+			return &accessToken{value: "t0k3n", principal: "stored@example.com"}, nil
+		},
+		tokenInfo: func(_ context.Context, tok string) (*auth.TokenInfo, error) {
+			lookups++
+			if tok != "t0k3n" {
+				t.Errorf("Expected the access token to be looked up, got: %q", tok)
+			}
+			return &auth.TokenInfo{Email: "user@example.com", Scopes: []string{"scope"}, Expiry: expiry}, nil
+		},
+	}
+
+	statuses := tested.status(context.Background(), []string{"gcr.io", "us.gcr.io", "evil.example.com"})
+
+	if len(statuses) != 3 {
+		t.Fatalf("Expected 3 statuses, got: %+v", statuses)
+	}
+	if lookups != 1 {
+		t.Errorf("Expected 1 tokeninfo lookup, got: %d", lookups)
+	}
+	for _, st := range statuses[:2] {
+		if st.TokenSource != "store" || st.Principal != "user@example.com" || !reflect.DeepEqual(st.Scopes, []string{"scope"}) ||
+			st.Expiry == nil || !st.Expiry.Equal(expiry) || st.Error != "" || st.RefreshError != "refresh failed" {
+			t.Errorf("Unexpected status: %+v", st)
+		}
+	}
+	if st := statuses[2]; st.Registry != "evil.example.com" || st.Error != "forbidden" || st.TokenSource != "" {
+		t.Errorf("Unexpected status: %+v", st)
+	}
+}
+
+func TestStatus_GcloudSelections(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	work := config.GcloudSelection{Account: "work@example.com"}
+	mockStore := mock_store.NewMockGCRCredStore(mockCtrl)
+	mockStore.EXPECT().GetRegistryKey(gomock.Any()).Return(nil, credentials.NewErrCredentialsNotFound()).Times(3)
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().CheckRegistry(gomock.Any()).Return(nil).Times(3)
+	mockUserCfg.EXPECT().GcloudSelection("gcr.io").Return(config.GcloudSelection{}).Times(2)
+	mockUserCfg.EXPECT().GcloudSelection("us-docker.pkg.dev").Return(work).Times(2)
+	mockUserCfg.EXPECT().GcloudSelection("europe-docker.pkg.dev").Return(work)
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"gcloud"}).Times(2)
+
+	var runs []config.GcloudSelection
+	tested := &gcrCredHelper{
+		store:   mockStore,
+		userCfg: mockUserCfg,
+		gcloudSDKToken: func(_ context.Context, _ cmd.Command, sel config.GcloudSelection) (*accessToken, error) {
+			runs = append(runs, sel)
+			return &accessToken{value: "t0k3n-" + sel.Account}, nil
+		},
+		tokenInfo: func(context.Context, string) (*auth.TokenInfo, error) {
+			return &auth.TokenInfo{}, nil
+		},
+	}
+
+	statuses := tested.status(context.Background(), []string{"gcr.io", "us-docker.pkg.dev", "europe-docker.pkg.dev"})
+
+	if expected := []config.GcloudSelection{{}, work}; !reflect.DeepEqual(runs, expected) {
+		t.Errorf("Expected gcloud to run once per selection, got: %+v", runs)
+	}
+	if len(statuses) != 3 || statuses[2].Error != "" || statuses[2].TokenSource != "gcloud" {
+		t.Errorf("Expected the last registry to reuse the token, got: %+v", statuses)
+	}
+}
+
+func TestStatus_TokenInfoFailure(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStore := mock_store.NewMockGCRCredStore(mockCtrl)
+	mockStore.EXPECT().GetRegistryKey(gomock.Any()).Return(nil, credentials.NewErrCredentialsNotFound())
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().CheckRegistry(gomock.Any()).Return(nil)
+	mockUserCfg.EXPECT().GcloudSelection(gomock.Any()).Return(config.GcloudSelection{})
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"env"})
+
+	expiry := time.Now().Add(time.Hour)
+	tested := &gcrCredHelper{
+		store:   mockStore,
+		userCfg: mockUserCfg,
+		envToken: func(context.Context, []string) (*accessToken, error) {
+			return &accessToken{value: "t0k3n", principal: "sa@example.com", expiry: expiry}, nil
+		},
+		tokenInfo: func(context.Context, string) (*auth.TokenInfo, error) {
+			return nil, errors.New("offline")
+		},
+	}
+
+	statuses := tested.status(context.Background(), []string{"gcr.io"})
+
+	st := statuses[0]
+	if st.TokenSource != "env" || st.Principal != "sa@example.com" || st.Expiry == nil || !st.Expiry.Equal(expiry) || st.Error == "" {
+		t.Errorf("Expected the token source's metadata and an error, got: %+v", st)
+	}
+}
+
+func TestStatus_ImportedCredential(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// The refresh token isn't checked, there's none.
+	mockStore := mock_store.NewMockGCRCredStore(mockCtrl)
+	mockStore.EXPECT().GetRegistryKey(gomock.Any()).Return(nil, credentials.NewErrCredentialsNotFound())
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().CheckRegistry(gomock.Any()).Return(nil)
+	mockUserCfg.EXPECT().GcloudSelection(gomock.Any()).Return(config.GcloudSelection{})
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"store"})
+
+	tested := &gcrCredHelper{
+		store:   mockStore,
+		userCfg: mockUserCfg,
+		credStoreToken: func(context.Context, []string, store.GCRCredStore) (*accessToken, error) {
+			return &accessToken{value: "t0k3n", principal: "sa@example.com", credentialType: store.ServiceAccountCredential}, nil
+		},
+		tokenInfo: func(context.Context, string) (*auth.TokenInfo, error) {
+			return &auth.TokenInfo{Email: "sa@example.com"}, nil
+		},
+	}
+
+	statuses := tested.status(context.Background(), []string{"gcr.io"})
+
+	st := statuses[0]
+	if st.StoredCredential != store.ServiceAccountCredential || st.RefreshError != "" || st.Error != "" {
+		t.Errorf("Expected the stored credential's type, got: %+v", st)
+	}
+}
+
+func TestStatus_JSONKey(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// The token sources aren't resolved for the registry with a stored key.
+	mockStore := mock_store.NewMockGCRCredStore(mockCtrl)
+	mockStore.EXPECT().GetRegistryKey("eu.gcr.io").Return([]byte("k3y"), nil)
+	mockStore.EXPECT().GetRegistryKey("gcr.io").Return(nil, credentials.NewErrCredentialsNotFound())
+	mockUserCfg := mock_config.NewMockUserConfig(mockCtrl)
+	mockUserCfg.EXPECT().CheckRegistry(gomock.Any()).Return(nil).Times(2)
+	mockUserCfg.EXPECT().AllowsTokenSource(config.JSONKeyTokenSource).Return(true)
+	mockUserCfg.EXPECT().GcloudSelection("gcr.io").Return(config.GcloudSelection{})
+	mockUserCfg.EXPECT().TokenSources().Return([]string{"env"})
+
+	tested := &gcrCredHelper{
+		store:   mockStore,
+		userCfg: mockUserCfg,
+		envToken: func(context.Context, []string) (*accessToken, error) {
+			return &accessToken{value: "t0k3n", principal: "env@example.com"}, nil
+		},
+		jsonKeyToken: func(context.Context, []string, []byte) (*accessToken, error) {
+			return &accessToken{value: "minted", principal: testServiceAccount}, nil
+		},
+		tokenInfo: func(context.Context, string) (*auth.TokenInfo, error) {
+			return &auth.TokenInfo{}, nil
+		},
+	}
+
+	statuses := tested.status(context.Background(), []string{"eu.gcr.io", "gcr.io"})
+
+	if st := statuses[0]; st.TokenSource != config.JSONKeyTokenSource || st.Principal != testServiceAccount || st.Error != "" {
+		t.Errorf("Expected the stored key to be reported, got: %+v", st)
+	}
+	if st := statuses[1]; st.TokenSource != "env" || st.Principal != "env@example.com" || st.Error != "" {
+		t.Errorf("Expected the token source to be reported, got: %+v", st)
+	}
+}
@@ -0,0 +1,352 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package gcloud reads credentials directly from the gcloud SDK's config
+directory, avoiding the cost of executing gcloud.
+
+The config directory is read-only to this package: gcloud's cached access
+tokens are reused while they're valid, but tokens obtained by refreshing
+gcloud's credentials aren't written back.
+*/
+package gcloud
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/util"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	configDirEnvVar    = "CLOUDSDK_CONFIG"
+	activeConfigEnvVar = "CLOUDSDK_ACTIVE_CONFIG_NAME"
+	accountEnvVar      = "CLOUDSDK_CORE_ACCOUNT"
+
+	credentialsDB  = "credentials.db"
+	accessTokensDB = "access_tokens.db"
+)
+
+// ErrUnsupported is wrapped by errors caused by a gcloud config directory
+// whose layout or file formats aren't understood. Callers may fall back to
+// executing gcloud.
+var ErrUnsupported = errors.New("unsupported gcloud config")
+
+// tokenURL returns the endpoint at which credentials with the given token_uri
+// are refreshed, made a variable for testing.
+var tokenURL = func(tokenURI string) string {
+	if tokenURI != "" {
+		return tokenURI
+	}
+	return google.Endpoint.TokenURL
+}
+
+// Config is a gcloud config directory.
+type Config struct {
+	Dir string
+}
+
+// DefaultConfig returns the gcloud config directory which gcloud itself would
+// use.
+func DefaultConfig() (*Config, error) {
+	if dir := os.Getenv(configDirEnvVar); strings.TrimSpace(dir) != "" {
+		return &Config{Dir: dir}, nil
+	}
+	dir, err := util.SdkConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	return &Config{Dir: dir}, nil
+}
+
+// ActiveConfiguration returns the name of the active named configuration.
+func (c *Config) ActiveConfiguration() string {
+	if name := strings.TrimSpace(os.Getenv(activeConfigEnvVar)); name != "" {
+		return name
+	}
+	if data, err := os.ReadFile(filepath.Join(c.Dir, "active_config")); err == nil {
+		if name := strings.TrimSpace(string(data)); name != "" {
+			return name
+		}
+	}
+	return "default"
+}
+
+// Account returns the account set in the given named configuration, or ""
+// if none is set.
+func (c *Config) Account(configuration string) (string, error) {
+	props, err := c.properties(configuration)
+	if err != nil {
+		return "", err
+	}
+	return props["core/account"], nil
+}
+
+// properties returns the properties set in the given named configuration,
+// keyed by "section/name".
+func (c *Config) properties(configuration string) (map[string]string, error) {
+	f, err := os.Open(filepath.Join(c.Dir, "configurations", "config_"+configuration))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("gcloud configuration %q does not exist", configuration)
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	props := map[string]string{}
+	var section string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+		default:
+			name, value, ok := strings.Cut(line, "=")
+			if !ok {
+				name, value, ok = strings.Cut(line, ":")
+			}
+			if !ok {
+				return nil, fmt.Errorf("%w: invalid line in configuration %q: %q", ErrUnsupported, configuration, line)
+			}
+			props[section+"/"+strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return props, scanner.Err()
+}
+
+// ResolveAccount returns the account gcloud would use if invoked with the
+// given --configuration and --account flags, either of which may be "".
+func (c *Config) ResolveAccount(configuration, account string) (string, error) {
+	if account != "" {
+		return account, nil
+	}
+	// As in gcloud, the environment takes precedence over the configuration.
+	if account := strings.TrimSpace(os.Getenv(accountEnvVar)); account != "" {
+		return account, nil
+	}
+	if configuration == "" {
+		configuration = c.ActiveConfiguration()
+	}
+	account, err := c.Account(configuration)
+	if err != nil {
+		return "", err
+	}
+	if account == "" {
+		return "", fmt.Errorf("no account is set in gcloud configuration %q", configuration)
+	}
+	return account, nil
+}
+
+// Token returns an access token for the given account, reusing gcloud's
+// cached access token if it's still valid, or else refreshing the account's
+// stored credentials. scopes are only used for service account keys.
+func (c *Config) Token(ctx context.Context, account string, scopes []string) (*oauth2.Token, error) {
+	if tok, err := c.cachedToken(account); err != nil {
+		return nil, err
+	} else if tok != nil {
+		return tok, nil
+	}
+
+	ts, err := c.tokenSource(ctx, account, scopes)
+	if err != nil {
+		return nil, err
+	}
+	return ts.Token()
+}
+
+// cachedToken returns gcloud's cached access token for the account, or nil if
+// there's none or it's about to expire.
+func (c *Config) cachedToken(account string) (*oauth2.Token, error) {
+	db, err := openSQLite(filepath.Join(c.Dir, accessTokensDB))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	rows, err := db.table("access_tokens")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrUnsupported, accessTokensDB, err)
+	}
+	for _, row := range rows {
+		if row["account_id"] != account {
+			continue
+		}
+		value, _ := row["access_token"].(string)
+		expiry, err := parseTimestamp(row["token_expiry"])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrUnsupported, accessTokensDB, err)
+		}
+		// Leave a margin for the token to be used.
+		if value == "" || expiry.Before(time.Now().Add(time.Minute)) {
+			return nil, nil
+		}
+		return &oauth2.Token{AccessToken: value, TokenType: "Bearer", Expiry: expiry}, nil
+	}
+	return nil, nil
+}
+
+// tokenSource returns a token source for the account's stored credentials.
+func (c *Config) tokenSource(ctx context.Context, account string, scopes []string) (oauth2.TokenSource, error) {
+	value, err := c.credential(account)
+	if err != nil {
+		return nil, err
+	}
+	return credentialsTokenSource(ctx, value, scopes)
+}
+
+// credentials returns the serialized credentials stored by gcloud, keyed by
+// account.
+func (c *Config) credentials() (map[string][]byte, error) {
+	db, err := openSQLite(filepath.Join(c.Dir, credentialsDB))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	rows, err := db.table("credentials")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrUnsupported, credentialsDB, err)
+	}
+	creds := make(map[string][]byte, len(rows))
+	for _, row := range rows {
+		account, _ := row["account_id"].(string)
+		switch v := row["value"].(type) {
+		case string:
+			creds[account] = []byte(v)
+		case []byte:
+			creds[account] = v
+		}
+	}
+	return creds, nil
+}
+
+// credential returns the serialized credential stored for the account.
+func (c *Config) credential(account string) ([]byte, error) {
+	creds, err := c.credentials()
+	if err != nil {
+		return nil, err
+	}
+	value, ok := creds[account]
+	if !ok {
+		return nil, fmt.Errorf("no gcloud credentials for account %s, run `gcloud auth login %s`", account, account)
+	}
+	return value, nil
+}
+
+// serializedCredential is the subset of a gcloud credential used by the
+// package.
+type serializedCredential struct {
+	Type         string `json:"type"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// UserCredential is a user account's credential stored by gcloud: a refresh
+// token, and the OAuth2 client which issued it.
+type UserCredential struct {
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+}
+
+// UserCredential returns the credential gcloud stores for the given user
+// account.
+func (c *Config) UserCredential(account string) (*UserCredential, error) {
+	value, err := c.credential(account)
+	if err != nil {
+		return nil, err
+	}
+	var cred serializedCredential
+	if err := json.Unmarshal(value, &cred); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse credentials: %v", ErrUnsupported, err)
+	}
+	if cred.Type != "authorized_user" {
+		return nil, fmt.Errorf("gcloud account %s isn't a user account, but %q", account, cred.Type)
+	}
+	if cred.RefreshToken == "" {
+		return nil, fmt.Errorf("gcloud has no refresh token for account %s, run `gcloud auth login %s`", account, account)
+	}
+	return &UserCredential{ClientID: cred.ClientID, ClientSecret: cred.ClientSecret, RefreshToken: cred.RefreshToken}, nil
+}
+
+// HasRefreshToken reports whether gcloud stores the given refresh token for
+// any account, i.e. whether revoking it would sign gcloud out.
+func (c *Config) HasRefreshToken(refreshToken string) (bool, error) {
+	creds, err := c.credentials()
+	if err != nil {
+		return false, err
+	}
+	for _, value := range creds {
+		var cred serializedCredential
+		if json.Unmarshal(value, &cred) == nil && cred.RefreshToken != "" && cred.RefreshToken == refreshToken {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// credentialsTokenSource returns a token source for a serialized gcloud
+// credential.
+func credentialsTokenSource(ctx context.Context, value []byte, scopes []string) (oauth2.TokenSource, error) {
+	var cred serializedCredential
+	if err := json.Unmarshal(value, &cred); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse credentials: %v", ErrUnsupported, err)
+	}
+	switch cred.Type {
+	case "authorized_user":
+		conf := &oauth2.Config{
+			ClientID:     cred.ClientID,
+			ClientSecret: cred.ClientSecret,
+			Endpoint:     oauth2.Endpoint{TokenURL: tokenURL(cred.TokenURI), AuthStyle: oauth2.AuthStyleInParams},
+		}
+		return conf.TokenSource(ctx, &oauth2.Token{RefreshToken: cred.RefreshToken}), nil
+	case "service_account":
+		conf, err := google.JWTConfigFromJSON(value, scopes...)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse service account key: %v", ErrUnsupported, err)
+		}
+		conf.TokenURL = tokenURL(conf.TokenURL)
+		return conf.TokenSource(ctx), nil
+	default:
+		return nil, fmt.Errorf("%w: credential type %q", ErrUnsupported, cred.Type)
+	}
+}
+
+// parseTimestamp parses a timestamp stored by Python's sqlite3 module, which
+// is naive UTC.
+func parseTimestamp(v interface{}) (time.Time, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid token_expiry: %v", v)
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05.999999", "2006-01-02T15:04:05.999999"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid token_expiry: %q", s)
+}
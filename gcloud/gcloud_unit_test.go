@@ -0,0 +1,238 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fixtures in testdata are generated by testdata/generate.py.
+const (
+	validDir  = "testdata/valid"
+	legacyDir = "testdata/legacy"
+)
+
+// setUpTokenServer points credential refreshes at a fake token endpoint,
+// which issues access tokens named after the refresh token. The returned
+// counter records the number of refreshes.
+func setUpTokenServer(t *testing.T) *int {
+	refreshes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes++
+		if r.PostFormValue("grant_type") != "refresh_token" {
+			t.Errorf("Unexpected grant_type: %q", r.PostFormValue("grant_type"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "fresh-" + r.PostFormValue("refresh_token"),
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+	t.Cleanup(srv.Close)
+	orig := tokenURL
+	tokenURL = func(string) string { return srv.URL }
+	t.Cleanup(func() { tokenURL = orig })
+	return &refreshes
+}
+
+func TestResolveAccount_Active(t *testing.T) {
+	t.Setenv(activeConfigEnvVar, "")
+	t.Setenv(accountEnvVar, "")
+	c := &Config{Dir: validDir}
+
+	if got := c.ActiveConfiguration(); got != "work" {
+		t.Errorf("Expected the active configuration to be \"work\", got: %q", got)
+	}
+	account, err := c.ResolveAccount("", "")
+	if err != nil || account != "user@example.com" {
+		t.Errorf("Expected account \"user@example.com\", got: %q, %v", account, err)
+	}
+
+	t.Setenv(activeConfigEnvVar, "default")
+	if account, err := c.ResolveAccount("", ""); err != nil || account != "default@example.com" {
+		t.Errorf("Expected $%s to select the configuration, got: %q, %v", activeConfigEnvVar, account, err)
+	}
+
+	t.Setenv(accountEnvVar, "override@example.com")
+	if account, err := c.ResolveAccount("", ""); err != nil || account != "override@example.com" {
+		t.Errorf("Expected $%s to override the account, got: %q, %v", accountEnvVar, account, err)
+	}
+}
+
+func TestResolveAccount(t *testing.T) {
+	t.Setenv(activeConfigEnvVar, "")
+	t.Setenv(accountEnvVar, "")
+	c := &Config{Dir: validDir}
+
+	for _, tc := range []struct {
+		configuration, account, expected string
+	}{
+		{"", "", "user@example.com"},
+		{"default", "", "default@example.com"},
+		{"default", "explicit@example.com", "explicit@example.com"},
+		{"", "explicit@example.com", "explicit@example.com"},
+	} {
+		account, err := c.ResolveAccount(tc.configuration, tc.account)
+		if err != nil || account != tc.expected {
+			t.Errorf("ResolveAccount(%q, %q): expected %q, got: %q, %v", tc.configuration, tc.account, tc.expected, account, err)
+		}
+	}
+
+	if account, err := c.ResolveAccount("missing", ""); err == nil {
+		t.Errorf("Expected an error for a missing configuration, got: %q", account)
+	}
+}
+
+func TestResolveAccount_NoAccount(t *testing.T) {
+	t.Setenv(activeConfigEnvVar, "noaccount")
+	t.Setenv(accountEnvVar, "")
+
+	if account, err := (&Config{Dir: validDir}).ResolveAccount("", ""); err == nil {
+		t.Fatalf("Expected an error, got: %q", account)
+	}
+}
+
+func TestToken_Cached(t *testing.T) {
+	refreshes := setUpTokenServer(t)
+
+	tok, err := (&Config{Dir: validDir}).Token(context.Background(), "user@example.com", nil)
+
+	if err != nil {
+		t.Fatalf("Token returned an error: %v", err)
+	}
+	if tok.AccessToken != "cached-access-token" {
+		t.Errorf("Expected the cached access token, got: %q", tok.AccessToken)
+	}
+	if expected := time.Date(2099, 1, 2, 3, 4, 5, 678901000, time.UTC); !tok.Expiry.Equal(expected) {
+		t.Errorf("Expected expiry %v, got: %v", expected, tok.Expiry)
+	}
+	if *refreshes != 0 {
+		t.Errorf("Expected no refreshes, got: %d", *refreshes)
+	}
+}
+
+func TestToken_Refresh(t *testing.T) {
+	for name, tc := range map[string]struct {
+		account, expected string
+	}{
+		"expired cached token": {"expired@example.com", "fresh-expired-refresh-token"},
+		"no cached token":      {"default@example.com", "fresh-default-refresh-token"},
+		"overflow pages":       {"big@example.com", "fresh-big-refresh-token"},
+		"later leaf page":      {"filler099@example.com", "fresh-filler-099"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			refreshes := setUpTokenServer(t)
+
+			tok, err := (&Config{Dir: validDir}).Token(context.Background(), tc.account, nil)
+
+			if err != nil {
+				t.Fatalf("Token returned an error: %v", err)
+			}
+			if tok.AccessToken != tc.expected {
+				t.Errorf("Expected access token %q, got: %q", tc.expected, tok.AccessToken)
+			}
+			if *refreshes != 1 {
+				t.Errorf("Expected 1 refresh, got: %d", *refreshes)
+			}
+		})
+	}
+}
+
+func TestToken_NoCredentials(t *testing.T) {
+	setUpTokenServer(t)
+
+	_, err := (&Config{Dir: validDir}).Token(context.Background(), "nobody@example.com", nil)
+
+	if err == nil || errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Expected a missing credentials error, got: %v", err)
+	}
+}
+
+func TestToken_Unsupported(t *testing.T) {
+	setUpTokenServer(t)
+	corrupt := t.TempDir()
+	if err := os.WriteFile(filepath.Join(corrupt, credentialsDB), []byte("not a database"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		dir, account string
+	}{
+		"unknown credential type": {validDir, "external@example.com"},
+		"no credentials.db":       {legacyDir, "user@example.com"},
+		"corrupt credentials.db":  {corrupt, "user@example.com"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := (&Config{Dir: tc.dir}).Token(context.Background(), tc.account, nil)
+			if !errors.Is(err, ErrUnsupported) {
+				t.Fatalf("Expected ErrUnsupported, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	got := parseColumns(`CREATE TABLE "access_tokens" (account_id TEXT PRIMARY KEY, access_token TEXT, token_expiry TIMESTAMP, rapt_token TEXT, id_token TEXT, CHECK (length(account_id) > 0))`)
+	expected := []string{"account_id", "access_token", "token_expiry", "rapt_token", "id_token"}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected columns %v, got: %v", expected, got)
+	}
+}
+
+func TestUserCredential(t *testing.T) {
+	cfg := &Config{Dir: validDir}
+
+	cred, err := cfg.UserCredential("user@example.com")
+
+	if err != nil {
+		t.Fatalf("UserCredential returned an error: %v", err)
+	}
+	if cred.RefreshToken != "user-refresh-token" || cred.ClientID != "32555940559.apps.googleusercontent.com" || cred.ClientSecret == "" {
+		t.Errorf("Expected the user's refresh token and gcloud's client, got: %+v", cred)
+	}
+	if _, err := cfg.UserCredential("external@example.com"); err == nil {
+		t.Error("Expected an error for an external account")
+	}
+	if _, err := cfg.UserCredential("nobody@example.com"); err == nil {
+		t.Error("Expected an error for an unknown account")
+	}
+	if _, err := (&Config{Dir: legacyDir}).UserCredential("user@example.com"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got: %v", err)
+	}
+}
+
+func TestHasRefreshToken(t *testing.T) {
+	cfg := &Config{Dir: validDir}
+
+	for token, expected := range map[string]bool{
+		"default-refresh-token": true,
+		"someone-elses-token":   false,
+		"":                      false,
+	} {
+		if shared, err := cfg.HasRefreshToken(token); err != nil || shared != expected {
+			t.Errorf("HasRefreshToken(%q): expected %v, got: %v, %v", token, expected, shared, err)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+/*
+sqliteDB is a minimal, read-only reader of the SQLite database file format,
+sufficient to read the small tables in which gcloud caches credentials. It
+only supports scanning whole tables of UTF-8 databases which aren't in WAL
+mode. See https://www.sqlite.org/fileformat.html.
+*/
+type sqliteDB struct {
+	data     []byte
+	pageSize int
+	// usable is the number of usable bytes in each page.
+	usable int
+}
+
+const sqliteMagic = "SQLite format 3\x00"
+
+// openSQLite reads the database at the given path.
+func openSQLite(path string) (*sqliteDB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stat(path + "-wal"); err == nil && fi.Size() > 0 {
+		return nil, fmt.Errorf("%s has uncheckpointed WAL changes", path)
+	}
+	return parseSQLite(path, data)
+}
+
+// parseSQLite validates the header of the database at the given path, whose
+// contents are data.
+func parseSQLite(path string, data []byte) (*sqliteDB, error) {
+	if len(data) < 100 || string(data[:16]) != sqliteMagic {
+		return nil, fmt.Errorf("%s is not a SQLite database", path)
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("%s has an invalid page size: %d", path, pageSize)
+	}
+	if enc := binary.BigEndian.Uint32(data[56:60]); enc != 0 && enc != 1 {
+		return nil, fmt.Errorf("%s has unsupported text encoding %d", path, enc)
+	}
+	// SQLite itself requires at least 480 usable bytes per page.
+	usable := pageSize - int(data[20])
+	if usable < 480 {
+		return nil, fmt.Errorf("%s has an invalid number of reserved bytes: %d", path, data[20])
+	}
+	return &sqliteDB{data: data, pageSize: pageSize, usable: usable}, nil
+}
+
+// page returns the contents of the given 1-indexed page.
+func (db *sqliteDB) page(n uint32) ([]byte, error) {
+	start := int64(n-1) * int64(db.pageSize)
+	if n == 0 || start+int64(db.pageSize) > int64(len(db.data)) {
+		return nil, fmt.Errorf("page %d out of range", n)
+	}
+	return db.data[start : start+int64(db.pageSize)], nil
+}
+
+// table returns every row of the named table, keyed by column name. Values are
+// nil, int64, float64, string or []byte.
+func (db *sqliteDB) table(name string) ([]map[string]interface{}, error) {
+	var rootPage uint32
+	var columns []string
+	err := db.scan(1, func(_ int64, values []interface{}) error {
+		if len(values) < 5 || values[0] != "table" || !strings.EqualFold(fmt.Sprint(values[1]), name) {
+			return nil
+		}
+		root, ok := values[3].(int64)
+		if !ok {
+			return fmt.Errorf("invalid root page for table %s", name)
+		}
+		rootPage = uint32(root)
+		columns = parseColumns(fmt.Sprint(values[4]))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rootPage == 0 {
+		return nil, fmt.Errorf("no such table: %s", name)
+	}
+
+	var rows []map[string]interface{}
+	err = db.scan(rootPage, func(_ int64, values []interface{}) error {
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			// Columns added by ALTER TABLE may be missing from older rows.
+			if i < len(values) {
+				row[column] = values[i]
+			}
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, err
+}
+
+// scan calls fn with the rowid and values of every record in the table b-tree
+// rooted at the given page.
+func (db *sqliteDB) scan(pageNum uint32, fn func(rowid int64, values []interface{}) error) error {
+	return db.scanDepth(pageNum, fn, 0, map[uint32]bool{})
+}
+
+// scanDepth is scan for a page at the given depth of the b-tree. Pages already
+// visited are refused, so that corrupt, cyclic b-trees can't be scanned
+// forever.
+func (db *sqliteDB) scanDepth(pageNum uint32, fn func(rowid int64, values []interface{}) error, depth int, visited map[uint32]bool) error {
+	if depth > 64 {
+		return fmt.Errorf("b-tree too deep at page %d", pageNum)
+	}
+	if visited[pageNum] {
+		return fmt.Errorf("b-tree page %d is referenced more than once", pageNum)
+	}
+	visited[pageNum] = true
+	page, err := db.page(pageNum)
+	if err != nil {
+		return err
+	}
+	hdr := page
+	if pageNum == 1 {
+		hdr = page[100:]
+	}
+	numCells := int(binary.BigEndian.Uint16(hdr[3:5]))
+	switch hdr[0] {
+	case 0x0d: // table leaf
+		for i := 0; i < numCells; i++ {
+			cell, err := cellAt(page, hdr, 8, i)
+			if err != nil {
+				return err
+			}
+			payloadLen, n := readVarint(cell)
+			rowid, m := readVarint(cell[n:])
+			if n == 0 || m == 0 {
+				return fmt.Errorf("invalid cell on page %d", pageNum)
+			}
+			payload, err := db.payload(cell[n+m:], int(payloadLen))
+			if err != nil {
+				return err
+			}
+			values, err := decodeRecord(payload)
+			if err != nil {
+				return fmt.Errorf("invalid record on page %d: %v", pageNum, err)
+			}
+			if err := fn(int64(rowid), values); err != nil {
+				return err
+			}
+		}
+		return nil
+	case 0x05: // table interior
+		for i := 0; i < numCells; i++ {
+			cell, err := cellAt(page, hdr, 12, i)
+			if err != nil {
+				return err
+			}
+			if len(cell) < 4 {
+				return fmt.Errorf("invalid cell on page %d", pageNum)
+			}
+			if err := db.scanDepth(binary.BigEndian.Uint32(cell), fn, depth+1, visited); err != nil {
+				return err
+			}
+		}
+		return db.scanDepth(binary.BigEndian.Uint32(hdr[8:12]), fn, depth+1, visited)
+	default:
+		return fmt.Errorf("page %d is not a table b-tree page", pageNum)
+	}
+}
+
+// cellAt returns the content of the i'th cell of a b-tree page, whose header
+// (of size hdrSize) starts at hdr.
+func cellAt(page, hdr []byte, hdrSize, i int) ([]byte, error) {
+	ptr := hdrSize + 2*i
+	if ptr+2 > len(hdr) {
+		return nil, fmt.Errorf("cell pointer %d out of range", i)
+	}
+	off := int(binary.BigEndian.Uint16(hdr[ptr : ptr+2]))
+	if off >= len(page) {
+		return nil, fmt.Errorf("cell %d out of range", i)
+	}
+	return page[off:], nil
+}
+
+// payload returns the complete payload of a table leaf cell, following any
+// overflow pages. The payload can't be larger than the database, and each of
+// its overflow pages is only read once, so a corrupt database's cyclic chain
+// is refused after at most as many pages as it has.
+func (db *sqliteDB) payload(local []byte, size int) ([]byte, error) {
+	if size < 0 || size > len(db.data) {
+		return nil, fmt.Errorf("invalid payload size: %d", size)
+	}
+	u := db.usable
+	maxLocal := u - 35
+	if size <= maxLocal {
+		if size > len(local) {
+			return nil, fmt.Errorf("payload out of range")
+		}
+		return local[:size], nil
+	}
+	minLocal := (u-12)*32/255 - 23
+	k := minLocal + (size-minLocal)%(u-4)
+	if k > maxLocal {
+		k = minLocal
+	}
+	if k+4 > len(local) {
+		return nil, fmt.Errorf("payload out of range")
+	}
+	ret := make([]byte, 0, size)
+	ret = append(ret, local[:k]...)
+	next := binary.BigEndian.Uint32(local[k : k+4])
+	visited := map[uint32]bool{}
+	for len(ret) < size {
+		if next == 0 {
+			return nil, fmt.Errorf("overflow chain ends early")
+		}
+		if visited[next] {
+			return nil, fmt.Errorf("overflow page %d is referenced more than once", next)
+		}
+		visited[next] = true
+		page, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		next = binary.BigEndian.Uint32(page[:4])
+		n := size - len(ret)
+		if n > u-4 {
+			n = u - 4
+		}
+		ret = append(ret, page[4:4+n]...)
+	}
+	return ret, nil
+}
+
+// decodeRecord decodes a record in the SQLite record format.
+func decodeRecord(rec []byte) ([]interface{}, error) {
+	hdrLen, n := readVarint(rec)
+	if n == 0 || int(hdrLen) > len(rec) || int(hdrLen) < n {
+		return nil, fmt.Errorf("invalid record header")
+	}
+	hdr, body := rec[n:hdrLen], rec[hdrLen:]
+	var values []interface{}
+	for len(hdr) > 0 {
+		serialType, n := readVarint(hdr)
+		if n == 0 {
+			return nil, fmt.Errorf("invalid serial type")
+		}
+		hdr = hdr[n:]
+
+		var size int
+		switch {
+		case serialType <= 4:
+			size = int(serialType)
+		case serialType == 5:
+			size = 6
+		case serialType == 6 || serialType == 7:
+			size = 8
+		case serialType == 8 || serialType == 9:
+			size = 0
+		case serialType >= 12:
+			size = int((serialType - 12) / 2)
+		default:
+			return nil, fmt.Errorf("reserved serial type %d", serialType)
+		}
+		if size < 0 || size > len(body) {
+			return nil, fmt.Errorf("value out of range")
+		}
+		v := body[:size]
+		body = body[size:]
+
+		switch {
+		case serialType == 0:
+			values = append(values, nil)
+		case serialType <= 6:
+			// Big-endian two's complement integers.
+			var i int64
+			if len(v) > 0 && v[0]&0x80 != 0 {
+				i = -1
+			}
+			for _, b := range v {
+				i = i<<8 | int64(b)
+			}
+			values = append(values, i)
+		case serialType == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(v)))
+		case serialType == 8:
+			values = append(values, int64(0))
+		case serialType == 9:
+			values = append(values, int64(1))
+		case serialType%2 == 0:
+			values = append(values, bytes.Clone(v))
+		default:
+			values = append(values, string(v))
+		}
+	}
+	return values, nil
+}
+
+// readVarint reads a SQLite variable-length integer, returning it and the
+// number of bytes read, or 0 if b is too short.
+func readVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(b) {
+			return 0, 0
+		}
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, 9
+}
+
+// parseColumns returns the column names from a CREATE TABLE statement.
+func parseColumns(sql string) []string {
+	start, end := strings.Index(sql, "("), strings.LastIndex(sql, ")")
+	if start < 0 || end <= start {
+		return nil
+	}
+	var columns []string
+	depth, from := 0, start+1
+	var defs []string
+	for i := start + 1; i < end; i++ {
+		switch sql[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				defs = append(defs, sql[from:i])
+				from = i + 1
+			}
+		}
+	}
+	defs = append(defs, sql[from:end])
+	for _, def := range defs {
+		fields := strings.Fields(def)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			// A table constraint, not a column.
+			continue
+		}
+		columns = append(columns, strings.Trim(fields[0], "\"`[]"))
+	}
+	return columns
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testPageSize = 512
+
+// putVarint encodes v, which must be less than 2^56, as a SQLite varint.
+func putVarint(v uint64) []byte {
+	ret := []byte{byte(v & 0x7f)}
+	for v >>= 7; v != 0; v >>= 7 {
+		ret = append([]byte{byte(v&0x7f) | 0x80}, ret...)
+	}
+	return ret
+}
+
+// corruptDB returns a database whose schema table holds the single given
+// cell, followed by the given overflow pages.
+func corruptDB(cell []byte, overflow ...[]byte) []byte {
+	page := make([]byte, testPageSize)
+	copy(page, sqliteMagic)
+	binary.BigEndian.PutUint16(page[16:18], testPageSize)
+	binary.BigEndian.PutUint32(page[56:60], 1)
+	// A table leaf page, with one cell.
+	page[100] = 0x0d
+	binary.BigEndian.PutUint16(page[103:105], 1)
+	binary.BigEndian.PutUint16(page[108:110], 200)
+	copy(page[200:], cell)
+	for _, o := range overflow {
+		page = append(page, make([]byte, testPageSize)...)
+		copy(page[len(page)-testPageSize:], o)
+	}
+	return page
+}
+
+func TestSQLite_Corrupt(t *testing.T) {
+	tests := map[string][]byte{
+		"negative payload size": corruptDB(append(bytes.Repeat([]byte{0xff}, 9), 1)),
+		"huge payload size":     corruptDB(append(putVarint(1<<40), 1)),
+		// The payload spills onto page 2, which points back at itself.
+		"cyclic overflow chain": corruptDB(
+			append(append(append(putVarint(1000), 1), make([]byte, 39)...), 0, 0, 0, 2),
+			[]byte{0, 0, 0, 2}),
+		// The record's only value is a 2^55 byte blob.
+		"huge value": corruptDB(append(append(putVarint(10), 1), append([]byte{9}, putVarint(1<<56-1)...)...)),
+		"reserved bytes": func() []byte {
+			db := corruptDB(nil)
+			db[20] = 255
+			return db
+		}(),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			db, err := parseSQLite(name, data)
+			if err != nil {
+				return
+			}
+			if _, err := db.table("credentials"); err == nil {
+				t.Error("Expected the corrupt database to be refused")
+			}
+		})
+	}
+}
+
+func FuzzSQLite(f *testing.F) {
+	for _, name := range []string{"credentials.db", "access_tokens.db"} {
+		data, err := os.ReadFile(filepath.Join(validDir, name))
+		if err != nil {
+			f.Fatalf("Unable to read %s: %v", name, err)
+		}
+		f.Add(data)
+	}
+	f.Add(corruptDB(append(bytes.Repeat([]byte{0xff}, 9), 1)))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		db, err := parseSQLite("fuzz", data)
+		if err != nil {
+			return
+		}
+		// Only panics and hangs are failures.
+		db.table("credentials")
+		db.table("access_tokens")
+	})
+}
@@ -0,0 +1,103 @@
+#!/usr/bin/env python3
+# Copyright 2026 Google, Inc.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+"""Generates the gcloud config directory fixtures, using the same schemas as
+gcloud's credential store and access token cache."""
+
+import json
+import os
+import shutil
+import sqlite3
+
+HERE = os.path.dirname(os.path.abspath(__file__))
+
+
+def user_cred(refresh_token, **extra):
+    cred = {
+        "client_id": "32555940559.apps.googleusercontent.com",
+        "client_secret": "ZmssLNjJy2998hD4CTg2ejr2",
+        "refresh_token": refresh_token,
+        "revoke_uri": "https://oauth2.googleapis.com/revoke",
+        "scopes": ["openid", "https://www.googleapis.com/auth/cloud-platform"],
+        "token_uri": "https://oauth2.googleapis.com/token",
+        "type": "authorized_user",
+    }
+    cred.update(extra)
+    return json.dumps(cred)
+
+
+def write(path, contents):
+    os.makedirs(os.path.dirname(path), exist_ok=True)
+    with open(path, "w") as f:
+        f.write(contents)
+
+
+def valid():
+    d = os.path.join(HERE, "valid")
+    shutil.rmtree(d, ignore_errors=True)
+    write(os.path.join(d, "active_config"), "work")
+    write(os.path.join(d, "configurations", "config_default"),
+          "[core]\naccount = default@example.com\n")
+    write(os.path.join(d, "configurations", "config_work"),
+          "[core]\naccount = user@example.com\nproject = work-project\n\n"
+          "[compute]\nregion = us-central1\n")
+    write(os.path.join(d, "configurations", "config_noaccount"),
+          "[core]\nproject = other-project\n")
+
+    creds = sqlite3.connect(os.path.join(d, "credentials.db"))
+    creds.execute('CREATE TABLE IF NOT EXISTS "credentials" '
+                  '(account_id TEXT PRIMARY KEY, value BLOB)')
+    rows = [
+        ("user@example.com", user_cred("user-refresh-token")),
+        ("default@example.com", user_cred("default-refresh-token")),
+        ("expired@example.com", user_cred("expired-refresh-token")),
+        # Large enough to spill onto overflow pages.
+        ("big@example.com", user_cred("big-refresh-token", padding="x" * 10000)),
+        ("external@example.com", json.dumps({"type": "external_account"})),
+    ]
+    # Enough rows to need an interior b-tree page.
+    rows += [("filler%03d@example.com" % i, user_cred("filler-%03d" % i))
+             for i in range(100)]
+    creds.executemany('REPLACE INTO "credentials" (account_id, value) VALUES (?,?)', rows)
+    creds.commit()
+    creds.close()
+
+    tokens = sqlite3.connect(os.path.join(d, "access_tokens.db"))
+    tokens.execute('CREATE TABLE IF NOT EXISTS "access_tokens" '
+                   '(account_id TEXT PRIMARY KEY, access_token TEXT, '
+                   'token_expiry TIMESTAMP, rapt_token TEXT, id_token TEXT)')
+    tokens.executemany(
+        'REPLACE INTO "access_tokens" (account_id, access_token, token_expiry, '
+        'rapt_token, id_token) VALUES (?,?,?,?,?)', [
+            ("user@example.com", "cached-access-token", "2099-01-02 03:04:05.678901", None, None),
+            ("expired@example.com", "expired-access-token", "2001-01-02 03:04:05", None, None),
+        ])
+    tokens.commit()
+    tokens.close()
+
+
+def legacy():
+    d = os.path.join(HERE, "legacy")
+    shutil.rmtree(d, ignore_errors=True)
+    write(os.path.join(d, "active_config"), "default")
+    write(os.path.join(d, "configurations", "config_default"),
+          "[core]\naccount = user@example.com\n")
+    write(os.path.join(d, "legacy_credentials", "user@example.com", "adc.json"),
+          user_cred("user-refresh-token"))
+
+
+if __name__ == "__main__":
+    valid()
+    legacy()
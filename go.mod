@@ -13,6 +13,7 @@ require (
 	github.com/toqueteos/webbrowser v1.2.0
 	golang.org/x/oauth2 v0.27.0
 	golang.org/x/sync v0.14.0
+	golang.org/x/sys v0.33.0
 )
 
 require (
@@ -22,6 +23,5 @@ require (
 	github.com/googleapis/gax-go/v2 v2.14.2 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/sirupsen/logrus v1.9.4 // indirect
-	golang.org/x/sys v0.33.0 // indirect
 	gotest.tools/v3 v3.0.3 // indirect
 )
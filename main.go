@@ -23,9 +23,15 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/cli"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/util/logging"
 	"github.com/google/subcommands"
 )
 
@@ -44,12 +50,28 @@ func main() {
 	subcommands.Register(cli.NewListSubcommand(), dockerCredStoreGroup)
 	subcommands.Register(cli.NewGCRLoginSubcommand(), gcrGroup)
 	subcommands.Register(cli.NewGCRLogoutSubcommand(), gcrGroup)
+	subcommands.Register(cli.NewStatusSubcommand(), gcrGroup)
+	subcommands.Register(subcommands.Alias("whoami", cli.NewStatusSubcommand()), gcrGroup)
 	subcommands.Register(cli.NewDockerConfigSubcommand(), configGroup)
 	subcommands.Register(cli.NewConfigSubcommand(), configGroup)
+	subcommands.Register(cli.NewAuditSubcommand(), configGroup)
 	subcommands.Register(cli.NewVersionSubcommand(), "")
 	subcommands.Register(cli.NewClearSubcommand(), "")
 
+	config.RegisterFlags(flag.CommandLine)
 	flag.Parse()
-	ctx := context.Background()
-	os.Exit(int(subcommands.Execute(ctx)))
+
+	closeLog, err := logging.Init()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failure: %v\n", err)
+	}
+	slog.Debug("executing subcommand", "subcommand", flag.Arg(0), "version", config.Version)
+
+	// Abandon outstanding requests, e.g. a hung gcloud, if interrupted.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	status := subcommands.Execute(ctx)
+	stop()
+	slog.Debug("subcommand finished", "subcommand", flag.Arg(0), "status", int(status))
+	closeLog()
+	os.Exit(int(status))
 }
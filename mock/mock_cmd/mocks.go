@@ -5,6 +5,7 @@
 package mock_cmd
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
 )
@@ -33,9 +34,9 @@ func (m *MockCommand) EXPECT() *MockCommandMockRecorder {
 }
 
 // Exec mocks base method
-func (m *MockCommand) Exec(arg0 ...string) ([]byte, error) {
-	varargs := []interface{}{}
-	for _, a := range arg0 {
+func (m *MockCommand) Exec(arg0 context.Context, arg1 ...string) ([]byte, error) {
+	varargs := []interface{}{arg0}
+	for _, a := range arg1 {
 		varargs = append(varargs, a)
 	}
 	ret := m.ctrl.Call(m, "Exec", varargs...)
@@ -45,6 +46,7 @@ func (m *MockCommand) Exec(arg0 ...string) ([]byte, error) {
 }
 
 // Exec indicates an expected call of Exec
-func (mr *MockCommandMockRecorder) Exec(arg0 ...interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exec", reflect.TypeOf((*MockCommand)(nil).Exec), arg0...)
+func (mr *MockCommandMockRecorder) Exec(arg0 interface{}, arg1 ...interface{}) *gomock.Call {
+	varargs := append([]interface{}{arg0}, arg1...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exec", reflect.TypeOf((*MockCommand)(nil).Exec), varargs...)
 }
@@ -5,8 +5,10 @@
 package mock_config
 
 import (
+	config "github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
+	time "time"
 )
 
 // MockUserConfig is a mock of UserConfig interface
@@ -32,6 +34,90 @@ func (m *MockUserConfig) EXPECT() *MockUserConfigMockRecorder {
 	return m.recorder
 }
 
+// AccessTokenFile mocks base method
+func (m *MockUserConfig) AccessTokenFile() string {
+	ret := m.ctrl.Call(m, "AccessTokenFile")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// AccessTokenFile indicates an expected call of AccessTokenFile
+func (mr *MockUserConfigMockRecorder) AccessTokenFile() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccessTokenFile", reflect.TypeOf((*MockUserConfig)(nil).AccessTokenFile))
+}
+
+// AllowsTokenSource mocks base method
+func (m *MockUserConfig) AllowsTokenSource(arg0 string) bool {
+	ret := m.ctrl.Call(m, "AllowsTokenSource", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// AllowsTokenSource indicates an expected call of AllowsTokenSource
+func (mr *MockUserConfigMockRecorder) AllowsTokenSource(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllowsTokenSource", reflect.TypeOf((*MockUserConfig)(nil).AllowsTokenSource), arg0)
+}
+
+// AuditLog mocks base method
+func (m *MockUserConfig) AuditLog() string {
+	ret := m.ctrl.Call(m, "AuditLog")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// AuditLog indicates an expected call of AuditLog
+func (mr *MockUserConfigMockRecorder) AuditLog() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuditLog", reflect.TypeOf((*MockUserConfig)(nil).AuditLog))
+}
+
+// AuditLogMaxSize mocks base method
+func (m *MockUserConfig) AuditLogMaxSize() int64 {
+	ret := m.ctrl.Call(m, "AuditLogMaxSize")
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+// AuditLogMaxSize indicates an expected call of AuditLogMaxSize
+func (mr *MockUserConfigMockRecorder) AuditLogMaxSize() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuditLogMaxSize", reflect.TypeOf((*MockUserConfig)(nil).AuditLogMaxSize))
+}
+
+// CheckRegistry mocks base method
+func (m *MockUserConfig) CheckRegistry(arg0 string) error {
+	ret := m.ctrl.Call(m, "CheckRegistry", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckRegistry indicates an expected call of CheckRegistry
+func (mr *MockUserConfigMockRecorder) CheckRegistry(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckRegistry", reflect.TypeOf((*MockUserConfig)(nil).CheckRegistry), arg0)
+}
+
+// CredsStore mocks base method
+func (m *MockUserConfig) CredsStore() bool {
+	ret := m.ctrl.Call(m, "CredsStore")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// CredsStore indicates an expected call of CredsStore
+func (mr *MockUserConfigMockRecorder) CredsStore() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CredsStore", reflect.TypeOf((*MockUserConfig)(nil).CredsStore))
+}
+
+// CredsStoreDelegate mocks base method
+func (m *MockUserConfig) CredsStoreDelegate() string {
+	ret := m.ctrl.Call(m, "CredsStoreDelegate")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// CredsStoreDelegate indicates an expected call of CredsStoreDelegate
+func (mr *MockUserConfigMockRecorder) CredsStoreDelegate() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CredsStoreDelegate", reflect.TypeOf((*MockUserConfig)(nil).CredsStoreDelegate))
+}
+
 // DefaultToGCRAccessToken mocks base method
 func (m *MockUserConfig) DefaultToGCRAccessToken() bool {
 	ret := m.ctrl.Call(m, "DefaultToGCRAccessToken")
@@ -44,6 +130,176 @@ func (mr *MockUserConfigMockRecorder) DefaultToGCRAccessToken() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DefaultToGCRAccessToken", reflect.TypeOf((*MockUserConfig)(nil).DefaultToGCRAccessToken))
 }
 
+// ExecSource mocks base method
+func (m *MockUserConfig) ExecSource(arg0 string) (config.ExecSource, bool) {
+	ret := m.ctrl.Call(m, "ExecSource", arg0)
+	ret0, _ := ret[0].(config.ExecSource)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// ExecSource indicates an expected call of ExecSource
+func (mr *MockUserConfigMockRecorder) ExecSource(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecSource", reflect.TypeOf((*MockUserConfig)(nil).ExecSource), arg0)
+}
+
+// GcloudSelection mocks base method
+func (m *MockUserConfig) GcloudSelection(arg0 string) config.GcloudSelection {
+	ret := m.ctrl.Call(m, "GcloudSelection", arg0)
+	ret0, _ := ret[0].(config.GcloudSelection)
+	return ret0
+}
+
+// GcloudSelection indicates an expected call of GcloudSelection
+func (mr *MockUserConfigMockRecorder) GcloudSelection(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GcloudSelection", reflect.TypeOf((*MockUserConfig)(nil).GcloudSelection), arg0)
+}
+
+// HostedDomain mocks base method
+func (m *MockUserConfig) HostedDomain() string {
+	ret := m.ctrl.Call(m, "HostedDomain")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// HostedDomain indicates an expected call of HostedDomain
+func (mr *MockUserConfigMockRecorder) HostedDomain() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HostedDomain", reflect.TypeOf((*MockUserConfig)(nil).HostedDomain))
+}
+
+// Interactive mocks base method
+func (m *MockUserConfig) Interactive() string {
+	ret := m.ctrl.Call(m, "Interactive")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Interactive indicates an expected call of Interactive
+func (mr *MockUserConfigMockRecorder) Interactive() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Interactive", reflect.TypeOf((*MockUserConfig)(nil).Interactive))
+}
+
+// KeyFile mocks base method
+func (m *MockUserConfig) KeyFile() string {
+	ret := m.ctrl.Call(m, "KeyFile")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// KeyFile indicates an expected call of KeyFile
+func (mr *MockUserConfigMockRecorder) KeyFile() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KeyFile", reflect.TypeOf((*MockUserConfig)(nil).KeyFile))
+}
+
+// KeyFileSelfSignedJWT mocks base method
+func (m *MockUserConfig) KeyFileSelfSignedJWT() bool {
+	ret := m.ctrl.Call(m, "KeyFileSelfSignedJWT")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// KeyFileSelfSignedJWT indicates an expected call of KeyFileSelfSignedJWT
+func (mr *MockUserConfigMockRecorder) KeyFileSelfSignedJWT() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KeyFileSelfSignedJWT", reflect.TypeOf((*MockUserConfig)(nil).KeyFileSelfSignedJWT))
+}
+
+// KeyRotationAge mocks base method
+func (m *MockUserConfig) KeyRotationAge() time.Duration {
+	ret := m.ctrl.Call(m, "KeyRotationAge")
+	ret0, _ := ret[0].(time.Duration)
+	return ret0
+}
+
+// KeyRotationAge indicates an expected call of KeyRotationAge
+func (mr *MockUserConfigMockRecorder) KeyRotationAge() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KeyRotationAge", reflect.TypeOf((*MockUserConfig)(nil).KeyRotationAge))
+}
+
+// LoginHint mocks base method
+func (m *MockUserConfig) LoginHint() string {
+	ret := m.ctrl.Call(m, "LoginHint")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// LoginHint indicates an expected call of LoginHint
+func (mr *MockUserConfigMockRecorder) LoginHint() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginHint", reflect.TypeOf((*MockUserConfig)(nil).LoginHint))
+}
+
+// MetadataHost mocks base method
+func (m *MockUserConfig) MetadataHost() string {
+	ret := m.ctrl.Call(m, "MetadataHost")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// MetadataHost indicates an expected call of MetadataHost
+func (mr *MockUserConfigMockRecorder) MetadataHost() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MetadataHost", reflect.TypeOf((*MockUserConfig)(nil).MetadataHost))
+}
+
+// MetadataNegativeCacheTTL mocks base method
+func (m *MockUserConfig) MetadataNegativeCacheTTL() time.Duration {
+	ret := m.ctrl.Call(m, "MetadataNegativeCacheTTL")
+	ret0, _ := ret[0].(time.Duration)
+	return ret0
+}
+
+// MetadataNegativeCacheTTL indicates an expected call of MetadataNegativeCacheTTL
+func (mr *MockUserConfigMockRecorder) MetadataNegativeCacheTTL() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MetadataNegativeCacheTTL", reflect.TypeOf((*MockUserConfig)(nil).MetadataNegativeCacheTTL))
+}
+
+// MetadataServiceAccount mocks base method
+func (m *MockUserConfig) MetadataServiceAccount() string {
+	ret := m.ctrl.Call(m, "MetadataServiceAccount")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// MetadataServiceAccount indicates an expected call of MetadataServiceAccount
+func (mr *MockUserConfigMockRecorder) MetadataServiceAccount() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MetadataServiceAccount", reflect.TypeOf((*MockUserConfig)(nil).MetadataServiceAccount))
+}
+
+// OAuthClient mocks base method
+func (m *MockUserConfig) OAuthClient() (config.OAuthClient, error) {
+	ret := m.ctrl.Call(m, "OAuthClient")
+	ret0, _ := ret[0].(config.OAuthClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OAuthClient indicates an expected call of OAuthClient
+func (mr *MockUserConfigMockRecorder) OAuthClient() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OAuthClient", reflect.TypeOf((*MockUserConfig)(nil).OAuthClient))
+}
+
+// ReauthNotifyCommand mocks base method
+func (m *MockUserConfig) ReauthNotifyCommand() []string {
+	ret := m.ctrl.Call(m, "ReauthNotifyCommand")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// ReauthNotifyCommand indicates an expected call of ReauthNotifyCommand
+func (mr *MockUserConfigMockRecorder) ReauthNotifyCommand() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReauthNotifyCommand", reflect.TypeOf((*MockUserConfig)(nil).ReauthNotifyCommand))
+}
+
+// ReauthPolicy mocks base method
+func (m *MockUserConfig) ReauthPolicy() string {
+	ret := m.ctrl.Call(m, "ReauthPolicy")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// ReauthPolicy indicates an expected call of ReauthPolicy
+func (mr *MockUserConfigMockRecorder) ReauthPolicy() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReauthPolicy", reflect.TypeOf((*MockUserConfig)(nil).ReauthPolicy))
+}
+
 // ResetAll mocks base method
 func (m *MockUserConfig) ResetAll() error {
 	ret := m.ctrl.Call(m, "ResetAll")
@@ -56,6 +312,18 @@ func (mr *MockUserConfigMockRecorder) ResetAll() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetAll", reflect.TypeOf((*MockUserConfig)(nil).ResetAll))
 }
 
+// SetCredsStore mocks base method
+func (m *MockUserConfig) SetCredsStore(arg0 bool, arg1 string) error {
+	ret := m.ctrl.Call(m, "SetCredsStore", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetCredsStore indicates an expected call of SetCredsStore
+func (mr *MockUserConfigMockRecorder) SetCredsStore(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCredsStore", reflect.TypeOf((*MockUserConfig)(nil).SetCredsStore), arg0, arg1)
+}
+
 // SetDefaultToGCRAccessToken mocks base method
 func (m *MockUserConfig) SetDefaultToGCRAccessToken(arg0 bool) error {
 	ret := m.ctrl.Call(m, "SetDefaultToGCRAccessToken", arg0)
@@ -68,6 +336,42 @@ func (mr *MockUserConfigMockRecorder) SetDefaultToGCRAccessToken(arg0 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDefaultToGCRAccessToken", reflect.TypeOf((*MockUserConfig)(nil).SetDefaultToGCRAccessToken), arg0)
 }
 
+// Scopes mocks base method
+func (m *MockUserConfig) Scopes() []string {
+	ret := m.ctrl.Call(m, "Scopes")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// Scopes indicates an expected call of Scopes
+func (mr *MockUserConfigMockRecorder) Scopes() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scopes", reflect.TypeOf((*MockUserConfig)(nil).Scopes))
+}
+
+// ScopesPinned mocks base method
+func (m *MockUserConfig) ScopesPinned() bool {
+	ret := m.ctrl.Call(m, "ScopesPinned")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// ScopesPinned indicates an expected call of ScopesPinned
+func (mr *MockUserConfigMockRecorder) ScopesPinned() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScopesPinned", reflect.TypeOf((*MockUserConfig)(nil).ScopesPinned))
+}
+
+// Settings mocks base method
+func (m *MockUserConfig) Settings() []config.Setting {
+	ret := m.ctrl.Call(m, "Settings")
+	ret0, _ := ret[0].([]config.Setting)
+	return ret0
+}
+
+// Settings indicates an expected call of Settings
+func (mr *MockUserConfigMockRecorder) Settings() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Settings", reflect.TypeOf((*MockUserConfig)(nil).Settings))
+}
+
 // SetTokenSources mocks base method
 func (m *MockUserConfig) SetTokenSources(arg0 []string) error {
 	ret := m.ctrl.Call(m, "SetTokenSources", arg0)
@@ -80,6 +384,54 @@ func (mr *MockUserConfigMockRecorder) SetTokenSources(arg0 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTokenSources", reflect.TypeOf((*MockUserConfig)(nil).SetTokenSources), arg0)
 }
 
+// Timeout mocks base method
+func (m *MockUserConfig) Timeout() time.Duration {
+	ret := m.ctrl.Call(m, "Timeout")
+	ret0, _ := ret[0].(time.Duration)
+	return ret0
+}
+
+// Timeout indicates an expected call of Timeout
+func (mr *MockUserConfigMockRecorder) Timeout() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Timeout", reflect.TypeOf((*MockUserConfig)(nil).Timeout))
+}
+
+// TokenSourceTimeout mocks base method
+func (m *MockUserConfig) TokenSourceTimeout() time.Duration {
+	ret := m.ctrl.Call(m, "TokenSourceTimeout")
+	ret0, _ := ret[0].(time.Duration)
+	return ret0
+}
+
+// TokenSourceTimeout indicates an expected call of TokenSourceTimeout
+func (mr *MockUserConfigMockRecorder) TokenSourceTimeout() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TokenSourceTimeout", reflect.TypeOf((*MockUserConfig)(nil).TokenSourceTimeout))
+}
+
+// TokenSourceRetries mocks base method
+func (m *MockUserConfig) TokenSourceRetries() int {
+	ret := m.ctrl.Call(m, "TokenSourceRetries")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// TokenSourceRetries indicates an expected call of TokenSourceRetries
+func (mr *MockUserConfigMockRecorder) TokenSourceRetries() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TokenSourceRetries", reflect.TypeOf((*MockUserConfig)(nil).TokenSourceRetries))
+}
+
+// TokenSourceRetryBackoff mocks base method
+func (m *MockUserConfig) TokenSourceRetryBackoff() time.Duration {
+	ret := m.ctrl.Call(m, "TokenSourceRetryBackoff")
+	ret0, _ := ret[0].(time.Duration)
+	return ret0
+}
+
+// TokenSourceRetryBackoff indicates an expected call of TokenSourceRetryBackoff
+func (mr *MockUserConfigMockRecorder) TokenSourceRetryBackoff() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TokenSourceRetryBackoff", reflect.TypeOf((*MockUserConfig)(nil).TokenSourceRetryBackoff))
+}
+
 // TokenSources mocks base method
 func (m *MockUserConfig) TokenSources() []string {
 	ret := m.ctrl.Call(m, "TokenSources")
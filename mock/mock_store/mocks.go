@@ -5,6 +5,7 @@
 package mock_store
 
 import (
+	config "github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
 	store "github.com/GoogleCloudPlatform/docker-credential-gcr/v2/store"
 	credentials "github.com/docker/docker-credential-helpers/credentials"
 	gomock "github.com/golang/mock/gomock"
@@ -72,6 +73,18 @@ func (mr *MockGCRCredStoreMockRecorder) DeleteOtherCreds(arg0 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOtherCreds", reflect.TypeOf((*MockGCRCredStore)(nil).DeleteOtherCreds), arg0)
 }
 
+// DeleteRegistryKey mocks base method
+func (m *MockGCRCredStore) DeleteRegistryKey(arg0 string) error {
+	ret := m.ctrl.Call(m, "DeleteRegistryKey", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRegistryKey indicates an expected call of DeleteRegistryKey
+func (mr *MockGCRCredStoreMockRecorder) DeleteRegistryKey(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRegistryKey", reflect.TypeOf((*MockGCRCredStore)(nil).DeleteRegistryKey), arg0)
+}
+
 // GetGCRAuth mocks base method
 func (m *MockGCRCredStore) GetGCRAuth() (*store.GCRAuth, error) {
 	ret := m.ctrl.Call(m, "GetGCRAuth")
@@ -85,6 +98,32 @@ func (mr *MockGCRCredStoreMockRecorder) GetGCRAuth() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGCRAuth", reflect.TypeOf((*MockGCRCredStore)(nil).GetGCRAuth))
 }
 
+// GetImportedCredential mocks base method
+func (m *MockGCRCredStore) GetImportedCredential() (*store.ImportedCredential, error) {
+	ret := m.ctrl.Call(m, "GetImportedCredential")
+	ret0, _ := ret[0].(*store.ImportedCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImportedCredential indicates an expected call of GetImportedCredential
+func (mr *MockGCRCredStoreMockRecorder) GetImportedCredential() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImportedCredential", reflect.TypeOf((*MockGCRCredStore)(nil).GetImportedCredential))
+}
+
+// GetMetadataUnavailable mocks base method
+func (m *MockGCRCredStore) GetMetadataUnavailable() (*store.MetadataUnavailable, error) {
+	ret := m.ctrl.Call(m, "GetMetadataUnavailable")
+	ret0, _ := ret[0].(*store.MetadataUnavailable)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMetadataUnavailable indicates an expected call of GetMetadataUnavailable
+func (mr *MockGCRCredStoreMockRecorder) GetMetadataUnavailable() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMetadataUnavailable", reflect.TypeOf((*MockGCRCredStore)(nil).GetMetadataUnavailable))
+}
+
 // GetOtherCreds mocks base method
 func (m *MockGCRCredStore) GetOtherCreds(arg0 string) (*credentials.Credentials, error) {
 	ret := m.ctrl.Call(m, "GetOtherCreds", arg0)
@@ -98,16 +137,66 @@ func (mr *MockGCRCredStoreMockRecorder) GetOtherCreds(arg0 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOtherCreds", reflect.TypeOf((*MockGCRCredStore)(nil).GetOtherCreds), arg0)
 }
 
+// GetRegistryKey mocks base method
+func (m *MockGCRCredStore) GetRegistryKey(arg0 string) ([]byte, error) {
+	ret := m.ctrl.Call(m, "GetRegistryKey", arg0)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRegistryKey indicates an expected call of GetRegistryKey
+func (mr *MockGCRCredStoreMockRecorder) GetRegistryKey(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRegistryKey", reflect.TypeOf((*MockGCRCredStore)(nil).GetRegistryKey), arg0)
+}
+
+// RegistryKeys mocks base method
+func (m *MockGCRCredStore) RegistryKeys() ([]string, error) {
+	ret := m.ctrl.Call(m, "RegistryKeys")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegistryKeys indicates an expected call of RegistryKeys
+func (mr *MockGCRCredStoreMockRecorder) RegistryKeys() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegistryKeys", reflect.TypeOf((*MockGCRCredStore)(nil).RegistryKeys))
+}
+
 // SetGCRAuth mocks base method
-func (m *MockGCRCredStore) SetGCRAuth(arg0 *oauth2.Token) error {
-	ret := m.ctrl.Call(m, "SetGCRAuth", arg0)
+func (m *MockGCRCredStore) SetGCRAuth(arg0 *oauth2.Token, arg1 string, arg2 config.OAuthClient) error {
+	ret := m.ctrl.Call(m, "SetGCRAuth", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SetGCRAuth indicates an expected call of SetGCRAuth
-func (mr *MockGCRCredStoreMockRecorder) SetGCRAuth(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetGCRAuth", reflect.TypeOf((*MockGCRCredStore)(nil).SetGCRAuth), arg0)
+func (mr *MockGCRCredStoreMockRecorder) SetGCRAuth(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetGCRAuth", reflect.TypeOf((*MockGCRCredStore)(nil).SetGCRAuth), arg0, arg1, arg2)
+}
+
+// SetImportedCredential mocks base method
+func (m *MockGCRCredStore) SetImportedCredential(arg0 *store.ImportedCredential) error {
+	ret := m.ctrl.Call(m, "SetImportedCredential", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetImportedCredential indicates an expected call of SetImportedCredential
+func (mr *MockGCRCredStoreMockRecorder) SetImportedCredential(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetImportedCredential", reflect.TypeOf((*MockGCRCredStore)(nil).SetImportedCredential), arg0)
+}
+
+// SetMetadataUnavailable mocks base method
+func (m *MockGCRCredStore) SetMetadataUnavailable(arg0 *store.MetadataUnavailable) error {
+	ret := m.ctrl.Call(m, "SetMetadataUnavailable", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMetadataUnavailable indicates an expected call of SetMetadataUnavailable
+func (mr *MockGCRCredStoreMockRecorder) SetMetadataUnavailable(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMetadataUnavailable", reflect.TypeOf((*MockGCRCredStore)(nil).SetMetadataUnavailable), arg0)
 }
 
 // SetOtherCreds mocks base method
@@ -121,3 +210,15 @@ func (m *MockGCRCredStore) SetOtherCreds(arg0 *credentials.Credentials) error {
 func (mr *MockGCRCredStoreMockRecorder) SetOtherCreds(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetOtherCreds", reflect.TypeOf((*MockGCRCredStore)(nil).SetOtherCreds), arg0)
 }
+
+// SetRegistryKey mocks base method
+func (m *MockGCRCredStore) SetRegistryKey(arg0 string, arg1 []byte) error {
+	ret := m.ctrl.Call(m, "SetRegistryKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRegistryKey indicates an expected call of SetRegistryKey
+func (mr *MockGCRCredStoreMockRecorder) SetRegistryKey(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRegistryKey", reflect.TypeOf((*MockGCRCredStore)(nil).SetRegistryKey), arg0, arg1)
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"os"
+	"time"
+
+	"github.com/docker/docker-credential-helpers/credentials"
+)
+
+// The types of credentials which may be stored, named after the "type" of
+// their JSON representation.
+const (
+	// UserCredential is a user's refresh token, from gcr-login.
+	UserCredential = "authorized_user"
+	// ServiceAccountCredential is an imported service account key.
+	ServiceAccountCredential = "service_account"
+	// ExternalAccountCredential is an imported workload identity federation
+	// credential config.
+	ExternalAccountCredential = "external_account"
+)
+
+// importedCredentialAD is the additional data with which imported credentials
+// are encrypted.
+const importedCredentialAD = "imported credential"
+
+type importedCredential struct {
+	Type       string    `json:"type"`
+	Principal  string    `json:"principal,omitempty"`
+	ImportedAt time.Time `json:"imported_at"`
+	// JSON is the encrypted credential.
+	JSON string `json:"json"`
+}
+
+// An ImportedCredential is a service account key or an external account
+// credential config imported into the store, in place of a user's tokens.
+type ImportedCredential struct {
+	// Type is ServiceAccountCredential or ExternalAccountCredential.
+	Type string
+	// Principal is the service account the credential authenticates as, or ""
+	// if unknown.
+	Principal  string
+	ImportedAt time.Time
+	// JSON is the credential, as it was imported.
+	JSON []byte
+}
+
+// GetImportedCredential returns the imported credential, or
+// credentials.NewErrCredentialsNotFound() if there's none.
+func (s *credStore) GetImportedCredential() (*ImportedCredential, error) {
+	creds, err := s.loadDockerCredentials()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, credentials.NewErrCredentialsNotFound()
+		}
+		return nil, err
+	}
+	imported := creds.ImportedCreds
+	if imported == nil {
+		return nil, credentials.NewErrCredentialsNotFound()
+	}
+	data, err := s.open(imported.JSON, importedCredentialAD)
+	if err != nil {
+		return nil, authErr("unable to decrypt the imported credential", err)
+	}
+	return &ImportedCredential{
+		Type:       imported.Type,
+		Principal:  imported.Principal,
+		ImportedAt: imported.ImportedAt,
+		JSON:       data,
+	}, nil
+}
+
+// SetImportedCredential encrypts and stores an imported credential, replacing
+// the stored GCR credentials.
+func (s *credStore) SetImportedCredential(cred *ImportedCredential) error {
+	creds, err := s.loadDockerCredentials()
+	if err != nil {
+		// It's OK if we couldn't read any credentials,
+		// making a new file.
+		creds = &dockerCredentials{}
+	}
+	sealed, err := s.seal(cred.JSON, importedCredentialAD)
+	if err != nil {
+		return authErr("unable to encrypt the imported credential", err)
+	}
+	creds.GCRCreds = nil
+	creds.ImportedCreds = &importedCredential{
+		Type:       cred.Type,
+		Principal:  cred.Principal,
+		ImportedAt: cred.ImportedAt,
+		JSON:       sealed,
+	}
+	return s.setDockerCredentials(creds)
+}
@@ -0,0 +1,221 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/docker/docker-credential-helpers/credentials"
+)
+
+// Service account keys added for a registry, e.g. by
+// `docker login -u _json_key`, and imported credentials are encrypted with
+// AES-256-GCM under a random key kept in a separate file next to the credential
+// store. This keeps them out of copies of the store alone, such as backups or
+// bug reports, but not from anyone able to read both files as the user.
+
+// encryptionKeySize is the size of the AES-256 key which encrypts secrets in
+// the store.
+const encryptionKeySize = 32
+
+// EncryptionKeyPath returns the path of the file holding the key which
+// encrypts the registry keys and imported credentials in the credential store
+// at storePath.
+func EncryptionKeyPath(storePath string) string {
+	return storePath + ".key"
+}
+
+// GetRegistryKey returns the service account key stored for the given registry
+// host, or credentials.NewErrCredentialsNotFound() if there's none.
+func (s *credStore) GetRegistryKey(registry string) ([]byte, error) {
+	creds, err := s.loadDockerCredentials()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, credentials.NewErrCredentialsNotFound()
+		}
+		return nil, err
+	}
+	sealed, ok := creds.RegistryKeys[registry]
+	if !ok {
+		return nil, credentials.NewErrCredentialsNotFound()
+	}
+	key, err := s.open(sealed, registry)
+	if err != nil {
+		return nil, authErr("unable to decrypt the key for "+registry, err)
+	}
+	return key, nil
+}
+
+// SetRegistryKey encrypts and stores a service account key for the given
+// registry host, replacing any stored before.
+func (s *credStore) SetRegistryKey(registry string, key []byte) error {
+	creds, err := s.loadDockerCredentials()
+	if err != nil {
+		// It's OK if we couldn't read any credentials,
+		// making a new file.
+		creds = &dockerCredentials{}
+	}
+	// The registry is authenticated with the key, so that it can't be moved to
+	// another registry.
+	sealed, err := s.seal(key, registry)
+	if err != nil {
+		return authErr("unable to encrypt the key for "+registry, err)
+	}
+	if creds.RegistryKeys == nil {
+		creds.RegistryKeys = map[string]string{}
+	}
+	creds.RegistryKeys[registry] = sealed
+	return s.setDockerCredentials(creds)
+}
+
+// DeleteRegistryKey deletes the service account key stored for the given
+// registry host, if any.
+func (s *credStore) DeleteRegistryKey(registry string) error {
+	creds, err := s.loadDockerCredentials()
+	if err != nil {
+		if os.IsNotExist(err) {
+			// No file, no credentials.
+			return nil
+		}
+		return err
+	}
+	if _, ok := creds.RegistryKeys[registry]; !ok {
+		return nil
+	}
+	delete(creds.RegistryKeys, registry)
+	return s.setDockerCredentials(creds)
+}
+
+// RegistryKeys returns the sorted hosts of the registries which have a stored
+// service account key.
+func (s *credStore) RegistryKeys() ([]string, error) {
+	creds, err := s.loadDockerCredentials()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	registries := make([]string, 0, len(creds.RegistryKeys))
+	for registry := range creds.RegistryKeys {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	return registries, nil
+}
+
+// seal encrypts and authenticates plaintext, along with the given additional
+// data, returning it base64 encoded.
+func (s *credStore) seal(plaintext []byte, additionalData string) (string, error) {
+	aead, err := s.cipher(true)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(additionalData))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts and authenticates the output of seal, which must have been
+// given the same additional data.
+func (s *credStore) open(sealed, additionalData string) ([]byte, error) {
+	aead, err := s.cipher(false)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(additionalData))
+}
+
+// cipher returns the AEAD which encrypts secrets in the store, creating its key
+// if it doesn't exist and create is set.
+func (s *credStore) cipher(create bool) (cipher.AEAD, error) {
+	path := EncryptionKeyPath(s.credentialPath)
+	key, err := readEncryptionKey(path)
+	if os.IsNotExist(err) && create {
+		key, err = createEncryptionKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != encryptionKeySize {
+		return nil, errors.New("malformed encryption key " + path)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// createEncryptionKey writes a new random encryption key to path, readable
+// only by the user. If another helper creates the key first, that key is
+// returned instead, so that neither's secrets become undecryptable.
+func createEncryptionKey(path string) ([]byte, error) {
+	key := make([]byte, encryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return readEncryptionKey(path)
+	} else if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(key); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return key, nil
+}
+
+// readEncryptionKey reads the encryption key at path. A key shorter than
+// expected may have just been created by another helper, so it's reread until
+// it has been written, briefly.
+func readEncryptionKey(path string) ([]byte, error) {
+	for i := 0; ; i++ {
+		key, err := os.ReadFile(path)
+		if err != nil || len(key) >= encryptionKeySize || i == 50 {
+			return key, err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MetadataUnavailable records that a metadata server couldn't be reached, i.e.
+// that the helper isn't running on Google Cloud, so that it isn't probed again
+// on every request.
+type MetadataUnavailable struct {
+	// Host is the metadata server which couldn't be reached.
+	Host string `json:"host"`
+	// Until is when the metadata server may be probed again.
+	Until time.Time `json:"until"`
+}
+
+// MetadataUnavailablePath returns the path of the file recording that the
+// metadata server is unavailable, next to the credential store at storePath.
+func MetadataUnavailablePath(storePath string) string {
+	return storePath + ".metadata"
+}
+
+// GetMetadataUnavailable returns the record that the metadata server is
+// unavailable, or nil if there's none.
+func (s *credStore) GetMetadataUnavailable() (*MetadataUnavailable, error) {
+	data, err := os.ReadFile(MetadataUnavailablePath(s.credentialPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var m MetadataUnavailable
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, authErr("unable to decode the metadata server's availability", err)
+	}
+	return &m, nil
+}
+
+// SetMetadataUnavailable records that the metadata server is unavailable, or
+// removes the record if m is nil.
+func (s *credStore) SetMetadataUnavailable(m *MetadataUnavailable) error {
+	path := MetadataUnavailablePath(s.credentialPath)
+	if m == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
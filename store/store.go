@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -32,7 +33,6 @@ import (
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/util"
 	"github.com/docker/docker-credential-helpers/credentials"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 )
 
 const (
@@ -44,14 +44,31 @@ type tokens struct {
 	AccessToken  string     `json:"access_token"`
 	RefreshToken string     `json:"refresh_token"`
 	TokenExpiry  *time.Time `json:"token_expiry"`
+	// Account is the account which signed in, if known.
+	Account string `json:"account,omitempty"`
+	// ClientID and ClientSecret identify the OAuth2 client which issued the
+	// tokens, if not docker-credential-gcr's own.
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
 }
 
 type dockerCredentials struct {
 	GCRCreds *tokens `json:"gcrCreds,omitempty"`
+	// ImportedCreds is an imported credential, stored in place of GCRCreds.
+	ImportedCreds *importedCredential `json:"importedCreds,omitempty"`
+	// RegistryKeys maps registry hosts to the encrypted service account keys
+	// added for them.
+	RegistryKeys map[string]string `json:"registryKeys,omitempty"`
 }
 
 // A GCRAuth provides access to tokens from a prior login.
 type GCRAuth struct {
+	// Account is the account which signed in, or "" if unknown.
+	Account string
+	// Client is the OAuth2 client which issued the tokens, and which is used
+	// to refresh them.
+	Client config.OAuthClient
+
 	conf         *oauth2.Config
 	initialToken *oauth2.Token
 }
@@ -65,13 +82,34 @@ func (a *GCRAuth) TokenSource(ctx context.Context) oauth2.TokenSource {
 	return a.conf.TokenSource(ctx, a.initialToken)
 }
 
+// RefreshToken returns the stored refresh token.
+func (a *GCRAuth) RefreshToken() string {
+	return a.initialToken.RefreshToken
+}
+
+// Refresh uses the refresh token to obtain a new access token, regardless of
+// whether the current one has expired. Like TokenSource, it won't update the
+// credentials with the new access token.
+func (a *GCRAuth) Refresh(ctx context.Context) (*oauth2.Token, error) {
+	return a.conf.TokenSource(ctx, &oauth2.Token{RefreshToken: a.initialToken.RefreshToken}).Token()
+}
+
 // GCRCredStore describes the interface for a store capable of storing both
-// GCR's credentials (OAuth2 access/refresh tokens) as well as generic
-// Docker credentials.
+// GCR's credentials (OAuth2 access/refresh tokens, or an imported credential)
+// as well as service account keys added for individual registries. It also
+// remembers whether the metadata server was found to be unavailable.
 type GCRCredStore interface {
 	GetGCRAuth() (*GCRAuth, error)
-	SetGCRAuth(tok *oauth2.Token) error
+	SetGCRAuth(tok *oauth2.Token, account string, client config.OAuthClient) error
 	DeleteGCRAuth() error
+	GetImportedCredential() (*ImportedCredential, error)
+	SetImportedCredential(cred *ImportedCredential) error
+	GetRegistryKey(registry string) ([]byte, error)
+	SetRegistryKey(registry string, key []byte) error
+	DeleteRegistryKey(registry string) error
+	RegistryKeys() ([]string, error)
+	GetMetadataUnavailable() (*MetadataUnavailable, error)
+	SetMetadataUnavailable(m *MetadataUnavailable) error
 }
 
 type credStore struct {
@@ -113,12 +151,19 @@ func (s *credStore) GetGCRAuth() (*GCRAuth, error) {
 		expiry = *creds.GCRCreds.TokenExpiry
 	}
 
+	client := config.DefaultOAuthClient
+	if creds.GCRCreds.ClientID != "" {
+		client = config.OAuthClient{ID: creds.GCRCreds.ClientID, Secret: creds.GCRCreds.ClientSecret}
+	}
+
 	return &GCRAuth{
+		Account: creds.GCRCreds.Account,
+		Client:  client,
 		conf: &oauth2.Config{
-			ClientID:     config.GCRCredHelperClientID,
-			ClientSecret: config.GCRCredHelperClientNotSoSecret,
+			ClientID:     client.ID,
+			ClientSecret: client.Secret,
 			Scopes:       config.GCRScopes,
-			Endpoint:     google.Endpoint,
+			Endpoint:     config.GCROAuth2Endpoint,
 			RedirectURL:  "oob",
 		},
 		initialToken: &oauth2.Token{
@@ -129,8 +174,10 @@ func (s *credStore) GetGCRAuth() (*GCRAuth, error) {
 	}, nil
 }
 
-// SetGCRAuth sets the stored GCR credentials.
-func (s *credStore) SetGCRAuth(tok *oauth2.Token) error {
+// SetGCRAuth sets the stored GCR credentials, along with the account which
+// signed in, which may be "" if unknown, and the OAuth2 client which issued
+// them. Any imported credential is replaced.
+func (s *credStore) SetGCRAuth(tok *oauth2.Token, account string, client config.OAuthClient) error {
 	creds, err := s.loadDockerCredentials()
 	if err != nil {
 		// It's OK if we couldn't read any credentials,
@@ -138,16 +185,23 @@ func (s *credStore) SetGCRAuth(tok *oauth2.Token) error {
 		creds = &dockerCredentials{}
 	}
 
+	creds.ImportedCreds = nil
 	creds.GCRCreds = &tokens{
 		AccessToken:  tok.AccessToken,
 		RefreshToken: tok.RefreshToken,
 		TokenExpiry:  &tok.Expiry,
+		Account:      account,
+	}
+	if !client.IsDefault() {
+		creds.GCRCreds.ClientID = client.ID
+		creds.GCRCreds.ClientSecret = client.Secret
 	}
 
 	return s.setDockerCredentials(creds)
 }
 
-// DeleteGCRAuth deletes the stored GCR credentials.
+// DeleteGCRAuth deletes the stored GCR credentials, including any imported
+// credential.
 func (s *credStore) DeleteGCRAuth() error {
 	creds, err := s.loadDockerCredentials()
 	if err != nil {
@@ -159,8 +213,9 @@ func (s *credStore) DeleteGCRAuth() error {
 	}
 
 	// Optimization: only perform a 'set' if necessary
-	if creds.GCRCreds != nil {
+	if creds.GCRCreds != nil || creds.ImportedCreds != nil {
 		creds.GCRCreds = nil
+		creds.ImportedCreds = nil
 		return s.setDockerCredentials(creds)
 	}
 	return nil
@@ -182,6 +237,7 @@ func (s *credStore) createCredentialFile() (*os.File, error) {
 
 func (s *credStore) loadDockerCredentials() (*dockerCredentials, error) {
 	path := s.credentialPath
+	slog.Debug("store: loading credentials", "path", path)
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -197,6 +253,7 @@ func (s *credStore) loadDockerCredentials() (*dockerCredentials, error) {
 }
 
 func (s *credStore) setDockerCredentials(creds *dockerCredentials) error {
+	slog.Debug("store: saving credentials", "path", s.credentialPath)
 	f, err := s.createCredentialFile()
 	if err != nil {
 		return err
@@ -206,6 +263,12 @@ func (s *credStore) setDockerCredentials(creds *dockerCredentials) error {
 	return json.NewEncoder(f).Encode(creds)
 }
 
+// DefaultCredStorePath returns the full path of the file which backs the
+// DefaultGCRCredStore.
+func DefaultCredStorePath() (string, error) {
+	return dockerCredentialPath()
+}
+
 // dockerCredentialPath returns the full path of our Docker credential store.
 func dockerCredentialPath() (string, error) {
 	if path := os.Getenv(credentialStoreEnvVar); strings.TrimSpace(path) != "" {
@@ -22,9 +22,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/config"
+	"github.com/docker/docker-credential-helpers/credentials"
 	"golang.org/x/oauth2"
 )
 
@@ -115,7 +119,9 @@ func TestSetGCRAuth_NoFile(t *testing.T) {
 		Expiry:       expectedExpiry,
 	}
 
-	err = tested.SetGCRAuth(gcrTok)
+	const expectedAccount = "user@example.com"
+
+	err = tested.SetGCRAuth(gcrTok, expectedAccount, config.DefaultOAuthClient)
 	if err != nil {
 		t.Fatalf("SetGCRAuth returned an error: %v", err)
 	}
@@ -125,6 +131,9 @@ func TestSetGCRAuth_NoFile(t *testing.T) {
 		t.Fatalf("GetGCRAuth returned an error: %v", err)
 	}
 
+	if auth.Account != expectedAccount {
+		t.Errorf("account: Expected \"%s\", got \"%s\"", expectedAccount, auth.Account)
+	}
 	actualAccessTok := auth.initialToken.AccessToken
 	if actualAccessTok != testAccessToken {
 		t.Errorf("access_token: Expected \"%s\", got \"%s\"", testAccessToken, actualAccessTok)
@@ -157,7 +166,7 @@ func TestSetGCRAuth_OverwriteOld(t *testing.T) {
 		Expiry:       expectedExpiry,
 	}
 
-	err := tested.SetGCRAuth(gcrTok)
+	err := tested.SetGCRAuth(gcrTok, "", config.DefaultOAuthClient)
 	if err != nil {
 		t.Fatalf("SetGCRAuth returned an error: %v", err)
 	}
@@ -199,7 +208,7 @@ func TestSetGCRAuth_PreserveOthers(t *testing.T) {
 		Expiry:       expectedExpiry,
 	}
 
-	err := tested.SetGCRAuth(gcrTok)
+	err := tested.SetGCRAuth(gcrTok, "", config.DefaultOAuthClient)
 	if err != nil {
 		t.Fatalf("SetGCRAuth returned an error: %v", err)
 	}
@@ -287,7 +296,7 @@ func TestGCRAuthLifespan(t *testing.T) {
 	}
 
 	// set the credentials
-	err = tested.SetGCRAuth(gcrTok)
+	err = tested.SetGCRAuth(gcrTok, "", config.DefaultOAuthClient)
 	if err != nil {
 		t.Fatalf("SetGCRAuth returned an error: %v", err)
 	}
@@ -322,3 +331,227 @@ func TestGCRAuthLifespan(t *testing.T) {
 		t.Fatalf("Expected no credentials, got %v", *auth)
 	}
 }
+
+func TestSetGCRAuth_CustomClient(t *testing.T) {
+	err := cleanUp()
+	if err != nil {
+		t.Fatal("Could not guarantee that no credential file existed.")
+	}
+	tested := getCredStore(t)
+	expectedClient := config.OAuthClient{ID: "mine.apps.googleusercontent.com", Secret: "shh"}
+
+	err = tested.SetGCRAuth(&oauth2.Token{AccessToken: testAccessToken}, "", expectedClient)
+	if err != nil {
+		t.Fatalf("SetGCRAuth returned an error: %v", err)
+	}
+
+	auth, err := tested.GetGCRAuth()
+	if err != nil {
+		t.Fatalf("GetGCRAuth returned an error: %v", err)
+	}
+	if auth.Client != expectedClient {
+		t.Errorf("client: Expected %+v, got %+v", expectedClient, auth.Client)
+	}
+	if auth.conf.ClientID != expectedClient.ID || auth.conf.ClientSecret != expectedClient.Secret {
+		t.Errorf("Expected refreshes to use the stored client, got: %s", auth.conf.ClientID)
+	}
+
+	// docker-credential-gcr's own client isn't stored.
+	err = tested.SetGCRAuth(&oauth2.Token{AccessToken: testAccessToken}, "", config.DefaultOAuthClient)
+	if err != nil {
+		t.Fatalf("SetGCRAuth returned an error: %v", err)
+	}
+	data, err := os.ReadFile(testCredStorePath)
+	if err != nil {
+		t.Fatalf("Unable to read the credential store: %v", err)
+	}
+	if strings.Contains(string(data), "client_id") {
+		t.Errorf("Expected the default client not to be stored, got: %s", data)
+	}
+}
+
+func TestRegistryKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), credentialStoreFilename)
+	tested := NewGCRCredStore(path)
+	const key = `{"type":"service_account","private_key":"so secret"}`
+
+	if err := tested.SetRegistryKey("gcr.io", []byte(key)); err != nil {
+		t.Fatalf("SetRegistryKey returned an error: %v", err)
+	}
+	if err := tested.SetRegistryKey("us-docker.pkg.dev", []byte("other")); err != nil {
+		t.Fatalf("SetRegistryKey returned an error: %v", err)
+	}
+
+	actual, err := tested.GetRegistryKey("gcr.io")
+	if err != nil || string(actual) != key {
+		t.Errorf("Expected the stored key, got: %s, %v", actual, err)
+	}
+	if _, err := tested.GetRegistryKey("eu.gcr.io"); !credentials.IsErrCredentialsNotFound(err) {
+		t.Errorf("Expected no key for another registry, got: %v", err)
+	}
+	if registries, err := tested.RegistryKeys(); err != nil || strings.Join(registries, ",") != "gcr.io,us-docker.pkg.dev" {
+		t.Errorf("Expected both registries, got: %v, %v", registries, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read the credential store: %v", err)
+	}
+	if strings.Contains(string(data), "so secret") {
+		t.Errorf("Expected the key to be encrypted, got: %s", data)
+	}
+
+	if err := tested.DeleteRegistryKey("gcr.io"); err != nil {
+		t.Fatalf("DeleteRegistryKey returned an error: %v", err)
+	}
+	if _, err := tested.GetRegistryKey("gcr.io"); !credentials.IsErrCredentialsNotFound(err) {
+		t.Errorf("Expected the key to be deleted, got: %v", err)
+	}
+}
+
+func TestRegistryKeys_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), credentialStoreFilename)
+
+	// Each helper may create the encryption key, but only one key may win.
+	sealed := make([]string, 20)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range sealed {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			var err error
+			if sealed[i], err = NewGCRCredStore(path).(*credStore).seal([]byte("secret"), "gcr.io"); err != nil {
+				t.Errorf("seal returned an error: %v", err)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	tested := NewGCRCredStore(path).(*credStore)
+	for _, s := range sealed {
+		if secret, err := tested.open(s, "gcr.io"); err != nil || string(secret) != "secret" {
+			t.Errorf("Expected every secret to be decryptable, got: %q, %v", secret, err)
+		}
+	}
+}
+
+func TestGetRegistryKey_BoundToRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), credentialStoreFilename)
+	tested := NewGCRCredStore(path)
+	if err := tested.SetRegistryKey("gcr.io", []byte("key")); err != nil {
+		t.Fatalf("SetRegistryKey returned an error: %v", err)
+	}
+
+	// Move the encrypted key to another registry.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read the credential store: %v", err)
+	}
+	var creds dockerCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		t.Fatalf("Unable to decode the credential store: %v", err)
+	}
+	creds.RegistryKeys["evil.gcr.io"] = creds.RegistryKeys["gcr.io"]
+	if data, err = json.Marshal(creds); err != nil {
+		t.Fatalf("Unable to encode the credential store: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Unable to write the credential store: %v", err)
+	}
+
+	if _, err := tested.GetRegistryKey("evil.gcr.io"); err == nil {
+		t.Error("Expected a key moved to another registry not to decrypt")
+	}
+	if err := os.Remove(EncryptionKeyPath(path)); err != nil {
+		t.Fatalf("Unable to remove the encryption key: %v", err)
+	}
+	if _, err := tested.GetRegistryKey("gcr.io"); err == nil {
+		t.Error("Expected the key not to decrypt without the encryption key")
+	}
+}
+
+func TestImportedCredential(t *testing.T) {
+	path := filepath.Join(t.TempDir(), credentialStoreFilename)
+	tested := NewGCRCredStore(path)
+	if err := tested.SetGCRAuth(&oauth2.Token{AccessToken: testAccessToken, RefreshToken: "r"}, "me@example.com", config.DefaultOAuthClient); err != nil {
+		t.Fatalf("SetGCRAuth returned an error: %v", err)
+	}
+	if _, err := tested.GetImportedCredential(); !credentials.IsErrCredentialsNotFound(err) {
+		t.Errorf("Expected no imported credential, got: %v", err)
+	}
+
+	expected := &ImportedCredential{
+		Type:       ServiceAccountCredential,
+		Principal:  "sa@example.com",
+		ImportedAt: time.Now().UTC().Truncate(time.Second),
+		JSON:       []byte(`{"type":"service_account","private_key":"so secret"}`),
+	}
+	if err := tested.SetImportedCredential(expected); err != nil {
+		t.Fatalf("SetImportedCredential returned an error: %v", err)
+	}
+
+	actual, err := tested.GetImportedCredential()
+	if err != nil {
+		t.Fatalf("GetImportedCredential returned an error: %v", err)
+	}
+	if actual.Type != expected.Type || actual.Principal != expected.Principal || !actual.ImportedAt.Equal(expected.ImportedAt) || string(actual.JSON) != string(expected.JSON) {
+		t.Errorf("Expected %+v, got: %+v", expected, actual)
+	}
+	if _, err := tested.GetGCRAuth(); err == nil {
+		t.Error("Expected the imported credential to replace the user's tokens")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read the credential store: %v", err)
+	}
+	if strings.Contains(string(data), "so secret") {
+		t.Errorf("Expected the credential to be encrypted, got: %s", data)
+	}
+
+	if err := tested.DeleteGCRAuth(); err != nil {
+		t.Fatalf("DeleteGCRAuth returned an error: %v", err)
+	}
+	if _, err := tested.GetImportedCredential(); !credentials.IsErrCredentialsNotFound(err) {
+		t.Errorf("Expected the imported credential to be deleted, got: %v", err)
+	}
+}
+
+func TestSetGCRAuth_ReplacesImportedCredential(t *testing.T) {
+	tested := NewGCRCredStore(filepath.Join(t.TempDir(), credentialStoreFilename))
+	if err := tested.SetImportedCredential(&ImportedCredential{Type: ExternalAccountCredential, JSON: []byte("{}")}); err != nil {
+		t.Fatalf("SetImportedCredential returned an error: %v", err)
+	}
+
+	if err := tested.SetGCRAuth(&oauth2.Token{AccessToken: testAccessToken}, "", config.DefaultOAuthClient); err != nil {
+		t.Fatalf("SetGCRAuth returned an error: %v", err)
+	}
+
+	if _, err := tested.GetImportedCredential(); !credentials.IsErrCredentialsNotFound(err) {
+		t.Errorf("Expected the imported credential to be replaced, got: %v", err)
+	}
+}
+
+func TestMetadataUnavailable(t *testing.T) {
+	tested := NewGCRCredStore(filepath.Join(t.TempDir(), credentialStoreFilename))
+	if m, err := tested.GetMetadataUnavailable(); err != nil || m != nil {
+		t.Errorf("Expected no record, got: %+v, %v", m, err)
+	}
+
+	expected := &MetadataUnavailable{Host: "169.254.169.254", Until: time.Now().Add(time.Hour).UTC().Truncate(time.Second)}
+	if err := tested.SetMetadataUnavailable(expected); err != nil {
+		t.Fatalf("SetMetadataUnavailable returned an error: %v", err)
+	}
+	if m, err := tested.GetMetadataUnavailable(); err != nil || m == nil || m.Host != expected.Host || !m.Until.Equal(expected.Until) {
+		t.Errorf("Expected %+v, got: %+v, %v", expected, m, err)
+	}
+
+	if err := tested.SetMetadataUnavailable(nil); err != nil {
+		t.Fatalf("SetMetadataUnavailable returned an error: %v", err)
+	}
+	if m, err := tested.GetMetadataUnavailable(); err != nil || m != nil {
+		t.Errorf("Expected the record to be removed, got: %+v, %v", m, err)
+	}
+}
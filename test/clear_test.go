@@ -0,0 +1,141 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/v2/util"
+	cliconfig "github.com/docker/cli/cli/config"
+)
+
+// setUpClear configures the helper, stores credentials and configures an
+// isolated Docker config directory, which is returned.
+func setUpClear(t *testing.T) string {
+	if err := initTestEnvironment(); err != nil {
+		t.Fatalf("Could not initialize test environment: %v", err)
+	}
+	assertTestEnv(t)
+
+	dockerConfigDir := t.TempDir()
+	// An entry for another helper, which must be preserved.
+	if err := os.WriteFile(filepath.Join(dockerConfigDir, cliconfig.ConfigFileName), []byte(`{"credHelpers":{"other.example.com":"other"}}`), 0600); err != nil {
+		t.Fatalf("Unable to write the docker config: %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"config", "--token-source=store"},
+		{"configure-docker", "--registries=gcr.io,us.gcr.io"},
+	} {
+		helper := dockerConfigCmd(dockerConfigDir, args)
+		if out, err := helper.CombinedOutput(); err != nil {
+			t.Fatalf("`%s` failed: %v, Output: %s", strings.Join(args, " "), err, out)
+		}
+	}
+	if err := writeValidGCRCreds(gcrAccessToken, gcrRefreshToken); err != nil {
+		t.Fatalf("Unable to write creds store: %v", err)
+	}
+	return dockerConfigDir
+}
+
+// dockerConfigCmd returns a helperCmd which uses the given Docker config
+// directory.
+func dockerConfigCmd(dockerConfigDir string, args []string) *exec.Cmd {
+	helper := helperCmd(args)
+	helper.Env = append(os.Environ(), "DOCKER_CONFIG="+dockerConfigDir)
+	return helper
+}
+
+func TestClear_All(t *testing.T) {
+	dockerConfigDir := setUpClear(t)
+
+	// --local-only avoids revoking the fake refresh token at Google.
+	helper := dockerConfigCmd(dockerConfigDir, []string{"clear", "--all", "--yes", "--local-only"})
+	if out, err := helper.CombinedOutput(); err != nil {
+		t.Fatalf("`clear --all` failed: %v, Output: %s", err, out)
+	}
+
+	// Only an empty test environment should remain.
+	assertTestEnv(t)
+	dockerConfig, err := cliconfig.Load(dockerConfigDir)
+	if err != nil {
+		t.Fatalf("Unable to load the docker config: %v", err)
+	}
+	if len(dockerConfig.CredentialHelpers) != 1 || dockerConfig.CredentialHelpers["other.example.com"] != "other" {
+		t.Errorf("Expected only the other helper's entry to remain, got: %v", dockerConfig.CredentialHelpers)
+	}
+}
+
+func TestClear_NonInteractive(t *testing.T) {
+	if util.HasTerminal() {
+		t.Skip("the helper would find the terminal to confirm with")
+	}
+	dockerConfigDir := setUpClear(t)
+
+	// Without a terminal, clear can't be confirmed and must fail, rather than
+	// report success having skipped everything.
+	helper := dockerConfigCmd(dockerConfigDir, []string{"clear", "--all", "--local-only"})
+	helper.Stdin = strings.NewReader("y\n")
+	var stderr bytes.Buffer
+	helper.Stderr = &stderr
+	if err := helper.Run(); err == nil {
+		t.Fatal("Expected `clear --all` without --yes to fail")
+	}
+	if !strings.Contains(stderr.String(), "--yes") {
+		t.Errorf("Expected the error to suggest --yes, got: %s", stderr.String())
+	}
+	for _, path := range []func() (string, error){testConfigPath, testCredStorePath} {
+		p, _ := path()
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("Expected %s to remain: %v", p, err)
+		}
+	}
+	dockerConfig, err := cliconfig.Load(dockerConfigDir)
+	if err != nil {
+		t.Fatalf("Unable to load the docker config: %v", err)
+	}
+	if len(dockerConfig.CredentialHelpers) != 3 {
+		t.Errorf("Expected the docker config to be unchanged, got: %v", dockerConfig.CredentialHelpers)
+	}
+	if err := removeTestFiles(); err != nil {
+		t.Fatalf("Unable to clean up: %v", err)
+	}
+}
+
+func TestClear_DefaultsToCredentials(t *testing.T) {
+	dockerConfigDir := setUpClear(t)
+
+	helper := dockerConfigCmd(dockerConfigDir, []string{"clear", "--yes", "--local-only"})
+	if out, err := helper.CombinedOutput(); err != nil {
+		t.Fatalf("`clear` failed: %v, Output: %s", err, out)
+	}
+
+	storePath, _ := testCredStorePath()
+	if _, err := os.Stat(storePath); !os.IsNotExist(err) {
+		t.Errorf("Expected the credential store to be removed: %v", err)
+	}
+	configPath, _ := testConfigPath()
+	if _, err := os.Stat(configPath); err != nil {
+		t.Errorf("Expected the config to remain: %v", err)
+	}
+	if err := removeTestFiles(); err != nil {
+		t.Fatalf("Unable to clean up: %v", err)
+	}
+}
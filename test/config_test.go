@@ -53,8 +53,8 @@ func TestConfig(t *testing.T) {
 	configBuf, err := ioutil.ReadFile(configPath)
 	if err != nil {
 		t.Fatalf("Unable to verify config: %v", err)
-	} else if configStr := string(configBuf); strings.TrimSpace(configStr) != `{"TokenSources":["store"]}` {
-		t.Fatalf("Expected config: %s, was: %s", `{"TokenSources":["store"]}`, configStr)
+	} else if configStr := string(configBuf); strings.TrimSpace(configStr) != `{"SchemaVersion":1,"TokenSources":["store"]}` {
+		t.Fatalf("Expected config: %s, was: %s", `{"SchemaVersion":1,"TokenSources":["store"]}`, configStr)
 	}
 
 	// Unset everything.
@@ -178,3 +178,58 @@ func TestConfigureDocker_NonDefault(t *testing.T) {
 		}
 	}
 }
+
+func TestConfigureDocker_AsCredsStore(t *testing.T) {
+	if err := initTestEnvironment(); err != nil {
+		t.Fatalf("Could not initialize test environment: %v", err)
+	}
+	assertTestEnv(t)
+
+	// A fake credsStore, to which other registries are delegated.
+	binDir := t.TempDir()
+	script := "#!/bin/sh\nread url\necho \"{\\\"ServerURL\\\":\\\"$url\\\",\\\"Username\\\":\\\"delegated\\\",\\\"Secret\\\":\\\"hunter2\\\"}\"\n"
+	if err := os.WriteFile(filepath.Join(binDir, "docker-credential-fake"), []byte(script), 0755); err != nil {
+		t.Fatalf("Unable to write the fake credsStore: %v", err)
+	}
+	dockerConfigDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dockerConfigDir, cliconfig.ConfigFileName), []byte(`{"credsStore":"fake","credHelpers":{"gcr.io":"gcr","other.example.com":"other"}}`), 0600); err != nil {
+		t.Fatalf("Unable to write the docker config: %v", err)
+	}
+	run := func(stdin string, args ...string) string {
+		helper := dockerConfigCmd(dockerConfigDir, args)
+		helper.Env = append(helper.Env, "PATH="+binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+		helper.Stdin = strings.NewReader(stdin)
+		out, err := helper.CombinedOutput()
+		if err != nil {
+			t.Fatalf("`%s` failed: %v, Output: %s", strings.Join(args, " "), err, out)
+		}
+		return string(out)
+	}
+
+	run("", "configure-docker", "--as-creds-store")
+
+	dockerConfig, err := cliconfig.Load(dockerConfigDir)
+	if err != nil {
+		t.Fatalf("Unable to load the docker config: %v", err)
+	}
+	if dockerConfig.CredentialsStore != "gcr" {
+		t.Errorf("Expected credsStore: gcr, got: %s", dockerConfig.CredentialsStore)
+	}
+	if _, ok := dockerConfig.CredentialHelpers["gcr.io"]; ok || dockerConfig.CredentialHelpers["other.example.com"] != "other" {
+		t.Errorf("Expected only this helper's credHelpers entries to be removed, got: %v", dockerConfig.CredentialHelpers)
+	}
+
+	if out := run("https://index.docker.io/v1/", "get"); !strings.Contains(out, `"Username":"delegated"`) {
+		t.Errorf("Expected the fake credsStore's credentials, got: %s", out)
+	}
+
+	// Clearing the Docker config restores the delegate.
+	run("", "clear", "--docker-config", "--yes")
+	dockerConfig, err = cliconfig.Load(dockerConfigDir)
+	if err != nil {
+		t.Fatalf("Unable to load the docker config: %v", err)
+	}
+	if dockerConfig.CredentialsStore != "fake" {
+		t.Errorf("Expected the fake credsStore to be restored, got: %s", dockerConfig.CredentialsStore)
+	}
+}
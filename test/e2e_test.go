@@ -19,7 +19,11 @@ package test
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -100,8 +104,8 @@ func TestEndToEnd_GCRCreds(t *testing.T) {
 	configBuf, err := ioutil.ReadFile(configPath)
 	if err != nil {
 		t.Fatalf("Unable to verify config: %v", err)
-	} else if configStr := string(configBuf); strings.TrimSpace(configStr) != `{"TokenSources":["store"]}` {
-		t.Fatalf("Expected config: %s, was: %s", `{"TokenSources":["store"]}`, configStr)
+	} else if configStr := string(configBuf); strings.TrimSpace(configStr) != `{"SchemaVersion":1,"TokenSources":["store"]}` {
+		t.Fatalf("Expected config: %s, was: %s", `{"SchemaVersion":1,"TokenSources":["store"]}`, configStr)
 	}
 
 	if err := writeValidGCRCreds(gcrAccessToken, gcrRefreshToken); err != nil {
@@ -134,10 +138,151 @@ func TestEndToEnd_GCRCreds(t *testing.T) {
 		t.Errorf("Bad GCR access token. Wanted: %s, Got: %s", gcrAccessToken, creds.Secret)
 	}
 
-	// erase the credentials
+	// erase only removes a service account key added for the registry, not
+	// the signed-in credentials.
 	helper = helperCmd([]string{"erase"})
 	helper.Stdin = strings.NewReader(gcrRegistry)
-	if err = helper.Run(); err == nil {
-		t.Fatal("Expected erase to fail for GCR hostname.")
+	if err = helper.Run(); err != nil {
+		t.Fatalf("`erase` failed: %v", err)
+	}
+	helper = helperCmd([]string{"get"})
+	helper.Stdin = strings.NewReader(gcrRegistry)
+	if err = helper.Run(); err != nil {
+		t.Fatalf("Expected the signed-in credentials to remain after erase: %v", err)
+	}
+}
+
+func TestEndToEnd_ImportedServiceAccountKey(t *testing.T) {
+	if err := initTestEnvironment(); err != nil {
+		t.Fatalf("Could not initialize test environment: %v", err)
+	}
+	assertTestEnv(t)
+
+	helper := helperCmd([]string{"config", "--token-source=store"})
+	if err := helper.Run(); err != nil {
+		t.Fatalf("Failed to configure the helper: %v", err)
+	}
+
+	// import a service account key
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unable to generate a private key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(pk)
+	if err != nil {
+		t.Fatalf("Unable to marshal the private key: %v", err)
+	}
+	key, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"private_key_id": "k3y",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "ci@my-project.iam.gserviceaccount.com",
+		"token_uri":      "https://oauth2.googleapis.com/token",
+	})
+	if err != nil {
+		t.Fatalf("Unable to marshal the service account key: %v", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "sa.json")
+	if err := os.WriteFile(keyFile, key, 0600); err != nil {
+		t.Fatalf("Unable to write the service account key: %v", err)
+	}
+	helper = helperCmd([]string{"gcr-login", "--key-file=" + keyFile})
+	if out, err := helper.CombinedOutput(); err != nil {
+		t.Fatalf("`gcr-login --key-file` failed: %v, Output: %s", err, out)
+	}
+
+	// The store's tokens are minted from the key: scoped, they're self-signed
+	// JWTs.
+	helper = helperCmd([]string{"get"})
+	var out bytes.Buffer
+	helper.Stdout = &out
+	helper.Stdin = strings.NewReader(gcrRegistry)
+	if err = helper.Run(); err != nil {
+		t.Fatalf("`get` failed: %v, Stdout: %s", err, out.String())
+	}
+	var creds credentials.Credentials
+	if err := json.NewDecoder(&out).Decode(&creds); err != nil {
+		t.Fatalf("Unable to decode credentials returned from get: %v", err)
+	}
+	if strings.Count(creds.Secret, ".") != 2 {
+		t.Errorf("Expected a JWT minted from the imported key, got: %s", creds.Secret)
+	}
+}
+
+func TestEndToEnd_ImportFromGcloud(t *testing.T) {
+	if err := initTestEnvironment(); err != nil {
+		t.Fatalf("Could not initialize test environment: %v", err)
+	}
+	assertTestEnv(t)
+
+	helper := helperCmd([]string{"gcr-login", "--from-gcloud", "--account=user@example.com"})
+	helper.Env = append(os.Environ(), "CLOUDSDK_CONFIG=../gcloud/testdata/valid")
+	if out, err := helper.CombinedOutput(); err != nil {
+		t.Fatalf("`gcr-login --from-gcloud` failed: %v, Output: %s", err, out)
+	}
+
+	credStorePath, err := testCredStorePath()
+	if err != nil {
+		t.Fatalf("Unable to construct test credential store path: %v", err)
+	}
+	var stored struct {
+		GCRCreds struct {
+			RefreshToken string `json:"refresh_token"`
+			Account      string `json:"account"`
+			ClientID     string `json:"client_id"`
+		} `json:"gcrCreds"`
+	}
+	data, err := os.ReadFile(credStorePath)
+	if err != nil {
+		t.Fatalf("Unable to read the credential store: %v", err)
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatalf("Unable to decode the credential store: %v", err)
+	}
+	if creds := stored.GCRCreds; creds.RefreshToken != "user-refresh-token" || creds.Account != "user@example.com" || creds.ClientID == "" {
+		t.Errorf("Expected gcloud's refresh token and client to be stored, got: %s", data)
+	}
+}
+
+func TestEndToEnd_AccessTokenFile(t *testing.T) {
+	if err := initTestEnvironment(); err != nil {
+		t.Fatalf("Could not initialize test environment: %v", err)
+	}
+	assertTestEnv(t)
+
+	helper := helperCmd([]string{"config", "--token-source=file"})
+	if err := helper.Run(); err != nil {
+		t.Fatalf("Failed to configure the helper: %v", err)
+	}
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	get := func() (string, error) {
+		helper := helperCmd([]string{"get"})
+		helper.Env = append(os.Environ(), "DOCKER_CREDENTIAL_GCR_ACCESS_TOKEN_FILE="+tokenFile)
+		helper.Stdin = strings.NewReader(gcrRegistry)
+		out, err := helper.CombinedOutput()
+		return string(out), err
+	}
+
+	if err := os.WriteFile(tokenFile, []byte("ya29.mounted\n"), 0600); err != nil {
+		t.Fatalf("Unable to write the token file: %v", err)
+	}
+	out, err := get()
+	if err != nil {
+		t.Fatalf("`get` failed: %v, Output: %s", err, out)
+	}
+	var creds credentials.Credentials
+	if err := json.Unmarshal([]byte(out), &creds); err != nil {
+		t.Fatalf("Unable to decode credentials returned from get: %v", err)
+	}
+	if creds.Secret != "ya29.mounted" {
+		t.Errorf("Expected the mounted token, got: %s", creds.Secret)
+	}
+
+	if err := os.WriteFile(tokenFile, []byte(`{"access_token":"ya29.mounted","expiry":"2020-01-02T15:04:05Z"}`), 0600); err != nil {
+		t.Fatalf("Unable to write the token file: %v", err)
+	}
+	if out, err := get(); err == nil || !strings.Contains(out, "expired") {
+		t.Errorf("Expected `get` to fail for an expired token, got: %v, Output: %s", err, out)
 	}
 }
@@ -45,8 +45,14 @@ func removeTestFiles() error {
 	if err != nil {
 		return err
 	}
-	if _, err = os.Stat(path); err == nil {
-		return os.Remove(path)
+	// the credential store, the key encrypting its secrets and the record of
+	// the metadata server's availability
+	for _, path := range []string{path, path + ".key", path + ".metadata"} {
+		if _, err = os.Stat(path); err == nil {
+			if err = os.Remove(path); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -19,12 +19,20 @@ interface.
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"os/exec"
+	"time"
 )
 
+// waitDelay bounds how long Exec waits for a killed command's children to
+// release its output after the context is done.
+const waitDelay = time.Second
+
 // Command execs a command with the given arguments.
 type Command interface {
-	Exec(...string) ([]byte, error)
+	// Exec executes the command, killing it if ctx is done before it exits.
+	Exec(ctx context.Context, args ...string) ([]byte, error)
 }
 
 // RealImpl is a real implementation of Command which uses exec.Command to
@@ -35,7 +43,14 @@ type RealImpl struct {
 }
 
 // Exec executes the defined command with the given args, returning the results
-// of stdout, or an error.
-func (s *RealImpl) Exec(args ...string) ([]byte, error) {
-	return exec.Command(s.Command, args...).Output()
+// of stdout, or an error. If ctx is done first, the command is killed and the
+// context's error is returned.
+func (s *RealImpl) Exec(ctx context.Context, args ...string) ([]byte, error) {
+	c := exec.CommandContext(ctx, s.Command, args...)
+	c.WaitDelay = waitDelay
+	out, err := c.Output()
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return nil, fmt.Errorf("`%s` was killed: %w", s.Command, ctxErr)
+	}
+	return out, err
 }
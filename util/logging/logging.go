@@ -0,0 +1,107 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package logging configures the structured (log/slog) logger used throughout
+the credential helper.
+
+Since Docker swallows the helper's stderr, logs may be written to a file via
+DOCKER_CREDENTIAL_GCR_LOG_FILE. Attributes with sensitive keys (see
+SensitiveKeys) are always redacted.
+*/
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	// LevelEnvVar designates the minimum level to log: debug, info, warn or
+	// error.
+	LevelEnvVar = "DOCKER_CREDENTIAL_GCR_LOG_LEVEL"
+	// FileEnvVar designates a file to append logs to, instead of stderr.
+	FileEnvVar = "DOCKER_CREDENTIAL_GCR_LOG_FILE"
+
+	// Redacted replaces the value of any sensitive attribute.
+	Redacted = "REDACTED"
+)
+
+// SensitiveKeys are the attribute keys whose values are always redacted.
+var SensitiveKeys = map[string]bool{
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"id_token":      true,
+	"secret":        true,
+	"client_secret": true,
+	"password":      true,
+	"private_key":   true,
+	"code":          true,
+	"code_verifier": true,
+}
+
+// Init configures the default slog.Logger from the environment, returning a
+// func which closes the log file, if any. If neither LevelEnvVar nor
+// FileEnvVar is set, logs are discarded.
+func Init() (func() error, error) {
+	levelStr := strings.TrimSpace(os.Getenv(LevelEnvVar))
+	path := strings.TrimSpace(os.Getenv(FileEnvVar))
+	noop := func() error { return nil }
+
+	if levelStr == "" && path == "" {
+		slog.SetDefault(slog.New(slog.DiscardHandler))
+		return noop, nil
+	}
+
+	level := slog.LevelInfo
+	if levelStr != "" {
+		if err := level.UnmarshalText([]byte(levelStr)); err != nil {
+			return noop, fmt.Errorf("invalid %s %q: %v", LevelEnvVar, levelStr, err)
+		}
+	}
+
+	var out io.Writer = os.Stderr
+	closer := noop
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return noop, fmt.Errorf("unable to open %s %q: %v", FileEnvVar, path, err)
+		}
+		out = f
+		closer = f.Close
+	}
+
+	slog.SetDefault(slog.New(NewHandler(out, level)).With("pid", os.Getpid()))
+	return closer, nil
+}
+
+// NewHandler returns a slog.Handler which writes text records of at least the
+// given level to w, redacting sensitive attributes.
+func NewHandler(w io.Writer, level slog.Leveler) slog.Handler {
+	return slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	})
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if SensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
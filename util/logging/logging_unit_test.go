@@ -0,0 +1,86 @@
+// Copyright 2026 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const secret = "ya29.much-secret-wow"
+
+func TestHandler_RedactsSensitiveKeys(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, slog.LevelDebug))
+
+	logger.Info("issued", "access_token", secret, "Refresh_Token", secret, slog.Group("creds", "secret", secret), "server_url", "https://gcr.io")
+
+	out := buf.String()
+	if strings.Contains(out, secret) {
+		t.Fatalf("Expected the secret to be redacted, got: %s", out)
+	}
+	if !strings.Contains(out, "server_url=https://gcr.io") {
+		t.Errorf("Expected non-sensitive attributes to be logged, got: %s", out)
+	}
+}
+
+func TestHandler_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, slog.LevelWarn))
+
+	logger.Info("quiet")
+	logger.Warn("loud")
+
+	if out := buf.String(); strings.Contains(out, "quiet") || !strings.Contains(out, "loud") {
+		t.Errorf("Expected only warnings to be logged, got: %s", out)
+	}
+}
+
+func TestInit_File(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	path := filepath.Join(t.TempDir(), "helper.log")
+	t.Setenv(FileEnvVar, path)
+	t.Setenv(LevelEnvVar, "debug")
+
+	closeLog, err := Init()
+	if err != nil {
+		t.Fatalf("Init returned an error: %v", err)
+	}
+	slog.Debug("hello", "token", secret)
+	if err := closeLog(); err != nil {
+		t.Fatalf("Unable to close the log: %v", err)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read the log: %v", err)
+	}
+	if !strings.Contains(string(contents), "msg=hello") || strings.Contains(string(contents), secret) {
+		t.Errorf("Unexpected log contents: %s", contents)
+	}
+}
+
+func TestInit_InvalidLevel(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	t.Setenv(LevelEnvVar, "chatty")
+
+	if _, err := Init(); err == nil {
+		t.Fatal("Expected an error for an invalid level")
+	}
+}
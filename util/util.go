@@ -51,3 +51,19 @@ func unixHomeDir() string {
 	}
 	return ""
 }
+
+// HasTerminal reports whether the process is attached to a terminal with which
+// the user may interact. Docker pipes the credential helper's stdin and stdout,
+// so the controlling terminal is checked instead where there is one.
+func HasTerminal() bool {
+	if runtime.GOOS != "windows" {
+		tty, err := os.Open("/dev/tty")
+		if err != nil {
+			return false
+		}
+		tty.Close()
+		return true
+	}
+	fi, err := os.Stderr.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}